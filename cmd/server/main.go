@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/developia-II/ecommerce-backend/internal/database"
 	"github.com/developia-II/ecommerce-backend/internal/handlers"
+	"github.com/developia-II/ecommerce-backend/internal/jobs"
+	"github.com/developia-II/ecommerce-backend/internal/payments"
+	"github.com/developia-II/ecommerce-backend/internal/search"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -24,6 +29,12 @@ func main() {
 		db = nil
 	} else {
 		logrus.Info("Successfully connected to DB")
+		if err := database.EnsureIndexes(db); err != nil {
+			logrus.WithError(err).Warn("Failed to create database indexes")
+		}
+		if err := database.Migrate(db); err != nil {
+			logrus.WithError(err).Warn("Failed to migrate existing documents")
+		}
 	}
 
 	logrus.Info("Setting up Gin router...")
@@ -32,10 +43,21 @@ func main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "https://your-frontend-url.vercel.app"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Cart-Token", "Idempotency-Key"},
+		ExposeHeaders:    []string{"X-Cart-Token"},
 		AllowCredentials: true,
 	}))
-	handlers.SetupRoutes(router, db)
+	provider := payments.FromEnv()
+	handlers.SetupRoutes(router, db, provider)
+
+	if db != nil {
+		logrus.Info("Starting background jobs...")
+		jobs.Every(context.Background(), "monthly-sales-reset", time.Hour, jobs.ResetMonthlySales(db))
+		jobs.Every(context.Background(), "search-vocabulary", 10*time.Minute, jobs.RefreshSearchVocabulary(db, search.Products))
+		jobs.Every(context.Background(), "payment-reconciliation", 5*time.Minute, handlers.ReconcilePayments(db, provider))
+		jobs.Every(context.Background(), "payout-hold-release", time.Hour, handlers.ReleaseHeldFunds(db))
+		jobs.Every(context.Background(), "wishlist-alerts", 15*time.Minute, handlers.SendWishlistAlerts(db))
+	}
 
 	logrus.Info("Loading environment variables...")
 	if err := godotenv.Load(); err != nil {
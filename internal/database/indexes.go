@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the handlers rely on. CreateMany is a
+// no-op for indexes that already exist, so it is safe to call on every start.
+func EnsureIndexes(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		"verification_tokens": {
+			{
+				Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "userId", Value: 1}, {Key: "purpose", Value: 1}},
+			},
+			{
+				// Keep expired tokens around for a week so they can still be
+				// reported as expired rather than unknown.
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(int32((7 * 24 * time.Hour).Seconds())),
+			},
+		},
+		"sessions": {
+			{
+				Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "familyId", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "userId", Value: 1}},
+			},
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+		"seller_applications": {
+			{
+				Keys: bson.D{{Key: "userID", Value: 1}, {Key: "status", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "status", Value: 1}, {Key: "riskScore", Value: -1}, {Key: "appliedAt", Value: 1}},
+			},
+			{
+				Keys:    bson.D{{Key: "taxId", Value: 1}},
+				Options: options.Index().SetSparse(true),
+			},
+		},
+		"seller_application_reviews": {
+			{
+				Keys: bson.D{{Key: "applicationID", Value: 1}, {Key: "createdAt", Value: 1}},
+			},
+		},
+		"carts": {
+			{
+				Keys:    bson.D{{Key: "userId", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		"guest_carts": {
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+		"categories": {
+			{
+				Keys: bson.D{{Key: "parentId", Value: 1}},
+			},
+			{
+				// Sibling names are unique regardless of case
+				Keys: bson.D{{Key: "parentId", Value: 1}, {Key: "name", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+			},
+		},
+		"products": {
+			{
+				Keys: bson.D{{Key: "name", Value: "text"}, {Key: "categoryName", Value: "text"}, {Key: "description", Value: "text"}},
+				Options: options.Index().SetName("product_text").
+					SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "categoryName", Value: 5}, {Key: "description", Value: 1}}),
+			},
+			{
+				Keys: bson.D{{Key: "vendorId", Value: 1}, {Key: "createdAt", Value: -1}},
+			},
+			// Public listing: one index per sort order, with and without a
+			// category filter. _id comes last to match the cursor tie-breaker.
+			{
+				Keys: bson.D{{Key: "categoryId", Value: 1}, {Key: "_id", Value: -1}},
+			},
+			{
+				Keys: bson.D{{Key: "categoryId", Value: 1}, {Key: "price", Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "categoryId", Value: 1}, {Key: "salesCount", Value: -1}, {Key: "_id", Value: -1}},
+			},
+			{
+				Keys: bson.D{{Key: "price", Value: 1}, {Key: "_id", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "salesCount", Value: -1}, {Key: "_id", Value: -1}},
+			},
+			{
+				Keys: bson.D{{Key: "vendorId", Value: 1}, {Key: "_id", Value: -1}},
+			},
+			{
+				// SKUs are unique per vendor among live products, so a deleted
+				// product's SKUs can be reused. Uniqueness between variants of
+				// the same product is checked by Product.ValidateVariants.
+				Keys: bson.D{{Key: "vendorId", Value: 1}, {Key: "variants.sku", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{
+						"variants.sku": bson.M{"$exists": true},
+						"deletedAt":    bson.M{"$exists": false},
+					}),
+			},
+		},
+		"orders": {
+			{
+				// A checkout retried with the same key finds the first order
+				Keys: bson.D{{Key: "userId", Value: 1}, {Key: "idempotencyKey", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"idempotencyKey": bson.M{"$exists": true}}),
+			},
+			{
+				Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+			},
+			{
+				// Unpaid orders are expired oldest first
+				Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}},
+			},
+		},
+		"payments": {
+			{
+				Keys:    bson.D{{Key: "reference", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "orderId", Value: 1}, {Key: "createdAt", Value: -1}},
+			},
+			{
+				Keys: bson.D{{Key: "provider", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: 1}},
+			},
+			{
+				Keys:    bson.D{{Key: "refundId", Value: 1}},
+				Options: options.Index().SetSparse(true),
+			},
+		},
+		"refunds": {
+			{
+				Keys: bson.D{{Key: "orderId", Value: 1}, {Key: "createdAt", Value: -1}},
+			},
+			{
+				Keys:    bson.D{{Key: "providerRefundId", Value: 1}},
+				Options: options.Index().SetSparse(true),
+			},
+			{
+				Keys: bson.D{{Key: "paymentId", Value: 1}, {Key: "status", Value: 1}},
+			},
+		},
+		"ledger_entries": {
+			{
+				Keys: bson.D{{Key: "vendorId", Value: 1}, {Key: "createdAt", Value: -1}},
+			},
+			{
+				Keys: bson.D{{Key: "subOrderId", Value: 1}, {Key: "type", Value: 1}},
+			},
+			{
+				// A sub-order is credited to its vendor once; only sales
+				// have availableAt
+				Keys: bson.D{{Key: "subOrderId", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"availableAt": bson.M{"$exists": true}}),
+			},
+			{
+				Keys: bson.D{{Key: "type", Value: 1}, {Key: "availableAt", Value: 1}},
+			},
+		},
+		"payouts": {
+			{
+				Keys: bson.D{{Key: "vendorId", Value: 1}, {Key: "requestedAt", Value: -1}},
+			},
+			{
+				Keys: bson.D{{Key: "status", Value: 1}, {Key: "requestedAt", Value: -1}},
+			},
+		},
+		"wishlists": {
+			{
+				Keys:    bson.D{{Key: "userId", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys: bson.D{{Key: "productIds", Value: 1}},
+			},
+		},
+		"wishlist_notifications": {
+			{
+				// The same alert is only sent once
+				Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "productId", Value: 1}, {Key: "key", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		"wishlist_alert_counts": {
+			{
+				// One counter per user per day; the rate limit relies on it
+				Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "day", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+		"sub_orders": {
+			{
+				Keys: bson.D{{Key: "orderId", Value: 1}},
+			},
+			{
+				Keys: bson.D{{Key: "vendorId", Value: 1}, {Key: "createdAt", Value: -1}},
+			},
+			{
+				Keys: bson.D{{Key: "vendorId", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: -1}},
+			},
+		},
+		"vendor_accounts": {
+			{
+				Keys:    bson.D{{Key: "userID", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+	}
+
+	for name, models := range indexes {
+		if _, err := db.Collection(name).Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+	return nil
+}
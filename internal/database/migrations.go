@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrate backfills fields that newer code expects on documents written
+// before those fields existed. Every step only touches documents that are
+// still out of date, so it is safe to call on every start.
+func Migrate(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if err := backfillCategoryNames(ctx, db); err != nil {
+		return err
+	}
+	return backfillSubOrders(ctx, db)
+}
+
+// backfillCategoryNames copies each category's name onto its products, so
+// products created before categoryName existed are found by text search.
+func backfillCategoryNames(ctx context.Context, db *mongo.Database) error {
+	cursor, err := db.Collection("categories").Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	products := db.Collection("products")
+	for cursor.Next(ctx) {
+		var category struct {
+			ID   interface{} `bson:"_id"`
+			Name string      `bson:"name"`
+		}
+		if err := cursor.Decode(&category); err != nil {
+			return err
+		}
+		if _, err := products.UpdateMany(ctx, bson.M{
+			"categoryId":   category.ID,
+			"categoryName": bson.M{"$ne": category.Name},
+		}, bson.M{"$set": bson.M{"categoryName": category.Name}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// backfillSubOrders splits orders placed before sub-orders existed, so their
+// vendors can fulfil them and their status can be derived like any other.
+func backfillSubOrders(ctx context.Context, db *mongo.Database) error {
+	cursor, err := db.Collection("orders").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$lookup", Value: bson.M{"from": "sub_orders", "localField": "_id", "foreignField": "orderId", "as": "subOrders"}}},
+		{{Key: "$match", Value: bson.M{"subOrders.0": bson.M{"$exists": false}}}},
+		{{Key: "$project", Value: bson.M{"subOrders": 0}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	subOrders := db.Collection("sub_orders")
+	for cursor.Next(ctx) {
+		var order models.Order
+		if err := cursor.Decode(&order); err != nil {
+			return err
+		}
+		split := models.SplitOrder(&order)
+		if len(split) == 0 {
+			continue
+		}
+		docs := make([]interface{}, 0, len(split))
+		for _, sub := range split {
+			docs = append(docs, sub)
+		}
+		if _, err := subOrders.InsertMany(ctx, docs); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -14,6 +14,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -61,7 +62,7 @@ func (h *AuthHandler) CreateUser(c *gin.Context) {
 		Phone:      user.Phone,
 		Address:    user.Address,
 		Password:   string(hashedPassword),
-		Role:       "customer",
+		Role:       models.RoleCustomer,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 		IsVerified: false,
@@ -71,28 +72,14 @@ func (h *AuthHandler) CreateUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to register user"))
 		return
 	}
-	verificationLink := fmt.Sprintf("http://localhost:3000/verify?token=%s", newUser.ID.Hex())
-	emailBody := fmt.Sprintf(`
-    <html>
-    <body style="font-family: Arial, sans-serif;">
-        <h2>Welcome to Vendora, %s!</h2>
-        <p>Thank you for registering. Please verify your email by clicking the button below:</p>
-        <a href="%s" style="background-color: #4CAF50; color: white; padding: 10px 20px; text-decoration: none; border-radius: 5px;">Verify Email</a>
-        <p>If you didn't create this account, please ignore this email.</p>
-        <p>Best regards,<br>The Vendora Team</p>
-    </body>
-    </html>
-`, newUser.Name, verificationLink)
-
-	go func() {
-		if err := utils.SendEmail(user.Email, "Verify Your Vendora Account", emailBody); err != nil {
-			logrus.WithError(err).WithField("email", user.Email).Error("Failed to send verification email")
-		} else {
-			logrus.WithField("email", user.Email).Info("Verification email sent successfully")
-		}
-	}()
+	verificationToken, err := h.issueVerificationToken(ctx, newUser.ID)
+	if err != nil {
+		logrus.WithError(err).WithField("email", user.Email).Error("Failed to issue verification token")
+	} else {
+		sendVerificationEmail(newUser.Name, newUser.Email, verificationToken, "Thank you for registering.")
+	}
 
-	accessToken, err := utils.GenerateToken(newUser.ID.Hex(), newUser.Role, 24*time.Hour)
+	accessToken, refreshToken, err := h.issueTokens(ctx, c, newUser, primitive.NilObjectID, primitive.NilObjectID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("failed to generate accessToken"))
 		return
@@ -106,29 +93,71 @@ func (h *AuthHandler) CreateUser(c *gin.Context) {
 			"name":  newUser.Name,
 			"email": newUser.Email,
 		},
-		"accessToken": accessToken,
-		"isVerified":  newUser.IsVerified,
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
+		"isVerified":   newUser.IsVerified,
 	}
+	h.attachMergedCart(ctx, c, newUser.ID, response)
 	c.JSON(http.StatusCreated, utils.SuccessResponse("User Created Successfully", response))
 
 }
 
 func (h *AuthHandler) VerifyEmail(c *gin.Context) {
 	token := c.Param("token")
-
-	userID, err := primitive.ObjectIDFromHex(token)
-	if err != nil {
+	if token == "" {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid token"))
 		return
 	}
 
-	collection := h.DB.Collection("users")
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	update := bson.M{"$set": bson.M{"isVerified": true}}
+	tokens := h.DB.Collection("verification_tokens")
+	var record models.VerificationToken
+	err := tokens.FindOne(ctx, bson.M{
+		"tokenHash": utils.HashToken(token),
+		"purpose":   models.TokenPurposeEmailVerification,
+	}).Decode(&record)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid token"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to verify token"))
+		return
+	}
+	if record.UsedAt != nil {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Verification token has already been used"))
+		return
+	}
+	if record.InvalidatedAt != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Verification token has been replaced by a newer one"))
+		return
+	}
+	if time.Now().After(record.ExpiresAt) {
+		c.JSON(http.StatusGone, utils.ErrorResponse("Verification token has expired"))
+		return
+	}
+
+	// Consume the token atomically so two concurrent requests cannot both use it.
+	consumed := tokens.FindOneAndUpdate(ctx, bson.M{
+		"_id":           record.ID,
+		"usedAt":        bson.M{"$exists": false},
+		"invalidatedAt": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"usedAt": time.Now()}})
+	if err := consumed.Err(); err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Verification token has already been used"))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to verify token"))
+		return
+	}
+
+	collection := h.DB.Collection("users")
+	update := bson.M{"$set": bson.M{"isverified": true, "updatedAt": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	var updatedUser models.User
-	if err := collection.FindOneAndUpdate(ctx, bson.M{"_id": userID}, update).Decode(&updatedUser); err != nil {
+	if err := collection.FindOneAndUpdate(ctx, bson.M{"_id": record.UserID}, update, opts).Decode(&updatedUser); err != nil {
 		c.JSON(http.StatusNotFound, utils.ErrorResponse("User not found"))
 		return
 	}
@@ -147,54 +176,47 @@ func (h *AuthHandler) VerifyEmail(c *gin.Context) {
 }
 
 func (h *AuthHandler) ResendVerification(c *gin.Context) {
-	token := c.Param("token")
-
-	userID, err := primitive.ObjectIDFromHex(token)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid token"))
+	var input struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
 		return
 	}
 
-	collection := h.DB.Collection("users")
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
+	// The response is the same whether or not the account exists, so the
+	// endpoint cannot be used to find out who is registered
+	const sent = "If the account exists and is not verified yet, a verification email has been sent"
 	var user models.User
-	err = collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
-	if err != nil {
-		c.JSON(http.StatusNotFound, utils.ErrorResponse("User not found"))
+	if err := h.DB.Collection("users").FindOne(ctx, bson.M{"email": input.Email}).Decode(&user); err != nil {
+		if err != mongo.ErrNoDocuments {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch user"))
+			return
+		}
+		c.JSON(http.StatusOK, utils.SuccessResponse(sent, nil))
 		return
 	}
-
-	// Check if user is already verified
 	if user.IsVerified {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse("User is already verified"))
+		c.JSON(http.StatusOK, utils.SuccessResponse(sent, nil))
 		return
 	}
 
-	// Generate new verification link (using the same user ID as token)
-	verificationLink := fmt.Sprintf("http://localhost:3000/verify?token=%s", user.ID.Hex())
-	emailBody := fmt.Sprintf(`
-    <html>
-    <body style="font-family: Arial, sans-serif;">
-        <h2>Welcome to Vendora, %s!</h2>
-        <p>Here is your verification link again. Please verify your email by clicking the button below:</p>
-        <a href="%s" style="background-color: #4CAF50; color: white; padding: 10px 20px; text-decoration: none; border-radius: 5px;">Verify Email</a>
-        <p>If you didn't create this account, please ignore this email.</p>
-        <p>Best regards,<br>The Vendora Team</p>
-    </body>
-    </html>
-`, user.Name, verificationLink)
-
-	go func() {
-		if err := utils.SendEmail(user.Email, "Verify Your Vendora Account", emailBody); err != nil {
-			logrus.WithError(err).WithField("email", user.Email).Error("Failed to send verification email")
-		} else {
-			logrus.WithField("email", user.Email).Info("Verification email sent successfully")
-		}
-	}()
+	// Issuing a new token invalidates every link sent before it
+	verificationToken, err := h.issueVerificationToken(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to generate verification token"))
+		return
+	}
+	sendVerificationEmail(user.Name, user.Email, verificationToken, "Here is your verification link again.")
 
-	c.JSON(http.StatusOK, utils.SuccessResponse("Verification email sent successfully", nil))
+	c.JSON(http.StatusOK, utils.SuccessResponse(sent, nil))
 }
 
 func (h *AuthHandler) LoginUser(c *gin.Context) {
@@ -228,7 +250,7 @@ func (h *AuthHandler) LoginUser(c *gin.Context) {
 		c.JSON(http.StatusForbidden, utils.ErrorResponse("Please verify your account"))
 		return
 	}
-	token, err := utils.GenerateToken(user.ID.Hex(), user.Role, 24*time.Hour)
+	token, refreshToken, err := h.issueTokens(ctx, c, user, primitive.NilObjectID, primitive.NilObjectID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to generate token"))
 		return
@@ -241,8 +263,10 @@ func (h *AuthHandler) LoginUser(c *gin.Context) {
 			"address": user.Address,
 			"role":    user.Role,
 		},
-		"accessToken": token,
+		"accessToken":  token,
+		"refreshToken": refreshToken,
 	}
+	h.attachMergedCart(ctx, c, user.ID, res)
 	c.JSON(http.StatusAccepted, utils.SuccessResponse("Login Successfull", res))
 }
 
@@ -375,6 +399,11 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
+	// A password change signs the user out everywhere
+	if err := h.revokeSessions(ctx, bson.M{"userId": user.ID}, "logout_all"); err != nil {
+		logrus.WithError(err).WithField("userId", user.ID.Hex()).Error("Failed to revoke sessions after password reset")
+	}
+
 	response := gin.H{
 		"success": true,
 		"user": gin.H{
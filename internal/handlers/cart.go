@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxCartQuantity = 99
+
+type CartHandler struct {
+	DB *mongo.Database
+}
+
+func NewCartHandler(db *mongo.Database) *CartHandler {
+	return &CartHandler{DB: db}
+}
+
+var errCartConflict = errors.New("cart was modified concurrently")
+
+// cartStore locates a cart document: the user's cart in "carts", or a guest
+// cart. Carts with a TTL expire that long after their last change.
+type cartStore struct {
+	Collection *mongo.Collection
+	Filter     bson.M
+	TTL        time.Duration
+}
+
+func (h *CartHandler) userCart(c *gin.Context) cartStore {
+	return cartStore{Collection: h.DB.Collection("carts"), Filter: bson.M{"userId": middleware.UserID(c)}}
+}
+
+type cartItemInput struct {
+	ProductID string `json:"productId" validate:"required"`
+	VariantID string `json:"variantId"`
+	Quantity  int    `json:"quantity" validate:"required,min=1,max=99"`
+}
+
+// cartLine is a cart item as shown to the client, with what changed since
+// the cart was last looked at.
+type cartLine struct {
+	models.CartItem
+	LineTotal        float64 `json:"lineTotal"`
+	InStock          int     `json:"inStock"`
+	PriceChanged     bool    `json:"priceChanged"`
+	PreviousPrice    float64 `json:"previousPrice,omitempty"`
+	SoldOut          bool    `json:"soldOut"`
+	QuantityAdjusted bool    `json:"quantityAdjusted"`
+}
+
+type removedCartItem struct {
+	ProductID primitive.ObjectID  `json:"productId"`
+	VariantID *primitive.ObjectID `json:"variantId,omitempty"`
+	Name      string              `json:"name"`
+	Reason    string              `json:"reason"`
+}
+
+type cartView struct {
+	Items     []cartLine        `json:"items"`
+	Removed   []removedCartItem `json:"removed"`
+	Subtotal  float64           `json:"subtotal"`
+	ItemCount int               `json:"itemCount"`
+}
+
+// loadCart returns the stored cart, or a new empty one.
+func loadCart(ctx context.Context, store cartStore) (*models.Cart, error) {
+	var cart models.Cart
+	err := store.Collection.FindOne(ctx, store.Filter).Decode(&cart)
+	if err == mongo.ErrNoDocuments {
+		return &models.Cart{Items: []models.CartItem{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// saveCart writes the cart back, failing with errCartConflict if it changed
+// since it was loaded.
+func saveCart(ctx context.Context, store cartStore, cart *models.Cart) error {
+	filter := bson.M{"version": cart.Version}
+	for k, v := range store.Filter {
+		filter[k] = v
+	}
+	now := time.Now()
+	set := bson.M{"items": cart.Items, "updatedAt": now}
+	if store.TTL > 0 {
+		set["expiresAt"] = now.Add(store.TTL)
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := store.Collection.FindOneAndUpdate(ctx, filter, bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"createdAt": now},
+		"$inc":         bson.M{"version": 1},
+	}, opts).Decode(cart)
+	// A cart with another version exists, so the upsert collided with it
+	if mongo.IsDuplicateKeyError(err) {
+		return errCartConflict
+	}
+	return err
+}
+
+// refreshCart checks every item against the live product: prices are
+// brought up to date, quantities are capped at the available stock, and
+// items whose product is gone are dropped. Sold out items stay in the cart
+// but do not count towards the subtotal. It reports whether the cart
+// changed.
+func refreshCart(ctx context.Context, db *mongo.Database, cart *models.Cart) (cartView, bool, error) {
+	view := cartView{Items: []cartLine{}, Removed: []removedCartItem{}}
+	if len(cart.Items) == 0 {
+		return view, false, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		ids = append(ids, item.ProductID)
+	}
+	cursor, err := db.Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "deletedAt": notDeleted})
+	if err != nil {
+		return view, false, err
+	}
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return view, false, err
+	}
+	byID := make(map[primitive.ObjectID]*models.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+
+	changed := false
+	kept := cart.Items[:0]
+	for _, item := range cart.Items {
+		product, ok := byID[item.ProductID]
+		var price float64
+		var stock int
+		var sku string
+		if ok {
+			price, stock, sku, err = sellable(product, item.VariantID)
+		}
+		if !ok || err != nil {
+			view.Removed = append(view.Removed, removedCartItem{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				Name:      item.Name,
+				Reason:    "no longer available",
+			})
+			changed = true
+			continue
+		}
+
+		line := cartLine{InStock: stock}
+		if price != item.Price {
+			line.PriceChanged, line.PreviousPrice = true, item.Price
+			item.Price = price
+			changed = true
+		}
+		if item.Name != product.Name || item.SKU != sku {
+			item.Name, item.SKU = product.Name, sku
+			changed = true
+		}
+		if stock <= 0 {
+			line.SoldOut = true
+		} else if item.Quantity > stock {
+			item.Quantity = stock
+			line.QuantityAdjusted = true
+			changed = true
+		}
+
+		line.CartItem = item
+		if !line.SoldOut {
+			line.LineTotal = item.Price * float64(item.Quantity)
+			view.Subtotal += line.LineTotal
+			view.ItemCount += item.Quantity
+		}
+		view.Items = append(view.Items, line)
+		kept = append(kept, item)
+	}
+	cart.Items = kept
+	return view, changed, nil
+}
+
+// updateCart loads the cart, applies mutate, refreshes it against live
+// product data and saves it, then writes the cart as the response. mutate
+// may write an error response and return false to abort.
+func updateCart(c *gin.Context, db *mongo.Database, store cartStore, message string, mutate func(ctx context.Context, cart *models.Cart) bool) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	cart, err := loadCart(ctx, store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch cart"))
+		return
+	}
+	if mutate != nil && !mutate(ctx, cart) {
+		return
+	}
+	view, changed, err := refreshCart(ctx, db, cart)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to refresh cart"))
+		return
+	}
+	if mutate != nil || changed {
+		if err := saveCart(ctx, store, cart); errors.Is(err, errCartConflict) {
+			c.JSON(http.StatusConflict, utils.ErrorResponse("Cart was updated elsewhere, please try again"))
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to save cart"))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse(message, gin.H{"cart": view}))
+}
+
+// setCartQuantity validates a product or variant and puts quantity of it in
+// the cart, adding to what is there already when add is set.
+func setCartQuantity(ctx context.Context, c *gin.Context, db *mongo.Database, cart *models.Cart, productID primitive.ObjectID, variantID *primitive.ObjectID, quantity int, add bool) bool {
+	var product models.Product
+	err := db.Collection("products").FindOne(ctx, bson.M{"_id": productID, "deletedAt": notDeleted}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Product not found"))
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch product"))
+		return false
+	}
+	price, stock, sku, err := sellable(&product, variantID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return false
+	}
+
+	index := cart.Find(productID, variantID)
+	if add && index >= 0 {
+		quantity += cart.Items[index].Quantity
+	}
+	if quantity > maxCartQuantity {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(fmt.Sprintf("You can add at most %d of an item", maxCartQuantity)))
+		return false
+	}
+	if stock <= 0 {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("This item is out of stock"))
+		return false
+	}
+	if quantity > stock {
+		c.JSON(http.StatusConflict, utils.ErrorResponse(fmt.Sprintf("Only %d left in stock", stock)))
+		return false
+	}
+
+	item := models.CartItem{
+		ProductID: productID,
+		VariantID: variantID,
+		SKU:       sku,
+		Name:      product.Name,
+		Price:     price,
+		Quantity:  quantity,
+	}
+	if index >= 0 {
+		cart.Items[index] = item
+	} else {
+		cart.Items = append(cart.Items, item)
+	}
+	return true
+}
+
+// cartLineParams reads the product ID path parameter and the optional
+// variantId query parameter that identify a cart line.
+func cartLineParams(c *gin.Context) (primitive.ObjectID, *primitive.ObjectID, bool) {
+	productID, err := primitive.ObjectIDFromHex(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid product ID"))
+		return productID, nil, false
+	}
+	variantID, ok := optionalObjectID(c, c.Query("variantId"), "Invalid variant ID")
+	return productID, variantID, ok
+}
+
+func optionalObjectID(c *gin.Context, raw, message string) (*primitive.ObjectID, bool) {
+	if raw == "" {
+		return nil, true
+	}
+	id, err := primitive.ObjectIDFromHex(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(message))
+		return nil, false
+	}
+	return &id, true
+}
+
+func (h *CartHandler) getCart(c *gin.Context, store cartStore) {
+	updateCart(c, h.DB, store, "Cart fetched successfully", nil)
+}
+
+func (h *CartHandler) addItem(c *gin.Context, store cartStore) {
+	var input cartItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	productID, err := primitive.ObjectIDFromHex(input.ProductID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid product ID"))
+		return
+	}
+	variantID, ok := optionalObjectID(c, input.VariantID, "Invalid variant ID")
+	if !ok {
+		return
+	}
+
+	updateCart(c, h.DB, store, "Item added to cart", func(ctx context.Context, cart *models.Cart) bool {
+		return setCartQuantity(ctx, c, h.DB, cart, productID, variantID, input.Quantity, true)
+	})
+}
+
+func (h *CartHandler) updateItem(c *gin.Context, store cartStore) {
+	productID, variantID, ok := cartLineParams(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Quantity int `json:"quantity" validate:"required,min=1,max=99"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	updateCart(c, h.DB, store, "Cart updated", func(ctx context.Context, cart *models.Cart) bool {
+		if cart.Find(productID, variantID) < 0 {
+			c.JSON(http.StatusNotFound, utils.ErrorResponse("Item is not in the cart"))
+			return false
+		}
+		return setCartQuantity(ctx, c, h.DB, cart, productID, variantID, input.Quantity, false)
+	})
+}
+
+func (h *CartHandler) removeItem(c *gin.Context, store cartStore) {
+	productID, variantID, ok := cartLineParams(c)
+	if !ok {
+		return
+	}
+	updateCart(c, h.DB, store, "Item removed from cart", func(ctx context.Context, cart *models.Cart) bool {
+		index := cart.Find(productID, variantID)
+		if index < 0 {
+			c.JSON(http.StatusNotFound, utils.ErrorResponse("Item is not in the cart"))
+			return false
+		}
+		cart.Items = append(cart.Items[:index], cart.Items[index+1:]...)
+		return true
+	})
+}
+
+func (h *CartHandler) clearCart(c *gin.Context, store cartStore) {
+	updateCart(c, h.DB, store, "Cart cleared", func(ctx context.Context, cart *models.Cart) bool {
+		cart.Items = []models.CartItem{}
+		return true
+	})
+}
+
+// GetCart returns the user's cart with prices and stock refreshed.
+func (h *CartHandler) GetCart(c *gin.Context) {
+	h.getCart(c, h.userCart(c))
+}
+
+// AddCartItem adds a product, or a variant of it, to the user's cart.
+func (h *CartHandler) AddCartItem(c *gin.Context) {
+	h.addItem(c, h.userCart(c))
+}
+
+// UpdateCartItem sets the quantity of an item in the user's cart.
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	h.updateItem(c, h.userCart(c))
+}
+
+// RemoveCartItem removes an item from the user's cart.
+func (h *CartHandler) RemoveCartItem(c *gin.Context) {
+	h.removeItem(c, h.userCart(c))
+}
+
+// ClearCart empties the user's cart.
+func (h *CartHandler) ClearCart(c *gin.Context) {
+	h.clearCart(c, h.userCart(c))
+}
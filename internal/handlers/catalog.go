@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// productSort describes one of the public listing orders. Every order ends
+// with _id so the cursor position is unique. Field is empty for orders on
+// _id alone.
+type productSort struct {
+	Field     string
+	Direction int
+}
+
+var productSorts = map[string]productSort{
+	"newest":     {Direction: -1},
+	"price_asc":  {Field: "price", Direction: 1},
+	"price_desc": {Field: "price", Direction: -1},
+	"popular":    {Field: "salesCount", Direction: -1},
+}
+
+// categoryWithDescendants returns the category's ID together with the IDs of
+// all categories below it.
+func categoryWithDescendants(ctx context.Context, db *mongo.Database, categoryID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := db.Collection("categories").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"_id": categoryID}}},
+		{{Key: "$graphLookup", Value: bson.M{
+			"from":             "categories",
+			"startWith":        "$_id",
+			"connectFromField": "_id",
+			"connectToField":   "parentId",
+			"as":               "descendants",
+		}}},
+		{{Key: "$project", Value: bson.M{"descendants._id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []struct {
+		ID          primitive.ObjectID `bson:"_id"`
+		Descendants []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		} `bson:"descendants"`
+	}
+	if err := cursor.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, errCategoryNotFound
+	}
+
+	ids := []primitive.ObjectID{result[0].ID}
+	for _, d := range result[0].Descendants {
+		ids = append(ids, d.ID)
+	}
+	return ids, nil
+}
+
+// afterCursor builds the keyset condition selecting documents that come
+// after the cursor position in the given order.
+func afterCursor(sort productSort, cursor utils.Cursor, id primitive.ObjectID) bson.M {
+	op := "$gt"
+	if sort.Direction < 0 {
+		op = "$lt"
+	}
+	if sort.Field == "" {
+		return bson.M{"_id": bson.M{op: id}}
+	}
+	return bson.M{"$or": bson.A{
+		bson.M{sort.Field: bson.M{op: cursor.Value}},
+		bson.M{sort.Field: cursor.Value, "_id": bson.M{op: id}},
+	}}
+}
+
+// ListProducts is the public catalog listing. It supports filtering by
+// category (including subcategories), price range, stock and vendor, and
+// pages through results with an opaque cursor rather than an offset so deep
+// pages stay cheap on large catalogs.
+func (h *ProductHandler) ListProducts(c *gin.Context) {
+	sortName := c.DefaultQuery("sort", "newest")
+	sort, ok := productSorts[sortName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("sort must be one of newest, price_asc, price_desc, popular"))
+		return
+	}
+	limit := limitParam(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"deletedAt": notDeleted}
+	if raw := c.Query("category"); raw != "" {
+		categoryID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid category ID"))
+			return
+		}
+		ids, err := categoryWithDescendants(ctx, h.DB, categoryID)
+		if errors.Is(err, errCategoryNotFound) {
+			c.JSON(http.StatusNotFound, utils.ErrorResponse("Category not found"))
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch category"))
+			return
+		}
+		filter["categoryId"] = bson.M{"$in": ids}
+	}
+	if raw := c.Query("vendor"); raw != "" {
+		vendorID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid vendor ID"))
+			return
+		}
+		filter["vendorId"] = vendorID
+	}
+
+	price := bson.M{}
+	for param, op := range map[string]string{"minPrice": "$gte", "maxPrice": "$lte"} {
+		if raw := c.Query(param); raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || value < 0 {
+				c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid "+param))
+				return
+			}
+			price[op] = value
+		}
+	}
+	if len(price) > 0 {
+		filter["price"] = price
+	}
+	if c.Query("inStock") == "true" {
+		filter["stock"] = bson.M{"$gt": 0}
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := utils.DecodeCursor(raw)
+		if err != nil || cursor.Sort != sortName {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid cursor"))
+			return
+		}
+		id, err := primitive.ObjectIDFromHex(cursor.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid cursor"))
+			return
+		}
+		filter = bson.M{"$and": bson.A{filter, afterCursor(sort, cursor, id)}}
+	}
+
+	order := bson.D{{Key: "_id", Value: sort.Direction}}
+	if sort.Field != "" {
+		order = bson.D{{Key: sort.Field, Value: sort.Direction}, {Key: "_id", Value: sort.Direction}}
+	}
+	// Fetch one extra product to find out whether there is another page
+	opts := options.Find().SetSort(order).SetLimit(limit + 1)
+	cursor, err := h.DB.Collection("products").Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch products"))
+		return
+	}
+	products := []models.Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch products"))
+		return
+	}
+
+	var nextCursor string
+	if int64(len(products)) > limit {
+		products = products[:limit]
+		last := products[len(products)-1]
+		next := utils.Cursor{Sort: sortName, ID: last.ID.Hex()}
+		switch sort.Field {
+		case "price":
+			next.Value = last.Price
+		case "salesCount":
+			next.Value = float64(last.SalesCount)
+		}
+		nextCursor = utils.EncodeCursor(next)
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Products fetched successfully", gin.H{
+		"products":   products,
+		"nextCursor": nextCursor,
+		"limit":      limit,
+	}))
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CategoryHandler struct {
+	DB *mongo.Database
+}
+
+func NewCategoryHandler(db *mongo.Database) *CategoryHandler {
+	return &CategoryHandler{DB: db}
+}
+
+var errCategoryCycle = errors.New("a category cannot be moved under itself or its subcategories")
+
+type categoryInput struct {
+	Name        string `json:"name" validate:"required,min=2,max=100"`
+	Description string `json:"description" validate:"max=500"`
+	ParentID    string `json:"parentId"`
+}
+
+// parseParent resolves an optional parent category ID. An empty ID means the
+// category is a root.
+func parseParent(ctx context.Context, db *mongo.Database, id string) (*primitive.ObjectID, error) {
+	if id == "" {
+		return nil, nil
+	}
+	parent, err := resolveCategory(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+	return &parent.ID, nil
+}
+
+// unknownCategories returns the entries of ids that are not the ID of an
+// existing category.
+func unknownCategories(ctx context.Context, db *mongo.Database, ids []string) ([]string, error) {
+	var unknown []string
+	objectIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objectID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			unknown = append(unknown, id)
+			continue
+		}
+		objectIDs = append(objectIDs, objectID)
+	}
+	if len(objectIDs) == 0 {
+		return unknown, nil
+	}
+
+	cursor, err := db.Collection("categories").Find(ctx, bson.M{"_id": bson.M{"$in": objectIDs}}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var found []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	exists := make(map[primitive.ObjectID]bool, len(found))
+	for _, f := range found {
+		exists[f.ID] = true
+	}
+	for _, id := range objectIDs {
+		if !exists[id] {
+			unknown = append(unknown, id.Hex())
+		}
+	}
+	return unknown, nil
+}
+
+// checkCategories responds with 400 and returns false when any of ids is not
+// an existing category.
+func checkCategories(ctx context.Context, c *gin.Context, db *mongo.Database, ids []string) bool {
+	unknown, err := unknownCategories(ctx, db, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to validate categories"))
+		return false
+	}
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Unknown categories: "+strings.Join(unknown, ", ")))
+		return false
+	}
+	return true
+}
+
+func (h *CategoryHandler) nameTaken(ctx context.Context, name string, parentID *primitive.ObjectID, except primitive.ObjectID) (bool, error) {
+	count, err := h.DB.Collection("categories").CountDocuments(ctx, bson.M{
+		"_id":      bson.M{"$ne": except},
+		"parentId": parentID,
+		"name":     primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"},
+	})
+	return count > 0, err
+}
+
+// GetCategoryTree returns every category nested under its parent, with
+// product counts.
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := h.DB.Collection("categories").Find(ctx, bson.M{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch categories"))
+		return
+	}
+	var categories []models.Category
+	if err := cursor.All(ctx, &categories); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch categories"))
+		return
+	}
+
+	countCursor, err := h.DB.Collection("products").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"deletedAt": notDeleted}}},
+		{{Key: "$group", Value: bson.M{"_id": "$categoryId", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to count products"))
+		return
+	}
+	var countRows []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Count int                `bson:"count"`
+	}
+	if err := countCursor.All(ctx, &countRows); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to count products"))
+		return
+	}
+	counts := make(map[primitive.ObjectID]int, len(countRows))
+	for _, row := range countRows {
+		counts[row.ID] = row.Count
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Categories fetched successfully", gin.H{
+		"categories": models.BuildCategoryTree(categories, counts),
+	}))
+}
+
+// CreateCategory adds a root category or a subcategory.
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var input categoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	parentID, err := parseParent(ctx, h.DB, input.ParentID)
+	if errors.Is(err, errCategoryNotFound) {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Parent category not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to validate parent category"))
+		return
+	}
+	if taken, err := h.nameTaken(ctx, input.Name, parentID, primitive.NilObjectID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to create category"))
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("A category with this name already exists here"))
+		return
+	}
+
+	now := time.Now()
+	category := models.Category{
+		ID:          primitive.NewObjectID(),
+		Name:        input.Name,
+		Description: input.Description,
+		ParentID:    parentID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := h.DB.Collection("categories").InsertOne(ctx, category); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, utils.ErrorResponse("A category with this name already exists here"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to create category"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse("Category created successfully", gin.H{
+		"category": category,
+	}))
+}
+
+// UpdateCategory renames a category or changes its description. Use
+// MoveCategory to change its parent.
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	categoryID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid category ID"))
+		return
+	}
+
+	var input categoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	categories := h.DB.Collection("categories")
+	var category models.Category
+	if err := categories.FindOne(ctx, bson.M{"_id": categoryID}).Decode(&category); err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Category not found"))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch category"))
+		return
+	}
+	if taken, err := h.nameTaken(ctx, input.Name, category.ParentID, categoryID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update category"))
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("A category with this name already exists here"))
+		return
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = categories.FindOneAndUpdate(ctx, bson.M{"_id": categoryID}, bson.M{"$set": bson.M{
+		"name":        input.Name,
+		"description": input.Description,
+		"updatedAt":   time.Now(),
+	}}, opts).Decode(&category)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update category"))
+		return
+	}
+
+	// Products keep a copy of the name for text search
+	if _, err := h.DB.Collection("products").UpdateMany(ctx, bson.M{"categoryId": categoryID}, bson.M{"$set": bson.M{
+		"categoryName": category.Name,
+	}}); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Category renamed but failed to update its products"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Category updated successfully", gin.H{
+		"category": category,
+	}))
+}
+
+// moveCategory reparents a category, refusing moves that would put it
+// inside its own subtree. A nil parent makes it a root category.
+func moveCategory(ctx context.Context, db *mongo.Database, categoryID primitive.ObjectID, parentID *primitive.ObjectID) (*models.Category, error) {
+	if parentID != nil {
+		subtree, err := categoryWithDescendants(ctx, db, categoryID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range subtree {
+			if id == *parentID {
+				return nil, errCategoryCycle
+			}
+		}
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var category models.Category
+	err := db.Collection("categories").FindOneAndUpdate(ctx, bson.M{"_id": categoryID}, bson.M{"$set": bson.M{
+		"parentId":  parentID,
+		"updatedAt": time.Now(),
+	}}, opts).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return nil, errCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// MoveCategory moves a category, with its subcategories, under another
+// parent. An empty parentId moves it to the top level.
+func (h *CategoryHandler) MoveCategory(c *gin.Context) {
+	categoryID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid category ID"))
+		return
+	}
+	var input struct {
+		ParentID string `json:"parentId"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	parentID, err := parseParent(ctx, h.DB, input.ParentID)
+	if errors.Is(err, errCategoryNotFound) {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Parent category not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to validate parent category"))
+		return
+	}
+
+	category, err := moveCategory(ctx, h.DB, categoryID, parentID)
+	switch {
+	case errors.Is(err, errCategoryNotFound):
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Category not found"))
+		return
+	case errors.Is(err, errCategoryCycle):
+		c.JSON(http.StatusUnprocessableEntity, utils.ErrorResponse(err.Error()))
+		return
+	case mongo.IsDuplicateKeyError(err):
+		c.JSON(http.StatusConflict, utils.ErrorResponse("A category with this name already exists there"))
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to move category"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Category moved successfully", gin.H{
+		"category": category,
+	}))
+}
+
+// DeleteCategory removes a category that has no subcategories. If products
+// still use it the request is refused unless ?moveTo names a category to
+// move them to first.
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	categoryID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid category ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	categories := h.DB.Collection("categories")
+	if count, err := categories.CountDocuments(ctx, bson.M{"_id": categoryID}); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch category"))
+		return
+	} else if count == 0 {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Category not found"))
+		return
+	}
+
+	children, err := categories.CountDocuments(ctx, bson.M{"parentId": categoryID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to check subcategories"))
+		return
+	}
+	if children > 0 {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Move or delete the subcategories first"))
+		return
+	}
+
+	// Soft deleted products are moved too so no product points at a missing category
+	products := h.DB.Collection("products")
+	productCount, err := products.CountDocuments(ctx, bson.M{"categoryId": categoryID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to check products"))
+		return
+	}
+
+	var moved int64
+	if productCount > 0 {
+		moveTo := c.Query("moveTo")
+		if moveTo == "" {
+			c.JSON(http.StatusConflict, utils.ErrorResponse(fmt.Sprintf("Category has %d products; pass moveTo to reassign them", productCount)))
+			return
+		}
+		target, err := resolveCategory(ctx, h.DB, moveTo)
+		if errors.Is(err, errCategoryNotFound) || (err == nil && target.ID == categoryID) {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("moveTo must be another existing category"))
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to validate moveTo category"))
+			return
+		}
+		res, err := products.UpdateMany(ctx, bson.M{"categoryId": categoryID}, bson.M{"$set": bson.M{
+			"categoryId":   target.ID,
+			"categoryName": target.Name,
+			"updatedAt":    time.Now(),
+		}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to move products"))
+			return
+		}
+		moved = res.ModifiedCount
+	}
+
+	if _, err := categories.DeleteOne(ctx, bson.M{"_id": categoryID}); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to delete category"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Category deleted successfully", gin.H{
+		"productsMoved": moved,
+	}))
+}
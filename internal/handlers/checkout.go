@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/internal/payments"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+)
+
+var (
+	errCartEmpty       = errors.New("cart is empty")
+	errCheckoutBlocked = errors.New("some items cannot be ordered")
+)
+
+type OrderHandler struct {
+	DB       *mongo.Database
+	Provider payments.PaymentProvider
+}
+
+func NewOrderHandler(db *mongo.Database, provider payments.PaymentProvider) *OrderHandler {
+	return &OrderHandler{DB: db, Provider: provider}
+}
+
+type checkoutInput struct {
+	ShippingAddress string `json:"shippingAddress" validate:"required,min=5,max=500"`
+}
+
+// checkoutIssue is a cart item that stopped the checkout, with the stock
+// that is left so the client can offer to adjust the quantity.
+type checkoutIssue struct {
+	ProductID primitive.ObjectID  `json:"productId"`
+	VariantID *primitive.ObjectID `json:"variantId,omitempty"`
+	Name      string              `json:"name"`
+	Reason    string              `json:"reason"`
+	Available int                 `json:"available"`
+}
+
+// findOrderByKey returns the order the user already placed with an
+// idempotency key, or nil.
+func findOrderByKey(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, key string) (*models.Order, error) {
+	var order models.Order
+	err := db.Collection("orders").FindOne(ctx, bson.M{"userId": userID, "idempotencyKey": key}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// priceOrderItems turns cart items into order items at the current price of
+// each product or variant. Items that are gone or short on stock are
+// returned as issues instead.
+func priceOrderItems(ctx context.Context, db *mongo.Database, items []models.CartItem) ([]models.OrderItem, []checkoutIssue, error) {
+	ids := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductID)
+	}
+	cursor, err := db.Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "deletedAt": notDeleted})
+	if err != nil {
+		return nil, nil, err
+	}
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, nil, err
+	}
+	byID := make(map[primitive.ObjectID]*models.Product, len(products))
+	for i := range products {
+		byID[products[i].ID] = &products[i]
+	}
+
+	orderItems := make([]models.OrderItem, 0, len(items))
+	var issues []checkoutIssue
+	for _, item := range items {
+		issue := checkoutIssue{ProductID: item.ProductID, VariantID: item.VariantID, Name: item.Name}
+		product, ok := byID[item.ProductID]
+		if !ok {
+			issue.Reason = "no longer available"
+			issues = append(issues, issue)
+			continue
+		}
+		price, stock, sku, err := sellable(product, item.VariantID)
+		if err != nil {
+			issue.Reason = "no longer available"
+			issues = append(issues, issue)
+			continue
+		}
+		if stock < item.Quantity {
+			issue.Reason, issue.Available = "not enough stock", max(stock, 0)
+			issues = append(issues, issue)
+			continue
+		}
+		orderItems = append(orderItems, models.OrderItem{
+			ProductID: product.ID,
+			VariantID: item.VariantID,
+			SKU:       sku,
+			VendorID:  product.VendorID,
+			Name:      product.Name,
+			Quantity:  item.Quantity,
+			Price:     price,
+		})
+	}
+	return orderItems, issues, nil
+}
+
+// withTransaction runs fn in a multi-document transaction. fn may be run
+// more than once on transient errors, so it must not keep state from a
+// previous attempt.
+func withTransaction(ctx context.Context, db *mongo.Database, fn func(sc mongo.SessionContext) error) error {
+	session, err := db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+// Checkout turns the user's cart into an order. Totals are recomputed from
+// live prices, and stock, vendor sales quotas, the order, its per-vendor
+// sub-orders and the emptied cart are written in one transaction, so an
+// oversold item leaves nothing behind. Clients send an Idempotency-Key
+// header; retrying with the same key returns the order placed by the first
+// attempt instead of a second order.
+func (h *OrderHandler) Checkout(c *gin.Context) {
+	key := strings.TrimSpace(c.GetHeader(idempotencyKeyHeader))
+	if key == "" || len(key) > maxIdempotencyKeyLen {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("An Idempotency-Key header of up to 255 characters is required"))
+		return
+	}
+	var input checkoutInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	input.ShippingAddress = strings.TrimSpace(input.ShippingAddress)
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	userID := middleware.UserID(c)
+	if existing, err := findOrderByKey(ctx, h.DB, userID, key); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to place order"))
+		return
+	} else if existing != nil {
+		c.JSON(http.StatusOK, utils.SuccessResponse("Order already placed", gin.H{"order": existing}))
+		return
+	}
+
+	var (
+		order     models.Order
+		issues    []checkoutIssue
+		accounts  []models.VendorAccount
+		subOrders []models.SubOrder
+	)
+	err := withTransaction(ctx, h.DB, func(sc mongo.SessionContext) error {
+		issues, accounts, subOrders = nil, nil, nil
+
+		store := cartStore{Collection: h.DB.Collection("carts"), Filter: bson.M{"userId": userID}}
+		cart, err := loadCart(sc, store)
+		if err != nil {
+			return err
+		}
+		if len(cart.Items) == 0 {
+			return errCartEmpty
+		}
+
+		items, found, err := priceOrderItems(sc, h.DB, cart.Items)
+		if err != nil {
+			return err
+		}
+		if issues = found; len(issues) > 0 {
+			return errCheckoutBlocked
+		}
+
+		for _, item := range items {
+			err := reserveStock(sc, h.DB, stockLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity})
+			if errors.Is(err, errOutOfStock) || errors.Is(err, errProductUnavailable) ||
+				errors.Is(err, errVariantRequired) || errors.Is(err, errVariantNotFound) {
+				issues = append(issues, checkoutIssue{ProductID: item.ProductID, VariantID: item.VariantID, Name: item.Name, Reason: err.Error()})
+				return errCheckoutBlocked
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		order = models.Order{
+			ID:              primitive.NewObjectID(),
+			UserID:          userID,
+			Items:           items,
+			Total:           models.OrderTotal(items),
+			Status:          models.OrderStatusPendingPayment,
+			ShippingAddress: input.ShippingAddress,
+			PaymentStatus:   models.PaymentStatusPending,
+			IdempotencyKey:  key,
+			StatusHistory: []models.OrderStatusChange{{
+				To:        models.OrderStatusPendingPayment,
+				ActorID:   &userID,
+				ActorRole: middleware.Role(c),
+				At:        now,
+			}},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if _, err := h.DB.Collection("orders").InsertOne(sc, order); err != nil {
+			return err
+		}
+
+		// Each vendor gets a sub-order to fulfil and the sale counted
+		// against their quota
+		subOrders = models.SplitOrder(&order)
+		docs := make([]interface{}, 0, len(subOrders))
+		for _, sub := range subOrders {
+			account, err := recordVendorSale(sc, h.DB, sub.VendorID, sub.Total)
+			if err != nil {
+				return err
+			}
+			accounts = append(accounts, *account)
+			docs = append(docs, sub)
+		}
+		if _, err := h.DB.Collection("sub_orders").InsertMany(sc, docs); err != nil {
+			return err
+		}
+
+		// Matching on the version makes a concurrent change to the cart
+		// abort the checkout instead of ordering a stale cart.
+		res, err := h.DB.Collection("carts").UpdateOne(sc, bson.M{"userId": userID, "version": cart.Version}, bson.M{
+			"$set": bson.M{"items": []models.CartItem{}, "updatedAt": now},
+			"$inc": bson.M{"version": 1},
+		})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return errCartConflict
+		}
+		return nil
+	})
+
+	switch {
+	case err == nil:
+	case mongo.IsDuplicateKeyError(err):
+		// A concurrent retry with the same key won the race
+		existing, findErr := findOrderByKey(ctx, h.DB, userID, key)
+		if findErr != nil || existing == nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to place order"))
+			return
+		}
+		c.JSON(http.StatusOK, utils.SuccessResponse("Order already placed", gin.H{"order": existing}))
+		return
+	case errors.Is(err, errCartEmpty):
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Your cart is empty"))
+		return
+	case errors.Is(err, errCheckoutBlocked):
+		c.JSON(http.StatusConflict, utils.Response{
+			Error: "Some items in your cart cannot be ordered",
+			Data:  gin.H{"issues": issues},
+		})
+		return
+	case errors.Is(err, errCartConflict):
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Your cart changed during checkout, review it and try again"))
+		return
+	case errors.Is(err, errSalesLimitReached), errors.Is(err, errVendorAccountNotFound), errors.Is(err, errVendorAccountInactive):
+		c.JSON(http.StatusConflict, utils.ErrorResponse("A store in your cart cannot take orders right now"))
+		return
+	default:
+		logrus.WithError(err).WithField("userId", userID.Hex()).Error("Checkout failed")
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to place order"))
+		return
+	}
+
+	for _, account := range accounts {
+		go warnOnLimitUsage(h.DB, account)
+	}
+	c.JSON(http.StatusCreated, utils.SuccessResponse("Order placed successfully", gin.H{"order": order, "subOrders": subOrders}))
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	guestCartTTL    = 30 * 24 * time.Hour
+	cartTokenHeader = "X-Cart-Token"
+)
+
+// guestCart resolves the visitor's cart from the X-Cart-Token header. A
+// visitor without a token, or whose token has expired along with its cart,
+// is given a new one for an empty cart. The token is re-signed on every
+// request so its expiry slides with the cart's, and echoed in the response
+// header.
+func (h *CartHandler) guestCart(c *gin.Context) (cartStore, bool) {
+	cartID := primitive.NewObjectID()
+	if header := c.GetHeader(cartTokenHeader); header != "" {
+		id, err := utils.ParseCartToken(header)
+		if err == nil {
+			cartID, err = primitive.ObjectIDFromHex(id)
+		}
+		// An expired token's cart has expired too, so the visitor starts over
+		if err != nil && !errors.Is(err, utils.ErrCartTokenExpired) {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid cart token"))
+			return cartStore{}, false
+		}
+	}
+
+	token, err := utils.SignCartToken(cartID.Hex(), time.Now().Add(guestCartTTL))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to create cart"))
+		return cartStore{}, false
+	}
+	c.Header(cartTokenHeader, token)
+	return cartStore{
+		Collection: h.DB.Collection("guest_carts"),
+		Filter:     bson.M{"_id": cartID},
+		TTL:        guestCartTTL,
+	}, true
+}
+
+// mergeGuestCart moves a visitor's cart into the user's cart after login or
+// registration. Quantities of the same item are added up, then capped at
+// the available stock by the usual refresh. It returns nil when there was
+// nothing to merge.
+func mergeGuestCart(ctx context.Context, db *mongo.Database, token string, userID primitive.ObjectID) (*cartView, error) {
+	if token == "" {
+		return nil, nil
+	}
+	id, err := utils.ParseCartToken(token)
+	if errors.Is(err, utils.ErrCartTokenExpired) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	guestID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	guestCarts := db.Collection("guest_carts")
+	guest, err := loadCart(ctx, cartStore{Collection: guestCarts, Filter: bson.M{"_id": guestID}})
+	if err != nil {
+		return nil, err
+	}
+	if len(guest.Items) == 0 {
+		return nil, nil
+	}
+
+	store := cartStore{Collection: db.Collection("carts"), Filter: bson.M{"userId": userID}}
+	// Retry once if the user's cart changes under us, e.g. a second tab
+	for attempt := 0; attempt < 2; attempt++ {
+		cart, err := loadCart(ctx, store)
+		if err != nil {
+			return nil, err
+		}
+		cart.Items = models.MergeCartItems(cart.Items, guest.Items, maxCartQuantity)
+		view, _, err := refreshCart(ctx, db, cart)
+		if err != nil {
+			return nil, err
+		}
+		err = saveCart(ctx, store, cart)
+		if errors.Is(err, errCartConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := guestCarts.DeleteOne(ctx, bson.M{"_id": guestID}); err != nil {
+			return nil, err
+		}
+		return &view, nil
+	}
+	return nil, errCartConflict
+}
+
+// attachMergedCart merges the guest cart named by the request's cart token
+// into the user's cart and adds the result to a login or registration
+// response. A failed merge is logged rather than failing the login.
+func (h *AuthHandler) attachMergedCart(ctx context.Context, c *gin.Context, userID primitive.ObjectID, response gin.H) {
+	view, err := mergeGuestCart(ctx, h.DB, c.GetHeader(cartTokenHeader), userID)
+	if err != nil {
+		logrus.WithError(err).WithField("userId", userID.Hex()).Warn("Failed to merge guest cart")
+		return
+	}
+	if view != nil {
+		response["cart"] = view
+	}
+}
+
+// GetGuestCart returns the visitor's cart with prices and stock refreshed.
+func (h *CartHandler) GetGuestCart(c *gin.Context) {
+	if store, ok := h.guestCart(c); ok {
+		h.getCart(c, store)
+	}
+}
+
+// AddGuestCartItem adds a product, or a variant of it, to the visitor's cart.
+func (h *CartHandler) AddGuestCartItem(c *gin.Context) {
+	if store, ok := h.guestCart(c); ok {
+		h.addItem(c, store)
+	}
+}
+
+// UpdateGuestCartItem sets the quantity of an item in the visitor's cart.
+func (h *CartHandler) UpdateGuestCartItem(c *gin.Context) {
+	if store, ok := h.guestCart(c); ok {
+		h.updateItem(c, store)
+	}
+}
+
+// RemoveGuestCartItem removes an item from the visitor's cart.
+func (h *CartHandler) RemoveGuestCartItem(c *gin.Context) {
+	if store, ok := h.guestCart(c); ok {
+		h.removeItem(c, store)
+	}
+}
+
+// ClearGuestCart empties the visitor's cart.
+func (h *CartHandler) ClearGuestCart(c *gin.Context) {
+	if store, ok := h.guestCart(c); ok {
+		h.clearCart(c, store)
+	}
+}
@@ -0,0 +1,402 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const statementDateLayout = "2006-01-02"
+
+var errLedgerUnbalanced = errors.New("ledger entry does not balance")
+
+// postLedgerEntries records entries, refusing any whose postings do not sum
+// to zero. ctx should be a transaction's session context.
+func postLedgerEntries(ctx context.Context, db *mongo.Database, entries ...models.LedgerEntry) error {
+	docs := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Balanced() {
+			return fmt.Errorf("%w: %s %v", errLedgerUnbalanced, entry.Type, entry.Postings)
+		}
+		if entry.ID.IsZero() {
+			entry.ID = primitive.NewObjectID()
+		}
+		docs = append(docs, entry)
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+	_, err := db.Collection("ledger_entries").InsertMany(ctx, docs)
+	return err
+}
+
+// postOrderSales credits each vendor of a paid order with their sub-order,
+// less the transaction fee of their tier, held for their payout hold.
+// ctx should be a transaction's session context.
+func postOrderSales(ctx context.Context, db *mongo.Database, orderID primitive.ObjectID, paidAt time.Time) error {
+	subOrders, err := loadSubOrders(ctx, db, orderID)
+	if err != nil {
+		return err
+	}
+	for i := range subOrders {
+		sub := &subOrders[i]
+		if sub.Status == models.OrderStatusCancelled {
+			continue
+		}
+		account, err := loadVendorAccount(ctx, db, sub.VendorID)
+		if err != nil {
+			return fmt.Errorf("failed to load vendor account %s: %w", sub.VendorID.Hex(), err)
+		}
+		if err := postLedgerEntries(ctx, db, models.SaleEntries(sub, account.TransactionFee, account.PayoutHoldDays, paidAt)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subOrderSale returns the sale and fee entries of a sub-order, either of
+// which is nil if it was never posted.
+func subOrderSale(ctx context.Context, db *mongo.Database, subOrderID primitive.ObjectID) (sale, fee *models.LedgerEntry, err error) {
+	cursor, err := db.Collection("ledger_entries").Find(ctx, bson.M{
+		"subOrderId": subOrderID,
+		"type":       bson.M{"$in": bson.A{models.LedgerEntrySale, models.LedgerEntryFee}},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var entries []models.LedgerEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, nil, err
+	}
+	for i := range entries {
+		switch entries[i].Type {
+		case models.LedgerEntrySale:
+			sale = &entries[i]
+		case models.LedgerEntryFee:
+			fee = &entries[i]
+		}
+	}
+	return sale, fee, nil
+}
+
+// postRefund debits a vendor for a refund the provider accepted. Sub-orders
+// paid before the ledger existed have no sale to hold, so their refunds
+// come out of the available balance. ctx should be a transaction's session
+// context.
+func postRefund(ctx context.Context, db *mongo.Database, refund *models.Refund) error {
+	sale, fee, err := subOrderSale(ctx, db, refund.SubOrderID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if sale != nil {
+		// Writing to the sale makes a release of it in another transaction
+		// conflict with this one, so whichever commits second retries and
+		// sees the other
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		if err := db.Collection("ledger_entries").FindOneAndUpdate(ctx, bson.M{"_id": sale.ID},
+			bson.M{"$set": bson.M{"refundedAt": now}}, opts).Decode(sale); err != nil {
+			return err
+		}
+	}
+	released := sale == nil || sale.ReleasedAt != nil
+	return postLedgerEntries(ctx, db, models.RefundEntry(refund, sale, fee, released, now))
+}
+
+// releaseSale moves what is left of a sale out of the payout hold. The sale
+// is marked released in the same transaction, and postRefund writes to the
+// sale too, so a refund racing the release conflicts with it and retries
+// rather than both reading the sale as it was. ctx should be a
+// transaction's session context.
+func releaseSale(ctx context.Context, db *mongo.Database, saleID primitive.ObjectID, now time.Time) error {
+	entries := db.Collection("ledger_entries")
+	var sale models.LedgerEntry
+	err := entries.FindOneAndUpdate(ctx,
+		bson.M{"_id": saleID, "releasedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"releasedAt": now}},
+	).Decode(&sale)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	cursor, err := entries.Find(ctx, bson.M{"subOrderId": sale.SubOrderID, "vendorId": sale.VendorID})
+	if err != nil {
+		return err
+	}
+	var related []models.LedgerEntry
+	if err := cursor.All(ctx, &related); err != nil {
+		return err
+	}
+	left := models.LedgerBalances(related)[models.VendorPendingAccount(sale.VendorID)]
+	if left <= 0 {
+		return nil
+	}
+	return postLedgerEntries(ctx, db, models.ReleaseEntry(&sale, left, now))
+}
+
+// ReleaseHeldFunds makes sales available for payout once their vendor's
+// payout hold has passed.
+func ReleaseHeldFunds(db *mongo.Database) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		now := time.Now()
+		opts := options.Find().SetSort(bson.D{{Key: "availableAt", Value: 1}}).SetLimit(500).SetProjection(bson.M{"_id": 1})
+		cursor, err := db.Collection("ledger_entries").Find(ctx, bson.M{
+			"type":        models.LedgerEntrySale,
+			"availableAt": bson.M{"$lte": now},
+			"releasedAt":  bson.M{"$exists": false},
+		}, opts)
+		if err != nil {
+			return err
+		}
+		var due []models.LedgerEntry
+		if err := cursor.All(ctx, &due); err != nil {
+			return err
+		}
+
+		released := 0
+		for _, sale := range due {
+			if err := withTransaction(ctx, db, func(sc mongo.SessionContext) error {
+				return releaseSale(sc, db, sale.ID, now)
+			}); err != nil {
+				logrus.WithError(err).WithField("entryId", sale.ID.Hex()).Error("Failed to release held funds")
+				continue
+			}
+			released++
+		}
+		if released > 0 {
+			logrus.WithField("sales", released).Info("Released held vendor funds")
+		}
+		return nil
+	}
+}
+
+// vendorBalances returns a vendor's pending and available balances.
+func vendorBalances(ctx context.Context, db *mongo.Database, vendorID primitive.ObjectID) (pending, available float64, err error) {
+	pendingAccount, availableAccount := models.VendorPendingAccount(vendorID), models.VendorAvailableAccount(vendorID)
+	cursor, err := db.Collection("ledger_entries").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"vendorId": vendorID}}},
+		{{Key: "$unwind", Value: "$postings"}},
+		{{Key: "$match", Value: bson.M{"postings.account": bson.M{"$in": bson.A{pendingAccount, availableAccount}}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$postings.account", "balance": bson.M{"$sum": "$postings.amount"}}}},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	var totals []struct {
+		Account string  `bson:"_id"`
+		Balance float64 `bson:"balance"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return 0, 0, err
+	}
+	for _, total := range totals {
+		switch total.Account {
+		case pendingAccount:
+			pending = models.RoundAmount(total.Balance)
+		case availableAccount:
+			available = models.RoundAmount(total.Balance)
+		}
+	}
+	return pending, available, nil
+}
+
+type WalletHandler struct {
+	DB *mongo.Database
+}
+
+func NewWalletHandler(db *mongo.Database) *WalletHandler {
+	return &WalletHandler{DB: db}
+}
+
+// statementLine is a ledger entry as the vendor sees it: how it moved their
+// pending and available balances.
+type statementLine struct {
+	ID          primitive.ObjectID  `json:"id"`
+	Type        string              `json:"type"`
+	Description string              `json:"description"`
+	OrderID     *primitive.ObjectID `json:"orderId,omitempty"`
+	Pending     float64             `json:"pending"`
+	Available   float64             `json:"available"`
+	CreatedAt   time.Time           `json:"createdAt"`
+}
+
+func newStatementLine(entry *models.LedgerEntry) statementLine {
+	return statementLine{
+		ID:          entry.ID,
+		Type:        entry.Type,
+		Description: entry.Description,
+		OrderID:     entry.OrderID,
+		Pending:     entry.AmountFor(models.VendorPendingAccount(entry.VendorID)),
+		Available:   entry.AmountFor(models.VendorAvailableAccount(entry.VendorID)),
+		CreatedAt:   entry.CreatedAt,
+	}
+}
+
+// statementFilter reads the optional from and to dates (inclusive, in
+// YYYY-MM-DD) of a statement request, writing the error response and
+// returning false if either is invalid.
+func statementFilter(c *gin.Context) (bson.M, bool) {
+	filter := bson.M{"vendorId": middleware.UserID(c)}
+	createdAt := bson.M{}
+	if raw := c.Query("from"); raw != "" {
+		from, err := time.Parse(statementDateLayout, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid from date, expected YYYY-MM-DD"))
+			return nil, false
+		}
+		createdAt["$gte"] = from
+	}
+	if raw := c.Query("to"); raw != "" {
+		to, err := time.Parse(statementDateLayout, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid to date, expected YYYY-MM-DD"))
+			return nil, false
+		}
+		createdAt["$lt"] = to.AddDate(0, 0, 1)
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+	return filter, true
+}
+
+// GetMyWallet returns the calling vendor's pending and available balances.
+func (h *WalletHandler) GetMyWallet(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	vendorID := middleware.UserID(c)
+	account, err := loadVendorAccount(ctx, h.DB, vendorID)
+	if errors.Is(err, errVendorAccountNotFound) {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Vendor account not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch wallet"))
+		return
+	}
+	pending, available, err := vendorBalances(ctx, h.DB, vendorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch wallet"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Wallet fetched successfully", gin.H{
+		"pending":        pending,
+		"available":      available,
+		"currency":       paymentCurrency(),
+		"transactionFee": account.TransactionFee,
+		"payoutHoldDays": account.PayoutHoldDays,
+		"payoutsBlocked": account.PayoutsBlocked(),
+	}))
+}
+
+// GetMyStatement lists the calling vendor's ledger entries, newest first.
+func (h *WalletHandler) GetMyStatement(c *gin.Context) {
+	filter, ok := statementFilter(c)
+	if !ok {
+		return
+	}
+	page, limit, skip := pageParams(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	entries := h.DB.Collection("ledger_entries")
+	total, err := entries.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch statement"))
+		return
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := entries.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch statement"))
+		return
+	}
+	var found []models.LedgerEntry
+	if err := cursor.All(ctx, &found); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch statement"))
+		return
+	}
+	lines := make([]statementLine, 0, len(found))
+	for i := range found {
+		lines = append(lines, newStatementLine(&found[i]))
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Statement fetched successfully", gin.H{
+		"entries": lines,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	}))
+}
+
+// ExportMyStatement streams the calling vendor's ledger entries as CSV,
+// oldest first.
+func (h *WalletHandler) ExportMyStatement(c *gin.Context) {
+	filter, ok := statementFilter(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Minute)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := h.DB.Collection("ledger_entries").Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to export statement"))
+		return
+	}
+	defer cursor.Close(ctx)
+
+	filename := "statement-" + time.Now().Format(statementDateLayout) + ".csv"
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"date", "type", "description", "order", "pending", "available"})
+	for cursor.Next(ctx) {
+		var entry models.LedgerEntry
+		if err := cursor.Decode(&entry); err != nil {
+			break
+		}
+		line := newStatementLine(&entry)
+		order := ""
+		if line.OrderID != nil {
+			order = line.OrderID.Hex()
+		}
+		_ = w.Write([]string{
+			line.CreatedAt.UTC().Format(time.RFC3339),
+			line.Type,
+			line.Description,
+			order,
+			strconv.FormatFloat(line.Pending, 'f', 2, 64),
+			strconv.FormatFloat(line.Available, 'f', 2, 64),
+		})
+	}
+	w.Flush()
+	if err := cursor.Err(); err != nil {
+		// Headers are already sent; all we can do is cut the file short
+		logrus.WithError(err).Error("Failed to export statement")
+	}
+}
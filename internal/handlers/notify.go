@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// notifyByEmail sends a simple templated email in the background. Failures
+// are only logged so they never affect the request that triggered them.
+// message is HTML and must already be escaped; greetingName is escaped here.
+func notifyByEmail(to, subject, greetingName, message string) {
+	emailBody := fmt.Sprintf(`
+    <html>
+    <body style="font-family: Arial, sans-serif;">
+        <h2>%s</h2>
+        <p>Hi %s,</p>
+        %s
+        <p>Best regards,<br>The Vendora Team</p>
+    </body>
+    </html>
+`, subject, html.EscapeString(greetingName), message)
+
+	go func() {
+		if err := utils.SendEmail(to, subject, emailBody); err != nil {
+			logrus.WithError(err).WithField("email", to).Errorf("Failed to send %q email", subject)
+		} else {
+			logrus.WithField("email", to).Infof("%q email sent successfully", subject)
+		}
+	}()
+}
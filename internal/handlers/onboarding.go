@@ -5,15 +5,19 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cloudinary/cloudinary-go/v2"
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
 	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/internal/risk"
 	"github.com/developia-II/ecommerce-backend/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -31,29 +35,12 @@ func NewOnboardingHandler(db *mongo.Database) *OnboardingHandler {
 var onboardingValidator = validator.New()
 
 func (h *OnboardingHandler) ClientUpdateInterest(c *gin.Context) {
-	authHeader := c.Request.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Missing or invalid token"))
-		return
-	}
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-	claims, err := utils.VerifyToken(tokenString)
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Missing or Invalid token"))
-		return
-	}
-	userId := claims.UserID
+	objectId := middleware.UserID(c)
 	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Second*10)
 	defer cancel()
 
 	var user models.User
 	collection := h.DB.Collection("users")
-	objectId, err := primitive.ObjectIDFromHex(userId)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
 	filter := bson.M{"_id": objectId}
 	if err := collection.FindOne(ctx, filter).Decode(&user); err != nil {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("No user found"))
@@ -69,6 +56,9 @@ func (h *OnboardingHandler) ClientUpdateInterest(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
 		return
 	}
+	if !checkCategories(ctx, c, h.DB, userInterest.Categories) {
+		return
+	}
 
 	// Initialize interests object if it's null, then set the fields
 	update := bson.M{
@@ -97,24 +87,7 @@ func (h *OnboardingHandler) ClientUpdateInterest(c *gin.Context) {
 }
 
 func (h *OnboardingHandler) ClientUpdatePreference(c *gin.Context) {
-	authHeader := c.Request.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	claims, err := utils.VerifyToken(tokenString)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to verify token"))
-		return
-	}
-
-	objectId, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid token"))
-		return
-	}
+	objectId := middleware.UserID(c)
 
 	var userPref models.UserPreferences
 
@@ -129,6 +102,9 @@ func (h *OnboardingHandler) ClientUpdatePreference(c *gin.Context) {
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
+	if !checkCategories(ctx, c, h.DB, userPref.Categories) {
+		return
+	}
 
 	collection := h.DB.Collection("users")
 
@@ -143,6 +119,7 @@ func (h *OnboardingHandler) ClientUpdatePreference(c *gin.Context) {
 	update := bson.M{
 		"$set": bson.M{
 			"preferences": bson.M{
+				"categories":        userPref.Categories,
 				"budgetRange":       userPref.BudgetRange,
 				"shoppingFrequency": userPref.ShoppingFrequency,
 				"specialPrefs":      userPref.SpecialPrefs,
@@ -161,6 +138,7 @@ func (h *OnboardingHandler) ClientUpdatePreference(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, utils.SuccessResponse("User preference updated successfully", gin.H{
+		"categories":        userPref.Categories,
 		"budgetRange":       userPref.BudgetRange,
 		"shoppingFrequency": userPref.ShoppingFrequency,
 		"specialPrefs":      userPref.SpecialPrefs,
@@ -169,19 +147,6 @@ func (h *OnboardingHandler) ClientUpdatePreference(c *gin.Context) {
 }
 
 func (h *OnboardingHandler) CompleteOnboardingFlow(c *gin.Context) {
-
-	authHeader := c.Request.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	claims, err := utils.VerifyToken(tokenString)
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or expired token"))
-		return
-	}
-
 	location := c.PostForm("location")
 	bio := c.PostForm("bio")
 	file, err := c.FormFile("profile_picture")
@@ -227,12 +192,7 @@ func (h *OnboardingHandler) CompleteOnboardingFlow(c *gin.Context) {
 		return
 	}
 
-	objectId, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid user ID"))
-		return
-	}
-
+	objectId := middleware.UserID(c)
 	collection := h.DB.Collection("users")
 	filter := bson.M{"_id": objectId}
 
@@ -271,25 +231,7 @@ func (h *OnboardingHandler) CompleteOnboardingFlow(c *gin.Context) {
 }
 
 func (h *OnboardingHandler) UserOnboardingDraft(c *gin.Context) {
-	authHeader := c.Request.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-	claims, err := utils.VerifyToken(tokenString)
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Failed to verify token"))
-		return
-	}
-	userId := claims.UserID
-
-	objectId, err := primitive.ObjectIDFromHex(userId)
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
+	objectId := middleware.UserID(c)
 	var input models.UserOnboardingDraft
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
@@ -303,6 +245,16 @@ func (h *OnboardingHandler) UserOnboardingDraft(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
+	if input.Role == "vendor" {
+		if submitted, err := h.sellerDraftSubmitted(ctx, objectId); err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch draft"))
+			return
+		} else if submitted {
+			c.JSON(http.StatusConflict, utils.ErrorResponse("Seller application already submitted"))
+			return
+		}
+	}
+
 	collection := h.DB.Collection("drafts")
 
 	filter := bson.M{"userID": objectId, "role": input.Role}
@@ -351,32 +303,18 @@ func (h *OnboardingHandler) UserOnboardingDraft(c *gin.Context) {
 }
 
 func (h *OnboardingHandler) GetOnboardingDraft(c *gin.Context) {
-	authHeader := c.Request.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
-	claims, err := utils.VerifyToken(strings.TrimPrefix(authHeader, "Bearer "))
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or expired token"))
-		return
-	}
 	role := c.Query("role")
 	if role == "" {
 		role = "customer"
 	}
 
-	objectId, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid user ID"))
-		return
-	}
+	objectId := middleware.UserID(c)
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 	defer cancel()
 
 	var draft models.UserOnboardingDraft
-	err = h.DB.Collection("drafts").FindOne(ctx, bson.M{
+	err := h.DB.Collection("drafts").FindOne(ctx, bson.M{
 		"userID": objectId,
 		"role":   role,
 	}).Decode(&draft)
@@ -405,16 +343,6 @@ func (h *OnboardingHandler) GetOnboardingDraft(c *gin.Context) {
 }
 
 func (h *OnboardingHandler) SellerBusinessType(c *gin.Context) {
-	auth := c.GetHeader("Authorization")
-	if !strings.HasPrefix(auth, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
-	claims, err := utils.VerifyToken(strings.TrimPrefix(auth, "Bearer "))
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
 
 	var input models.SellerBusinessInfo
 
@@ -427,9 +355,16 @@ func (h *OnboardingHandler) SellerBusinessType(c *gin.Context) {
 		return
 	}
 
-	userID, _ := primitive.ObjectIDFromHex(claims.UserID)
+	userID := middleware.UserID(c)
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
+	if submitted, err := h.sellerDraftSubmitted(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch draft"))
+		return
+	} else if submitted {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Seller application already submitted"))
+		return
+	}
 
 	// Save to drafts (role=vendor)
 	filter := bson.M{"userID": userID, "role": "vendor"}
@@ -456,16 +391,6 @@ func (h *OnboardingHandler) SellerBusinessType(c *gin.Context) {
 }
 
 func (h *OnboardingHandler) SellerBusinessCategory(c *gin.Context) {
-	auth := c.GetHeader("Authorization")
-	if !strings.HasPrefix(auth, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
-	claims, err := utils.VerifyToken(strings.TrimPrefix(auth, "Bearer "))
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
 
 	type categoryInput struct {
 		Categories []string `json:"categories" validate:"required,min=1,max=5,dive,required"`
@@ -480,9 +405,19 @@ func (h *OnboardingHandler) SellerBusinessCategory(c *gin.Context) {
 		return
 	}
 
-	userID, _ := primitive.ObjectIDFromHex(claims.UserID)
+	userID := middleware.UserID(c)
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
+	if !checkCategories(ctx, c, h.DB, input.Categories) {
+		return
+	}
+	if submitted, err := h.sellerDraftSubmitted(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch draft"))
+		return
+	} else if submitted {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Seller application already submitted"))
+		return
+	}
 
 	filter := bson.M{"userID": userID, "role": "vendor"}
 	update := bson.M{
@@ -508,16 +443,6 @@ func (h *OnboardingHandler) SellerBusinessCategory(c *gin.Context) {
 }
 
 func (h *OnboardingHandler) SellerBusinessInfo(c *gin.Context) {
-	auth := c.GetHeader("Authorization")
-	if !strings.HasPrefix(auth, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
-	claims, err := utils.VerifyToken(strings.TrimPrefix(auth, "Bearer "))
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
-		return
-	}
 	var input models.BusinessDetails
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid json payload"))
@@ -529,9 +454,16 @@ func (h *OnboardingHandler) SellerBusinessInfo(c *gin.Context) {
 		fmt.Println("Error", err)
 		return
 	}
-	userID, _ := primitive.ObjectIDFromHex(claims.UserID)
+	userID := middleware.UserID(c)
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
+	if submitted, err := h.sellerDraftSubmitted(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch draft"))
+		return
+	} else if submitted {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Seller application already submitted"))
+		return
+	}
 
 	filter := bson.M{
 		"userID": userID,
@@ -568,20 +500,16 @@ func (h *OnboardingHandler) SellerBusinessInfo(c *gin.Context) {
 }
 
 func (h *OnboardingHandler) StoreDetails(c *gin.Context) {
-	authHeader := c.Request.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or missing token"))
+	userID := middleware.UserID(c)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	if submitted, err := h.sellerDraftSubmitted(ctx, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch draft"))
 		return
-	}
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	claims, err := utils.VerifyToken(tokenString)
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or expired token"))
+	} else if submitted {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Seller application already submitted"))
 		return
 	}
-	userID, _ := primitive.ObjectIDFromHex(claims.UserID)
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
-	defer cancel()
 
 	storeName := c.PostForm("storeName")
 	storeDescription := c.PostForm("storeDescription")
@@ -663,3 +591,223 @@ func (h *OnboardingHandler) StoreDetails(c *gin.Context) {
 		},
 	}))
 }
+
+type sellerSubmitInput struct {
+	RequestedTier string                   `json:"requestedTier"`
+	TermsAccepted bool                     `json:"termsAccepted"`
+	TaxID         string                   `json:"taxId"`
+	SocialMedia   []models.SocialMediaLink `json:"socialMedia"`
+}
+
+// SubmitSellerApplication turns the vendor onboarding draft into a
+// SellerApplication awaiting review. The draft is kept, marked as submitted,
+// for audit purposes.
+func (h *OnboardingHandler) SubmitSellerApplication(c *gin.Context) {
+	userID := middleware.UserID(c)
+
+	var input sellerSubmitInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid json format"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	drafts := h.DB.Collection("drafts")
+	var draft models.SellerDraft
+	err := drafts.FindOne(ctx, bson.M{"userID": userID, "role": "vendor"}).Decode(&draft)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("No seller onboarding draft found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch draft"))
+		return
+	}
+
+	applications := h.DB.Collection("seller_applications")
+	claim := bson.M{"_id": draft.ID, "submittedAt": bson.M{"$exists": false}}
+	if draft.SubmittedAt != nil {
+		// Drafts approved before approval unlocked them are still marked as
+		// submitted; once their application is decided they can be reused
+		var previous models.SellerApplication
+		err := applications.FindOne(ctx, bson.M{"_id": draft.ApplicationID}).Decode(&previous)
+		if err != nil && err != mongo.ErrNoDocuments {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to check existing applications"))
+			return
+		}
+		if err == nil && previous.Status != models.ApplicationStatusApproved && previous.Status != models.ApplicationStatusRejected {
+			c.JSON(http.StatusConflict, utils.ErrorResponse("Seller application already submitted"))
+			return
+		}
+		claim = bson.M{"_id": draft.ID, "applicationID": draft.ApplicationID}
+	}
+
+	open, err := applications.CountDocuments(ctx, bson.M{
+		"userID": userID,
+		"status": bson.M{"$in": []string{models.ApplicationStatusPending, models.ApplicationStatusUnderReview}},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to check existing applications"))
+		return
+	}
+	if open > 0 {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("You already have an application under review"))
+		return
+	}
+
+	data := draft.StepData
+	var missingSteps []string
+	if data.BusinessInfo == nil {
+		missingSteps = append(missingSteps, "business-type")
+	}
+	if len(data.Categories) == 0 {
+		missingSteps = append(missingSteps, "business-category")
+	}
+	if data.BusinessDetails == nil {
+		missingSteps = append(missingSteps, "business-details")
+	}
+	if data.StoreDetails == nil {
+		missingSteps = append(missingSteps, "store-details")
+	}
+	if len(missingSteps) > 0 {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Incomplete onboarding, missing steps: "+strings.Join(missingSteps, ", ")))
+		return
+	}
+	// Categories may have been removed since the step was saved
+	if !checkCategories(ctx, c, h.DB, data.Categories) {
+		return
+	}
+
+	now := time.Now()
+	application := models.SellerApplication{
+		ID:                 primitive.NewObjectID(),
+		UserID:             userID,
+		RequestedTier:      input.RequestedTier,
+		BusinessTypeInfo:   data.BusinessInfo,
+		IsRegistered:       data.BusinessInfo.BusinessType != "unregistered",
+		StoreName:          data.StoreDetails.StoreName,
+		StoreDescription:   data.StoreDetails.StoreDescription,
+		Categories:         data.Categories,
+		BusinessDetails:    data.BusinessDetails,
+		StoreDetails:       data.StoreDetails,
+		IDDocument:         data.Documents.IDDocument,
+		SelfieVerification: data.Documents.SelfieVerification,
+		ProofOfActivity:    data.Documents.ProofOfActivity,
+		AddressProof:       data.Documents.AddressProof,
+		BusinessDocuments:  data.Documents.BusinessDocuments,
+		TaxID:              input.TaxID,
+		SocialMedia:        input.SocialMedia,
+		TermsAccepted:      input.TermsAccepted,
+		TermsAcceptedAt:    now,
+		Status:             models.ApplicationStatusPending,
+		AppliedAt:          now,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+		Version:            1,
+	}
+	if err := onboardingValidator.Struct(&application); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	if missing := application.MissingDocuments(); len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Missing documents for "+application.RequestedTier+" tier: "+strings.Join(missing, ", ")))
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("User not found"))
+		return
+	}
+	// Vendors re-applying through onboarding can only ask for a higher tier
+	var account models.VendorAccount
+	err = h.DB.Collection("vendor_accounts").FindOne(ctx, bson.M{"userID": userID}).Decode(&account)
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch vendor account"))
+		return
+	}
+	if err == nil && !models.IsTierUpgrade(account.Tier, application.RequestedTier) {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Your account is already on the "+account.Tier+" tier, request a higher tier to upgrade"))
+		return
+	}
+
+	score, flags := risk.NewEngine(risk.DefaultRules(h.DB)...).Score(ctx, risk.Subject{Application: &application, User: &user})
+	application.RiskScore = score
+	for _, flag := range flags {
+		application.RiskFlags = append(application.RiskFlags, flag.String())
+	}
+
+	// Claim the draft first so a double submit cannot create two applications
+	res, err := drafts.UpdateOne(ctx, claim, bson.M{"$set": bson.M{
+		"submittedAt":   now,
+		"applicationID": application.ID,
+		"stepCompleted": true,
+	}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to submit application"))
+		return
+	}
+	if res.ModifiedCount == 0 {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Seller application already submitted"))
+		return
+	}
+
+	if _, err := applications.InsertOne(ctx, application); err != nil {
+		_, _ = drafts.UpdateOne(ctx, bson.M{"_id": draft.ID}, bson.M{"$unset": bson.M{"submittedAt": "", "applicationID": ""}})
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to submit application"))
+		return
+	}
+
+	// Existing vendors applying for a higher tier keep their approved status
+	if _, err := h.DB.Collection("users").UpdateOne(ctx, bson.M{
+		"_id":  userID,
+		"role": bson.M{"$ne": models.RoleVendor},
+	}, bson.M{"$set": bson.M{"vendorStatus": "pending", "updatedAt": now}}); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update user status"))
+		return
+	}
+
+	if shouldAutoApprove(&application) {
+		approved, err := transitionSellerApplication(ctx, h.DB, application.ID, sellerTransition{
+			To:      models.ApplicationStatusApproved,
+			Version: application.Version,
+			Notes:   fmt.Sprintf("Automatically approved with risk score %d", application.RiskScore),
+		})
+		if err != nil {
+			logrus.WithError(err).WithField("applicationId", application.ID.Hex()).Error("Failed to auto-approve seller application")
+		}
+		if approved != nil {
+			application = *approved
+		}
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse("Seller application submitted successfully", gin.H{
+		"applicationId": application.ID.Hex(),
+		"requestedTier": application.RequestedTier,
+		"status":        application.Status,
+	}))
+}
+
+// sellerDraftSubmitted reports whether the user's seller draft has already
+// been turned into an application, in which case it is read-only.
+func (h *OnboardingHandler) sellerDraftSubmitted(ctx context.Context, userID primitive.ObjectID) (bool, error) {
+	count, err := h.DB.Collection("drafts").CountDocuments(ctx, bson.M{
+		"userID":      userID,
+		"role":        "vendor",
+		"submittedAt": bson.M{"$exists": true},
+	})
+	return count > 0, err
+}
+
+// shouldAutoApprove reports whether an application is low-risk enough to skip
+// manual review. Only individual-tier applications qualify, and only when
+// SELLER_AUTO_APPROVE_MAX_RISK is set.
+func shouldAutoApprove(app *models.SellerApplication) bool {
+	maxRisk, err := strconv.Atoi(os.Getenv("SELLER_AUTO_APPROVE_MAX_RISK"))
+	if err != nil {
+		return false
+	}
+	return app.RequestedTier == models.TierIndividual && app.RiskScore <= maxRisk
+}
@@ -0,0 +1,559 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	errOrderNotFound = errors.New("order not found")
+	errOrderConflict = errors.New("order was modified concurrently")
+	errOrderPaid     = errors.New("order has been paid for; refund it instead")
+)
+
+type cancelOrderInput struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// orderStatusInput leaves out paid: only a settled payment moves an order
+// from pending_payment to paid.
+type orderStatusInput struct {
+	Status string `json:"status" validate:"required,oneof=processing shipped delivered cancelled"`
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// orderTransition describes a requested status change. ActorID is nil for
+// system actions.
+type orderTransition struct {
+	To        string
+	ActorID   *primitive.ObjectID
+	ActorRole string
+	Reason    string
+
+	// Set by the vendor when a sub-order ships
+	Carrier        string
+	TrackingNumber string
+}
+
+func (t orderTransition) change(from string, at time.Time) models.OrderStatusChange {
+	return models.OrderStatusChange{
+		From:      from,
+		To:        t.To,
+		ActorID:   t.ActorID,
+		ActorRole: t.ActorRole,
+		Reason:    t.Reason,
+		At:        at,
+	}
+}
+
+type vendorOrderStatusInput struct {
+	Status         string `json:"status" validate:"required,oneof=processing shipped delivered"`
+	Carrier        string `json:"carrier" validate:"required_if=Status shipped,max=100"`
+	TrackingNumber string `json:"trackingNumber" validate:"required_if=Status shipped,max=100"`
+	Reason         string `json:"reason" validate:"max=500"`
+}
+
+// transitionOrder moves a whole order along the lifecycle by moving each of
+// its sub-orders, then brings the parent's status and timeline in line.
+// Sub-orders that are already there, or were cancelled or refunded, are
+// left alone; if any other sub-order cannot make the move, nothing changes.
+// ctx should be a transaction's session context so the sub-orders, the
+// parent and any restocking commit together.
+func transitionOrder(ctx context.Context, db *mongo.Database, orderID primitive.ObjectID, t orderTransition) (*models.Order, error) {
+	var current models.Order
+	if err := db.Collection("orders").FindOne(ctx, bson.M{"_id": orderID}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errOrderNotFound
+		}
+		return nil, err
+	}
+	if !models.CanTransitionOrder(current.Status, t.To) {
+		return nil, fmt.Errorf("%w: %s -> %s", errIllegalTransition, current.Status, t.To)
+	}
+	// Cancelling only puts stock back; money taken has to go through a refund
+	if t.To == models.OrderStatusCancelled && current.PaymentStatus != models.PaymentStatusPending && current.PaymentStatus != models.PaymentStatusFailed {
+		return nil, errOrderPaid
+	}
+
+	subOrders, err := loadSubOrders(ctx, db, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range subOrders {
+		sub := &subOrders[i]
+		if sub.Status == t.To || models.OrderStatusFinal(sub.Status) {
+			continue
+		}
+		if _, err := transitionSubOrder(ctx, db, sub, t); err != nil {
+			return nil, err
+		}
+	}
+	return syncParentOrder(ctx, db, orderID, t)
+}
+
+// transitionSubOrder moves one vendor's sub-order along the lifecycle and
+// appends the change to its timeline. The update matches on the status that
+// was checked, so a concurrent transition fails with errOrderConflict rather
+// than skipping a state. Cancelling puts the items back in stock and
+// reverses the vendor's sale. The caller syncs the parent order.
+func transitionSubOrder(ctx context.Context, db *mongo.Database, sub *models.SubOrder, t orderTransition) (*models.SubOrder, error) {
+	if !models.CanTransitionOrder(sub.Status, t.To) {
+		return nil, fmt.Errorf("%w: sub-order %s is %s", errIllegalTransition, sub.ID.Hex(), sub.Status)
+	}
+
+	now := time.Now()
+	set := bson.M{"status": t.To, "updatedAt": now}
+	switch t.To {
+	case models.OrderStatusShipped:
+		set["shipping.carrier"] = t.Carrier
+		set["shipping.trackingNumber"] = t.TrackingNumber
+		set["shipping.shippedAt"] = now
+	case models.OrderStatusDelivered:
+		set["shipping.deliveredAt"] = now
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated models.SubOrder
+	err := db.Collection("sub_orders").FindOneAndUpdate(ctx, bson.M{"_id": sub.ID, "status": sub.Status}, bson.M{
+		"$set":  set,
+		"$push": bson.M{"statusHistory": t.change(sub.Status, now)},
+	}, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, errOrderConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.To == models.OrderStatusCancelled {
+		if err := restockSubOrder(ctx, db, &updated); err != nil {
+			return nil, err
+		}
+	}
+	return &updated, nil
+}
+
+// syncParentOrder sets the parent order's status to the one derived from
+// its sub-orders, recording the change with the actor that caused it.
+func syncParentOrder(ctx context.Context, db *mongo.Database, orderID primitive.ObjectID, t orderTransition) (*models.Order, error) {
+	subOrders, err := loadSubOrders(ctx, db, orderID)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]string, 0, len(subOrders))
+	for _, sub := range subOrders {
+		statuses = append(statuses, sub.Status)
+	}
+
+	orders := db.Collection("orders")
+	var current models.Order
+	if err := orders.FindOne(ctx, bson.M{"_id": orderID}).Decode(&current); err != nil {
+		return nil, err
+	}
+	// Orders without sub-orders keep their own status
+	status := models.ParentOrderStatus(statuses)
+	if status == "" || status == current.Status {
+		return &current, nil
+	}
+
+	now := time.Now()
+	t.To = status
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated models.Order
+	err = orders.FindOneAndUpdate(ctx, bson.M{"_id": orderID, "status": current.Status}, bson.M{
+		"$set":  bson.M{"status": status, "updatedAt": now},
+		"$push": bson.M{"statusHistory": t.change(current.Status, now)},
+	}, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, errOrderConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func loadSubOrders(ctx context.Context, db *mongo.Database, orderID primitive.ObjectID) ([]models.SubOrder, error) {
+	cursor, err := db.Collection("sub_orders").Find(ctx, bson.M{"orderId": orderID}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	subOrders := []models.SubOrder{}
+	if err := cursor.All(ctx, &subOrders); err != nil {
+		return nil, err
+	}
+	return subOrders, nil
+}
+
+// restockSubOrder returns a sub-order's items to stock and takes the sale
+// off the vendor's totals.
+func restockSubOrder(ctx context.Context, db *mongo.Database, sub *models.SubOrder) error {
+	for _, item := range sub.Items {
+		if err := releaseStock(ctx, db, stockLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}); err != nil {
+			return err
+		}
+	}
+	return reverseVendorSale(ctx, db, sub.VendorID, sub.Total, sub.CreatedAt, true)
+}
+
+// respondTransitionError writes the response for a failed transitionOrder.
+func respondTransitionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Order not found"))
+	case errors.Is(err, errIllegalTransition):
+		c.JSON(http.StatusUnprocessableEntity, utils.ErrorResponse(err.Error()))
+	case errors.Is(err, errOrderConflict):
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Order was updated by someone else, reload and try again"))
+	case errors.Is(err, errOrderPaid):
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Order has been paid for, refund it instead"))
+	default:
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update order"))
+	}
+}
+
+func orderIDParam(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid order ID"))
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+// listOrders returns one page of orders matching filter, newest first.
+func (h *OrderHandler) listOrders(ctx context.Context, filter bson.M, skip, limit int64) ([]models.Order, int64, error) {
+	total, err := h.DB.Collection("orders").CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := h.DB.Collection("orders").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	orders := []models.Order{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+// ListMyOrders returns the customer's orders, newest first.
+func (h *OrderHandler) ListMyOrders(c *gin.Context) {
+	page, limit, skip := pageParams(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	orders, total, err := h.listOrders(ctx, bson.M{"userId": middleware.UserID(c)}, skip, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch orders"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Orders fetched successfully", gin.H{
+		"orders": orders,
+		"page":   page,
+		"limit":  limit,
+		"total":  total,
+	}))
+}
+
+// GetMyOrder returns one of the customer's orders with its timeline and
+// the per-vendor sub-orders it was split into.
+func (h *OrderHandler) GetMyOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var order models.Order
+	err := h.DB.Collection("orders").FindOne(ctx, bson.M{"_id": orderID, "userId": middleware.UserID(c)}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Order not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch order"))
+		return
+	}
+	subOrders, err := loadSubOrders(ctx, h.DB, order.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch order"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Order fetched successfully", gin.H{"order": order, "subOrders": subOrders}))
+}
+
+// CancelMyOrder lets a customer cancel an order that has not shipped yet.
+// The items go back into stock, and a paid order is refunded.
+func (h *OrderHandler) CancelMyOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+	var input cancelOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	userID := middleware.UserID(c)
+	var order *models.Order
+	err := withTransaction(ctx, h.DB, func(sc mongo.SessionContext) error {
+		// Only the customer's own orders, and only before shipment
+		var current models.Order
+		if err := h.DB.Collection("orders").FindOne(sc, bson.M{"_id": orderID, "userId": userID}).Decode(&current); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return errOrderNotFound
+			}
+			return err
+		}
+		if !models.OrderCancellable(current.Status) {
+			return fmt.Errorf("%w: order is already %s", errIllegalTransition, current.Status)
+		}
+
+		updated, err := transitionOrder(sc, h.DB, orderID, orderTransition{
+			To:        models.OrderStatusCancelled,
+			ActorID:   &userID,
+			ActorRole: middleware.Role(c),
+			Reason:    input.Reason,
+		})
+		order = updated
+		return err
+	})
+	if errors.Is(err, errOrderPaid) {
+		h.cancelPaidOrder(ctx, c, orderID, input.Reason)
+		return
+	}
+	if err != nil {
+		respondTransitionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Order cancelled successfully", gin.H{"order": order}))
+}
+
+// cancelPaidOrder cancels an order the customer already paid for by
+// refunding it in full, as long as no vendor has shipped their part.
+func (h *OrderHandler) cancelPaidOrder(ctx context.Context, c *gin.Context, orderID primitive.ObjectID, reason string) {
+	subOrders, err := loadSubOrders(ctx, h.DB, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to cancel order"))
+		return
+	}
+	for _, sub := range subOrders {
+		if !models.OrderStatusFinal(sub.Status) && !models.OrderCancellable(sub.Status) {
+			c.JSON(http.StatusUnprocessableEntity, utils.ErrorResponse("Part of this order has already shipped"))
+			return
+		}
+	}
+
+	if reason == "" {
+		reason = "Cancelled by the customer"
+	}
+	req := refundRequest{
+		Reason:    reason,
+		Restock:   true,
+		ActorID:   middleware.UserID(c),
+		ActorRole: middleware.Role(c),
+	}
+	var refunds []*models.Refund
+	for _, sub := range subOrders {
+		if !models.OrderRefundable(sub.Status) {
+			continue
+		}
+		refund, err := refundSubOrder(ctx, h.DB, h.Provider, sub.ID, req)
+		if errors.Is(err, errNothingToRefund) {
+			continue
+		}
+		if err != nil {
+			respondRefundError(c, err, refunds)
+			return
+		}
+		refunds = append(refunds, refund)
+	}
+
+	var order models.Order
+	if err := h.DB.Collection("orders").FindOne(ctx, bson.M{"_id": orderID}).Decode(&order); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch order"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Order cancelled and refunded successfully", gin.H{"order": order, "refunds": refunds}))
+}
+
+// ListVendorOrders returns the calling vendor's sub-orders, newest first.
+// ?status= narrows them down, e.g. to those waiting to ship.
+func (h *OrderHandler) ListVendorOrders(c *gin.Context) {
+	page, limit, skip := pageParams(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"vendorId": middleware.UserID(c)}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	subOrders := h.DB.Collection("sub_orders")
+	total, err := subOrders.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch orders"))
+		return
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := subOrders.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch orders"))
+		return
+	}
+	orders := []models.SubOrder{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch orders"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Orders fetched successfully", gin.H{
+		"orders": orders,
+		"page":   page,
+		"limit":  limit,
+		"total":  total,
+	}))
+}
+
+// loadVendorSubOrder fetches one of the calling vendor's sub-orders,
+// writing the error response and returning nil if it cannot.
+func (h *OrderHandler) loadVendorSubOrder(ctx context.Context, c *gin.Context) *models.SubOrder {
+	subOrderID, ok := orderIDParam(c)
+	if !ok {
+		return nil
+	}
+	var sub models.SubOrder
+	err := h.DB.Collection("sub_orders").FindOne(ctx, bson.M{"_id": subOrderID, "vendorId": middleware.UserID(c)}).Decode(&sub)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Order not found"))
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch order"))
+		return nil
+	}
+	return &sub
+}
+
+// GetVendorOrder returns one of the calling vendor's sub-orders with its
+// timeline.
+func (h *OrderHandler) GetVendorOrder(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if sub := h.loadVendorSubOrder(ctx, c); sub != nil {
+		c.JSON(http.StatusOK, utils.SuccessResponse("Order fetched successfully", gin.H{"order": sub}))
+	}
+}
+
+// UpdateVendorOrderStatus lets a vendor fulfil their sub-order: start
+// processing it, ship it with a carrier and tracking number, and mark it
+// delivered. The parent order follows once every vendor has caught up.
+func (h *OrderHandler) UpdateVendorOrderStatus(c *gin.Context) {
+	var input vendorOrderStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	sub := h.loadVendorSubOrder(ctx, c)
+	if sub == nil {
+		return
+	}
+
+	vendorID := middleware.UserID(c)
+	t := orderTransition{
+		To:             input.Status,
+		ActorID:        &vendorID,
+		ActorRole:      middleware.Role(c),
+		Reason:         input.Reason,
+		Carrier:        input.Carrier,
+		TrackingNumber: input.TrackingNumber,
+	}
+	var updated *models.SubOrder
+	err := withTransaction(ctx, h.DB, func(sc mongo.SessionContext) error {
+		var err error
+		if updated, err = transitionSubOrder(sc, h.DB, sub, t); err != nil {
+			return err
+		}
+		_, err = syncParentOrder(sc, h.DB, sub.OrderID, t)
+		return err
+	})
+	if err != nil {
+		respondTransitionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Order updated successfully", gin.H{"order": updated}))
+}
+
+// TransitionOrder lets an admin move an order to any status the lifecycle
+// allows from its current one, except paid.
+func (h *OrderHandler) TransitionOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+	var input orderStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := reviewValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	adminID := middleware.UserID(c)
+	var order *models.Order
+	err := withTransaction(ctx, h.DB, func(sc mongo.SessionContext) error {
+		updated, err := transitionOrder(sc, h.DB, orderID, orderTransition{
+			To:        input.Status,
+			ActorID:   &adminID,
+			ActorRole: middleware.Role(c),
+			Reason:    input.Reason,
+		})
+		order = updated
+		return err
+	})
+	if err != nil {
+		respondTransitionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Order updated successfully", gin.H{"order": order}))
+}
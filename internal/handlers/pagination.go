@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageParams reads the page and limit query parameters, falling back to
+// sane defaults. It returns the limit and the number of documents to skip.
+func pageParams(c *gin.Context) (page, limit, skip int64) {
+	page, err := strconv.ParseInt(c.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err = strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultPageSize)), 10, 64)
+	if err != nil || limit < 1 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return page, limit, (page - 1) * limit
+}
+
+// limitParam reads the limit query parameter for cursor paginated endpoints.
+func limitParam(c *gin.Context) int64 {
+	_, limit, _ := pageParams(c)
+	return limit
+}
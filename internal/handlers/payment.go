@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/internal/payments"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxWebhookBody = 1 << 20
+	// A started payment is reused for this long before a new one is started
+	paymentReuseWindow = 30 * time.Minute
+	// Payments still pending after this long are given up as abandoned
+	paymentAbandonAfter = 24 * time.Hour
+	// Reconciliation leaves payments alone for this long after creation and
+	// after each check, giving the customer and the webhook time
+	paymentCheckInterval = 10 * time.Minute
+)
+
+var (
+	errPaymentNotFound = errors.New("payment not found")
+	errPaymentConflict = errors.New("payment was settled concurrently")
+)
+
+type PaymentHandler struct {
+	DB       *mongo.Database
+	Provider payments.PaymentProvider
+}
+
+func NewPaymentHandler(db *mongo.Database, provider payments.PaymentProvider) *PaymentHandler {
+	return &PaymentHandler{DB: db, Provider: provider}
+}
+
+// unpaidOrderTTL is how long an order may wait for payment before it is
+// cancelled and its stock released, set with UNPAID_ORDER_TTL, e.g. "72h".
+// It defaults to two days, past the point pending payments are abandoned.
+func unpaidOrderTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("UNPAID_ORDER_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return 2 * paymentAbandonAfter
+}
+
+func paymentCurrency() string {
+	if currency := os.Getenv("PAYMENT_CURRENCY"); currency != "" {
+		return currency
+	}
+	return "NGN"
+}
+
+// PayOrder starts paying for one of the customer's orders and returns the
+// provider page to complete the payment on. A payment started recently is
+// returned again rather than starting a second one, and no new payment is
+// started while an older one is still pending.
+func (h *PaymentHandler) PayOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+	defer cancel()
+
+	userID := middleware.UserID(c)
+	var order models.Order
+	err := h.DB.Collection("orders").FindOne(ctx, bson.M{"_id": orderID, "userId": userID}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Order not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch order"))
+		return
+	}
+	if order.Status != models.OrderStatusPendingPayment {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Order is already "+order.Status))
+		return
+	}
+
+	// A second payment while one is still open could charge the customer
+	// twice, so a recent one is handed back and an older one has to be
+	// settled by the provider or reconciliation first
+	paymentsColl := h.DB.Collection("payments")
+	var open models.Payment
+	err = paymentsColl.FindOne(ctx, bson.M{
+		"orderId": order.ID,
+		"status":  models.PaymentStatusPending,
+	}, options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})).Decode(&open)
+	if err == nil {
+		if time.Since(open.CreatedAt) < paymentReuseWindow {
+			c.JSON(http.StatusOK, utils.SuccessResponse("Payment already started", gin.H{"payment": open}))
+			return
+		}
+		c.JSON(http.StatusConflict, utils.ErrorResponse("A previous payment for this order is still being processed, try again shortly"))
+		return
+	}
+	if err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to start payment"))
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to start payment"))
+		return
+	}
+
+	now := time.Now()
+	payment := models.Payment{
+		OrderID:   order.ID,
+		UserID:    userID,
+		Provider:  h.Provider.Name(),
+		Reference: fmt.Sprintf("%s-%d", order.ID.Hex(), now.UnixNano()),
+		Amount:    order.Total,
+		Currency:  paymentCurrency(),
+		Status:    models.PaymentStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	charge, err := h.Provider.Initialize(ctx, payments.ChargeRequest{
+		Reference:   payment.Reference,
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		Email:       user.Email,
+		CallbackURL: os.Getenv("PAYMENT_CALLBACK_URL"),
+		Metadata:    map[string]string{"orderId": order.ID.Hex()},
+	})
+	if err != nil {
+		logrus.WithError(err).WithField("orderId", order.ID.Hex()).Error("Failed to initialize payment")
+		c.JSON(http.StatusBadGateway, utils.ErrorResponse("Payment provider is unavailable, try again shortly"))
+		return
+	}
+	payment.AuthorizationURL = charge.AuthorizationURL
+
+	res, err := paymentsColl.InsertOne(ctx, payment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to start payment"))
+		return
+	}
+	payment.ID = res.InsertedID.(primitive.ObjectID)
+	if _, err := h.DB.Collection("orders").UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{"$set": bson.M{
+		"paymentId":     payment.Reference,
+		"paymentStatus": models.PaymentStatusPending,
+		"updatedAt":     now,
+	}}); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to start payment"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse("Payment started", gin.H{"payment": payment}))
+}
+
+// applyPaymentResult settles a pending payment with the provider's verdict.
+// A successful payment moves the order to paid and credits its vendors'
+// wallets; a failed one leaves it waiting for payment so the customer can
+// try again. Money taken that the order cannot keep, because the charge
+// does not match it, the order was cancelled or another payment got there
+// first, is marked refund_due for reconciliation to send back. A success
+// for a payment given up as failed is applied the same way, since the
+// customer was charged after all. Other settled payments are left alone,
+// which makes redelivered results harmless.
+// ctx should be a transaction's session context.
+func applyPaymentResult(ctx context.Context, db *mongo.Database, v *payments.Verification) error {
+	paymentsColl := db.Collection("payments")
+	var payment models.Payment
+	if err := paymentsColl.FindOne(ctx, bson.M{"reference": v.Reference}).Decode(&payment); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errPaymentNotFound
+		}
+		return err
+	}
+	lateSuccess := payment.Status == models.PaymentStatusFailed && v.Status == payments.StatusSucceeded
+	if (payment.Status != models.PaymentStatusPending && !lateSuccess) || v.Status == payments.StatusPending {
+		return nil
+	}
+
+	now := time.Now()
+	log := logrus.WithFields(logrus.Fields{"reference": payment.Reference, "orderId": payment.OrderID.Hex()})
+	set := bson.M{"updatedAt": now}
+	paidAt := now
+	mismatch := v.Status == payments.StatusSucceeded && (!payments.Covers(v.Amount, payment.Amount) || (v.Currency != "" && v.Currency != payment.Currency))
+	if mismatch {
+		log.WithFields(logrus.Fields{"paid": v.Amount, "currency": v.Currency}).Error("Payment does not cover the order, refunding")
+		set["status"], set["failureReason"] = models.PaymentStatusRefundDue, "amount mismatch"
+	} else if v.Status == payments.StatusSucceeded {
+		if v.PaidAt != nil {
+			paidAt = *v.PaidAt
+		}
+		set["status"], set["paidAt"] = models.PaymentStatusPaid, paidAt
+	} else {
+		set["status"] = models.PaymentStatusFailed
+	}
+
+	orders := db.Collection("orders")
+	if set["status"] == models.PaymentStatusPaid {
+		// The order is only marked paid while it is still waiting for
+		// payment, in the same update that checks it
+		res, err := orders.UpdateOne(ctx, bson.M{"_id": payment.OrderID, "status": models.OrderStatusPendingPayment}, bson.M{"$set": bson.M{
+			"paymentId":     payment.Reference,
+			"paymentStatus": models.PaymentStatusPaid,
+			"paidAt":        paidAt,
+			"updatedAt":     now,
+		}})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			log.Warn("Payment received for an order that no longer accepts it, refunding")
+			set["status"], set["failureReason"] = models.PaymentStatusRefundDue, "order no longer awaiting payment"
+		}
+	}
+
+	res, err := paymentsColl.UpdateOne(ctx, bson.M{"_id": payment.ID, "status": payment.Status}, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		// Settled concurrently; abort so the order update above is undone
+		return errPaymentConflict
+	}
+
+	switch {
+	case set["status"] == models.PaymentStatusFailed, mismatch:
+		// Only the latest attempt speaks for the order
+		_, err := orders.UpdateOne(ctx, bson.M{"_id": payment.OrderID, "paymentId": payment.Reference, "status": models.OrderStatusPendingPayment}, bson.M{
+			"$set": bson.M{"paymentStatus": models.PaymentStatusFailed, "updatedAt": now},
+		})
+		return err
+	case set["status"] == models.PaymentStatusRefundDue:
+		return nil
+	}
+
+	if _, err := transitionOrder(ctx, db, payment.OrderID, orderTransition{
+		To:        models.OrderStatusPaid,
+		ActorRole: models.OrderActorSystem,
+		Reason:    "Payment " + payment.Reference + " confirmed by " + payment.Provider,
+	}); err != nil {
+		return err
+	}
+	return postOrderSales(ctx, db, payment.OrderID, paidAt)
+}
+
+// Webhook receives payment notifications from the provider. The signature
+// is checked before anything else, and each event is recorded in the same
+// transaction that applies it so a redelivered event is only acknowledged.
+func (h *PaymentHandler) Webhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	event, err := h.Provider.ParseWebhook(c.Request.Header, body)
+	if errors.Is(err, payments.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Invalid signature"))
+		return
+	}
+	if errors.Is(err, payments.ErrUnsupportedEvent) {
+		c.JSON(http.StatusOK, utils.SuccessResponse("Event ignored", nil))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid webhook payload"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	log := logrus.WithFields(logrus.Fields{"event": event.ID, "reference": event.Reference, "providerRefundId": event.RefundID})
+	err = withTransaction(ctx, h.DB, func(sc mongo.SessionContext) error {
+		if _, err := h.DB.Collection("payment_events").InsertOne(sc, models.PaymentEvent{
+			ID:         h.Provider.Name() + ":" + event.ID,
+			Provider:   h.Provider.Name(),
+			Kind:       event.Kind,
+			Reference:  event.Reference,
+			Status:     event.Status,
+			ReceivedAt: time.Now(),
+		}); err != nil {
+			return err
+		}
+		if event.Kind == payments.EventRefund {
+			return settleRefund(sc, h.DB, event)
+		}
+		return applyPaymentResult(sc, h.DB, &payments.Verification{
+			Reference: event.Reference,
+			Status:    event.Status,
+			Amount:    event.Amount,
+			Currency:  event.Currency,
+		})
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, utils.SuccessResponse("Event processed", nil))
+	case mongo.IsDuplicateKeyError(err):
+		c.JSON(http.StatusOK, utils.SuccessResponse("Event already processed", nil))
+	case errors.Is(err, errPaymentNotFound):
+		// Not one of ours; retrying will not help
+		log.Warn("Webhook for an unknown payment")
+		c.JSON(http.StatusOK, utils.SuccessResponse("Event ignored", nil))
+	case errors.Is(err, errRefundNotFound):
+		log.Warn("Webhook for an unknown refund")
+		c.JSON(http.StatusOK, utils.SuccessResponse("Event ignored", nil))
+	default:
+		// A non-2xx response makes the provider deliver the event again
+		log.WithError(err).Error("Failed to process payment webhook")
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to process event"))
+	}
+}
+
+// ReconcilePayments asks the provider about payments that are still pending
+// in case their webhook never arrived. Payments pending for longer than a
+// day are given up as abandoned, payments due a refund are refunded, and
+// orders left unpaid for longer than unpaidOrderTTL are cancelled.
+func ReconcilePayments(db *mongo.Database, provider payments.PaymentProvider) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		now := time.Now()
+		cutoff := now.Add(-paymentCheckInterval)
+		opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(100)
+		cursor, err := db.Collection("payments").Find(ctx, bson.M{
+			"provider":  provider.Name(),
+			"status":    models.PaymentStatusPending,
+			"createdAt": bson.M{"$lt": cutoff},
+			"$or": bson.A{
+				bson.M{"checkedAt": bson.M{"$exists": false}},
+				bson.M{"checkedAt": bson.M{"$lt": cutoff}},
+			},
+		}, opts)
+		if err != nil {
+			return err
+		}
+		var pending []models.Payment
+		if err := cursor.All(ctx, &pending); err != nil {
+			return err
+		}
+
+		settled := 0
+		for _, payment := range pending {
+			log := logrus.WithField("reference", payment.Reference)
+			v, err := provider.Verify(ctx, payment.Reference)
+			if err != nil {
+				log.WithError(err).Warn("Failed to verify payment")
+				v = &payments.Verification{Reference: payment.Reference, Status: payments.StatusPending}
+			}
+			if v.Status == payments.StatusPending && now.Sub(payment.CreatedAt) > paymentAbandonAfter {
+				v.Status = payments.StatusFailed
+			}
+			if v.Status == payments.StatusPending {
+				if _, err := db.Collection("payments").UpdateOne(ctx, bson.M{"_id": payment.ID}, bson.M{"$set": bson.M{"checkedAt": now}}); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := withTransaction(ctx, db, func(sc mongo.SessionContext) error {
+				return applyPaymentResult(sc, db, v)
+			}); err != nil {
+				log.WithError(err).Error("Failed to apply reconciled payment")
+				continue
+			}
+			settled++
+		}
+		if settled > 0 {
+			logrus.WithField("payments", settled).Info("Reconciled pending payments")
+		}
+		if err := refundDuePayments(ctx, db, provider); err != nil {
+			return err
+		}
+		return expireUnpaidOrders(ctx, db, now)
+	}
+}
+
+// expireUnpaidOrders cancels orders still waiting for payment after
+// unpaidOrderTTL, which puts their stock back and reverses the vendors'
+// sales. Orders with a payment still pending are left for reconciliation to
+// settle first; one that succeeds after the cancellation is refunded.
+func expireUnpaidOrders(ctx context.Context, db *mongo.Database, now time.Time) error {
+	ttl := unpaidOrderTTL()
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(100).SetProjection(bson.M{"_id": 1})
+	cursor, err := db.Collection("orders").Find(ctx, bson.M{
+		"status":    models.OrderStatusPendingPayment,
+		"createdAt": bson.M{"$lt": now.Add(-ttl)},
+	}, opts)
+	if err != nil {
+		return err
+	}
+	var expired []models.Order
+	if err := cursor.All(ctx, &expired); err != nil {
+		return err
+	}
+
+	cancelled := 0
+	for _, order := range expired {
+		log := logrus.WithField("orderId", order.ID.Hex())
+		pending, err := db.Collection("payments").CountDocuments(ctx, bson.M{"orderId": order.ID, "status": models.PaymentStatusPending})
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			continue
+		}
+
+		err = withTransaction(ctx, db, func(sc mongo.SessionContext) error {
+			_, err := transitionOrder(sc, db, order.ID, orderTransition{
+				To:        models.OrderStatusCancelled,
+				ActorRole: models.OrderActorSystem,
+				Reason:    "Not paid within " + ttl.String(),
+			})
+			return err
+		})
+		if errors.Is(err, errIllegalTransition) || errors.Is(err, errOrderConflict) || errors.Is(err, errOrderPaid) {
+			// Paid or cancelled in the meantime
+			continue
+		}
+		if err != nil {
+			log.WithError(err).Error("Failed to cancel unpaid order")
+			continue
+		}
+		cancelled++
+	}
+	if cancelled > 0 {
+		logrus.WithField("orders", cancelled).Info("Cancelled unpaid orders")
+	}
+	return nil
+}
+
+// refundDuePayments sends back charges marked refund_due. The provider is
+// called outside any transaction, and a payment only leaves refund_due once
+// the provider accepts the refund, so a failed attempt is retried on the
+// next run. A refund the provider has yet to settle is refund_pending until
+// its webhook arrives.
+func refundDuePayments(ctx context.Context, db *mongo.Database, provider payments.PaymentProvider) error {
+	paymentsColl := db.Collection("payments")
+	cursor, err := paymentsColl.Find(ctx, bson.M{
+		"provider": provider.Name(),
+		"status":   models.PaymentStatusRefundDue,
+	}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(100))
+	if err != nil {
+		return err
+	}
+	var due []models.Payment
+	if err := cursor.All(ctx, &due); err != nil {
+		return err
+	}
+
+	for _, payment := range due {
+		log := logrus.WithFields(logrus.Fields{"reference": payment.Reference, "orderId": payment.OrderID.Hex()})
+		refund, err := provider.Refund(ctx, payments.RefundRequest{
+			Reference: payment.Reference,
+			Amount:    payment.Amount,
+			Reason:    "Order no longer awaiting payment",
+		})
+		if err != nil {
+			log.WithError(err).Error("Failed to refund payment")
+			continue
+		}
+		if refund.Status == payments.StatusFailed {
+			log.Error("Provider rejected the refund of a payment")
+			continue
+		}
+		// A refund the provider has yet to settle waits for its webhook
+		status := models.PaymentStatusRefundPending
+		if refund.Status == payments.StatusSucceeded {
+			status = models.PaymentStatusRefunded
+		}
+		if _, err := paymentsColl.UpdateOne(ctx, bson.M{"_id": payment.ID, "status": models.PaymentStatusRefundDue}, bson.M{"$set": bson.M{
+			"status":    status,
+			"refundId":  refund.ID,
+			"updatedAt": time.Now(),
+		}}); err != nil {
+			return err
+		}
+		log.WithField("status", status).Info("Refunded a payment the order could not take")
+	}
+	return nil
+}
@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Smallest amount a vendor can withdraw at once
+	minPayoutAmount = 1000.0
+	// A vendor can request one payout per interval; rejected requests do
+	// not count
+	payoutInterval = 7 * 24 * time.Hour
+)
+
+var (
+	errPayoutsBlocked          = errors.New("payouts are blocked for this vendor account")
+	errNoPayoutAccount         = errors.New("no payout bank account registered")
+	errPayoutTooSmall          = fmt.Errorf("payouts must be at least %.2f", minPayoutAmount)
+	errPayoutTooFrequent       = errors.New("a payout was already requested recently")
+	errInsufficientBalance     = errors.New("amount exceeds the available balance")
+	errPayoutNotFound          = errors.New("payout not found")
+	errIllegalPayoutTransition = errors.New("illegal payout status transition")
+)
+
+type payoutAccountInput struct {
+	BankName      string `json:"bankName" validate:"required,max=100"`
+	BankCode      string `json:"bankCode" validate:"required,alphanum,max=20"`
+	AccountName   string `json:"accountName" validate:"required,max=100"`
+	AccountNumber string `json:"accountNumber" validate:"required,numeric,min=6,max=20"`
+}
+
+func respondPayoutError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errVendorAccountNotFound):
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Vendor account not found"))
+	case errors.Is(err, errPayoutNotFound):
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Payout not found"))
+	case errors.Is(err, errPayoutsBlocked):
+		c.JSON(http.StatusForbidden, utils.ErrorResponse(err.Error()))
+	case errors.Is(err, errNoPayoutAccount), errors.Is(err, errIllegalPayoutTransition):
+		c.JSON(http.StatusConflict, utils.ErrorResponse(err.Error()))
+	case errors.Is(err, errPayoutTooSmall), errors.Is(err, errInsufficientBalance):
+		c.JSON(http.StatusUnprocessableEntity, utils.ErrorResponse(err.Error()))
+	case errors.Is(err, errPayoutTooFrequent):
+		c.JSON(http.StatusTooManyRequests, utils.ErrorResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to process payout"))
+	}
+}
+
+func loadVendorAccount(ctx context.Context, db *mongo.Database, vendorID primitive.ObjectID) (*models.VendorAccount, error) {
+	var account models.VendorAccount
+	err := db.Collection("vendor_accounts").FindOne(ctx, bson.M{"userID": vendorID}).Decode(&account)
+	if err == mongo.ErrNoDocuments {
+		return nil, errVendorAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetPayoutAccount returns the calling vendor's payout bank account, with
+// the account number masked.
+func (h *WalletHandler) GetPayoutAccount(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	account, err := loadVendorAccount(ctx, h.DB, middleware.UserID(c))
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+	if account.PayoutAccount == nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("No payout bank account registered"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Payout account fetched successfully", gin.H{"payoutAccount": account.PayoutAccount}))
+}
+
+// SetPayoutAccount registers or replaces the calling vendor's payout bank
+// account. The account number is stored encrypted.
+func (h *WalletHandler) SetPayoutAccount(c *gin.Context) {
+	var input payoutAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := vendorValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	vendorID := middleware.UserID(c)
+	account, err := loadVendorAccount(ctx, h.DB, vendorID)
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+	if account.PayoutsBlocked() {
+		respondPayoutError(c, errPayoutsBlocked)
+		return
+	}
+
+	encrypted, err := utils.EncryptSecret(input.AccountNumber)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to encrypt account number")
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to save payout account"))
+		return
+	}
+	now := time.Now()
+	payoutAccount := models.PayoutBankAccount{
+		BankName:            input.BankName,
+		BankCode:            input.BankCode,
+		AccountName:         input.AccountName,
+		AccountNumber:       encrypted,
+		MaskedAccountNumber: utils.MaskAccountNumber(input.AccountNumber),
+		UpdatedAt:           now,
+	}
+	if _, err := h.DB.Collection("vendor_accounts").UpdateOne(ctx, bson.M{"_id": account.ID}, bson.M{"$set": bson.M{
+		"payoutAccount": payoutAccount,
+		"updatedAt":     now,
+	}}); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to save payout account"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Payout account saved successfully", gin.H{"payoutAccount": payoutAccount}))
+}
+
+// requestPayout takes amount out of a vendor's available balance as a new
+// payout. Stamping the account serialises concurrent requests, so two of
+// them cannot both spend the same balance. ctx should be a transaction's
+// session context.
+func requestPayout(ctx context.Context, db *mongo.Database, vendorID primitive.ObjectID, amount float64) (*models.Payout, error) {
+	account, err := loadVendorAccount(ctx, db, vendorID)
+	if err != nil {
+		return nil, err
+	}
+	if account.PayoutsBlocked() {
+		return nil, errPayoutsBlocked
+	}
+	if account.PayoutAccount == nil {
+		return nil, errNoPayoutAccount
+	}
+	if amount < minPayoutAmount {
+		return nil, errPayoutTooSmall
+	}
+
+	now := time.Now()
+	if _, err := db.Collection("vendor_accounts").UpdateOne(ctx, bson.M{"_id": account.ID}, bson.M{"$set": bson.M{"lastPayoutRequestAt": now}}); err != nil {
+		return nil, err
+	}
+	recent, err := db.Collection("payouts").CountDocuments(ctx, bson.M{
+		"vendorId":    vendorID,
+		"status":      bson.M{"$ne": models.PayoutStatusRejected},
+		"requestedAt": bson.M{"$gt": now.Add(-payoutInterval)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if recent > 0 {
+		return nil, errPayoutTooFrequent
+	}
+	_, available, err := vendorBalances(ctx, db, vendorID)
+	if err != nil {
+		return nil, err
+	}
+	if amount > available {
+		return nil, fmt.Errorf("%w of %.2f", errInsufficientBalance, available)
+	}
+
+	payout := models.Payout{
+		ID:          primitive.NewObjectID(),
+		VendorID:    vendorID,
+		Amount:      amount,
+		Currency:    paymentCurrency(),
+		BankAccount: *account.PayoutAccount,
+		Status:      models.PayoutStatusRequested,
+		RequestedAt: now,
+		UpdatedAt:   now,
+	}
+	if _, err := db.Collection("payouts").InsertOne(ctx, payout); err != nil {
+		return nil, err
+	}
+	if err := postLedgerEntries(ctx, db, models.PayoutEntry(&payout)); err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
+
+// RequestPayout withdraws from the calling vendor's available balance to
+// their payout bank account, once an admin approves it.
+func (h *WalletHandler) RequestPayout(c *gin.Context) {
+	var input struct {
+		Amount float64 `json:"amount" validate:"required,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := vendorValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	var payout *models.Payout
+	err := withTransaction(ctx, h.DB, func(sc mongo.SessionContext) error {
+		var err error
+		payout, err = requestPayout(sc, h.DB, middleware.UserID(c), models.RoundAmount(input.Amount))
+		return err
+	})
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, utils.SuccessResponse("Payout requested successfully", gin.H{"payout": payout}))
+}
+
+func (h *WalletHandler) listPayouts(c *gin.Context, filter bson.M) {
+	page, limit, skip := pageParams(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	coll := h.DB.Collection("payouts")
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch payouts"))
+		return
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "requestedAt", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch payouts"))
+		return
+	}
+	payouts := []models.Payout{}
+	if err := cursor.All(ctx, &payouts); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch payouts"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Payouts fetched successfully", gin.H{
+		"payouts": payouts,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	}))
+}
+
+// ListMyPayouts returns the calling vendor's payouts, newest first.
+func (h *WalletHandler) ListMyPayouts(c *gin.Context) {
+	h.listPayouts(c, bson.M{"vendorId": middleware.UserID(c)})
+}
+
+// ListPayouts returns all payouts for admins, optionally filtered by status.
+func (h *WalletHandler) ListPayouts(c *gin.Context) {
+	filter := bson.M{}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	h.listPayouts(c, filter)
+}
+
+// GetPayout returns one payout to an admin with the full account number so
+// the transfer can be made.
+func (h *WalletHandler) GetPayout(c *gin.Context) {
+	payoutID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid payout ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var payout models.Payout
+	err = h.DB.Collection("payouts").FindOne(ctx, bson.M{"_id": payoutID}).Decode(&payout)
+	if err == mongo.ErrNoDocuments {
+		respondPayoutError(c, errPayoutNotFound)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch payout"))
+		return
+	}
+	accountNumber, err := utils.DecryptSecret(payout.BankAccount.AccountNumber)
+	if err != nil {
+		logrus.WithError(err).WithField("payoutId", payout.ID.Hex()).Error("Failed to decrypt account number")
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch payout"))
+		return
+	}
+	logrus.WithFields(logrus.Fields{"payoutId": payout.ID.Hex(), "adminId": middleware.UserID(c).Hex()}).Info("Payout bank details viewed")
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Payout fetched successfully", gin.H{
+		"payout":        payout,
+		"accountNumber": accountNumber,
+	}))
+}
+
+// payoutTransition is an admin's decision on a payout. Reference is the
+// bank transfer reference when marking it paid.
+type payoutTransition struct {
+	To        string
+	ActorID   primitive.ObjectID
+	Reference string
+	Reason    string
+}
+
+// transitionPayout moves a payout to a new status. Rejecting puts the
+// amount back in the vendor's available balance; approving or paying is
+// refused while the vendor's payouts are blocked. ctx should be a
+// transaction's session context.
+func transitionPayout(ctx context.Context, db *mongo.Database, payoutID primitive.ObjectID, t payoutTransition) (*models.Payout, error) {
+	payouts := db.Collection("payouts")
+	var payout models.Payout
+	if err := payouts.FindOne(ctx, bson.M{"_id": payoutID}).Decode(&payout); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errPayoutNotFound
+		}
+		return nil, err
+	}
+	if !models.CanTransitionPayout(payout.Status, t.To) {
+		return nil, fmt.Errorf("%w: %s to %s", errIllegalPayoutTransition, payout.Status, t.To)
+	}
+	if t.To != models.PayoutStatusRejected {
+		account, err := loadVendorAccount(ctx, db, payout.VendorID)
+		if err != nil {
+			return nil, err
+		}
+		if account.PayoutsBlocked() {
+			return nil, errPayoutsBlocked
+		}
+	}
+
+	now := time.Now()
+	set := bson.M{"status": t.To, "reviewedBy": t.ActorID, "updatedAt": now}
+	switch t.To {
+	case models.PayoutStatusApproved:
+		set["approvedAt"] = now
+	case models.PayoutStatusPaid:
+		set["paidAt"], set["reference"] = now, t.Reference
+	case models.PayoutStatusRejected:
+		set["rejectedAt"], set["rejectionReason"] = now, t.Reason
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := payouts.FindOneAndUpdate(ctx, bson.M{"_id": payout.ID, "status": payout.Status}, bson.M{"$set": set}, opts).Decode(&payout)
+	if err == mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("%w: payout was updated by someone else", errIllegalPayoutTransition)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.To == models.PayoutStatusRejected {
+		if err := postLedgerEntries(ctx, db, models.PayoutReversalEntry(&payout, now)); err != nil {
+			return nil, err
+		}
+	}
+	return &payout, nil
+}
+
+func (h *WalletHandler) applyPayoutTransition(c *gin.Context, t payoutTransition, message string) {
+	payoutID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid payout ID"))
+		return
+	}
+	t.ActorID = middleware.UserID(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	var payout *models.Payout
+	err = withTransaction(ctx, h.DB, func(sc mongo.SessionContext) error {
+		var err error
+		payout, err = transitionPayout(sc, h.DB, payoutID, t)
+		return err
+	})
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse(message, gin.H{"payout": payout}))
+}
+
+// ApprovePayout lets an admin approve a requested payout for transfer.
+func (h *WalletHandler) ApprovePayout(c *gin.Context) {
+	h.applyPayoutTransition(c, payoutTransition{To: models.PayoutStatusApproved}, "Payout approved")
+}
+
+// MarkPayoutPaid records that an approved payout has been transferred.
+func (h *WalletHandler) MarkPayoutPaid(c *gin.Context) {
+	var input struct {
+		Reference string `json:"reference" validate:"required,max=100"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := reviewValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	h.applyPayoutTransition(c, payoutTransition{To: models.PayoutStatusPaid, Reference: input.Reference}, "Payout marked as paid")
+}
+
+// RejectPayout turns down a payout that has not been paid and returns the
+// amount to the vendor's available balance.
+func (h *WalletHandler) RejectPayout(c *gin.Context) {
+	var input struct {
+		Reason string `json:"reason" validate:"required,max=500"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := reviewValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	h.applyPayoutTransition(c, payoutTransition{To: models.PayoutStatusRejected, Reason: input.Reason}, "Payout rejected")
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ProductHandler struct {
+	DB *mongo.Database
+}
+
+func NewProductHandler(db *mongo.Database) *ProductHandler {
+	return &ProductHandler{DB: db}
+}
+
+var productValidator = validator.New()
+
+var errCategoryNotFound = errors.New("category not found")
+
+// Price and Stock are required for simple products and derived from the
+// variants otherwise.
+type productInput struct {
+	Name        string                 `json:"name" validate:"required,max=200"`
+	Description string                 `json:"description" validate:"max=5000"`
+	Price       float64                `json:"price" validate:"omitempty,gt=0"`
+	Stock       int                    `json:"stock" validate:"gte=0"`
+	CategoryID  string                 `json:"categoryId" validate:"required"`
+	Images      []string               `json:"images" validate:"max=10,dive,url"`
+	Options     []models.ProductOption `json:"options" validate:"max=5,dive"`
+	Variants    []variantInput         `json:"variants" validate:"max=100,dive"`
+}
+
+type variantInput struct {
+	SKU     string            `json:"sku" validate:"required,max=64"`
+	Options map[string]string `json:"options" validate:"required"`
+	Price   float64           `json:"price" validate:"required,gt=0"`
+	Stock   int               `json:"stock" validate:"gte=0"`
+	Images  []string          `json:"images" validate:"max=10,dive,url"`
+}
+
+func (v variantInput) variant() models.ProductVariant {
+	return models.ProductVariant{
+		ID:      primitive.NewObjectID(),
+		SKU:     strings.TrimSpace(v.SKU),
+		Options: v.Options,
+		Price:   v.Price,
+		Stock:   v.Stock,
+		Images:  v.Images,
+	}
+}
+
+// Price and Stock can only be changed directly on products without
+// variants, and Options only until the first variant is added.
+type productUpdateInput struct {
+	Name        *string                 `json:"name" validate:"omitempty,min=1,max=200"`
+	Description *string                 `json:"description" validate:"omitempty,max=5000"`
+	Price       *float64                `json:"price" validate:"omitempty,gt=0"`
+	Stock       *int                    `json:"stock" validate:"omitempty,gte=0"`
+	CategoryID  *string                 `json:"categoryId"`
+	Images      *[]string               `json:"images" validate:"omitempty,max=10,dive,url"`
+	Options     *[]models.ProductOption `json:"options" validate:"omitempty,max=5,dive"`
+}
+
+// notDeleted matches products that have not been soft deleted.
+var notDeleted = bson.M{"$exists": false}
+
+// resolveCategory parses a category ID and loads the category.
+func resolveCategory(ctx context.Context, db *mongo.Database, id string) (*models.Category, error) {
+	categoryID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errCategoryNotFound
+	}
+	var category models.Category
+	err = db.Collection("categories").FindOne(ctx, bson.M{"_id": categoryID}).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return nil, errCategoryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// CreateProduct adds a product to the calling vendor's catalog. The product
+// counts against the vendor's tier limit, which also makes sure the vendor
+// has an active account.
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	var input productInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	if len(input.Variants) == 0 && input.Price == 0 {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: price is required for products without variants"))
+		return
+	}
+
+	now := time.Now()
+	product := models.Product{
+		ID:          primitive.NewObjectID(),
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       input.Price,
+		Stock:       input.Stock,
+		Images:      input.Images,
+		Options:     input.Options,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for _, v := range input.Variants {
+		product.Variants = append(product.Variants, v.variant())
+	}
+	if err := product.ValidateVariants(); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	product.SyncVariantTotals()
+	if product.Images == nil {
+		product.Images = []string{}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	category, err := resolveCategory(ctx, h.DB, input.CategoryID)
+	if errors.Is(err, errCategoryNotFound) {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Category not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to validate category"))
+		return
+	}
+
+	vendorID := middleware.UserID(c)
+	if _, err := reserveProductSlot(ctx, h.DB, vendorID); err != nil {
+		switch {
+		case errors.Is(err, errVendorAccountNotFound), errors.Is(err, errVendorAccountInactive):
+			c.JSON(http.StatusForbidden, utils.ErrorResponse("An active vendor account is required to list products"))
+		case errors.Is(err, errProductLimitReached):
+			c.JSON(http.StatusForbidden, utils.ErrorResponse("Product limit reached for your tier"))
+		default:
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to check product limit"))
+		}
+		return
+	}
+
+	product.CategoryID = category.ID
+	product.CategoryName = category.Name
+	product.VendorID = vendorID
+	if _, err := h.DB.Collection("products").InsertOne(ctx, product); err != nil {
+		if err := releaseProductSlot(context.Background(), h.DB, vendorID); err != nil {
+			logrus.WithError(err).WithField("vendorId", vendorID.Hex()).Error("Failed to release product slot")
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, utils.ErrorResponse("You already use one of these SKUs on another product"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to create product"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse("Product created successfully", gin.H{
+		"product": product,
+	}))
+}
+
+// ListMyProducts returns the calling vendor's products, newest first.
+func (h *ProductHandler) ListMyProducts(c *gin.Context) {
+	page, limit, skip := pageParams(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"vendorId": middleware.UserID(c), "deletedAt": notDeleted}
+	total, err := h.DB.Collection("products").CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch products"))
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := h.DB.Collection("products").Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch products"))
+		return
+	}
+	products := []models.Product{}
+	if err := cursor.All(ctx, &products); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch products"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Products fetched successfully", gin.H{
+		"products": products,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+	}))
+}
+
+// GetProduct returns a single product that has not been deleted.
+func (h *ProductHandler) GetProduct(c *gin.Context) {
+	productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid product ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var product models.Product
+	err = h.DB.Collection("products").FindOne(ctx, bson.M{"_id": productID, "deletedAt": notDeleted}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Product not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch product"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Product fetched successfully", gin.H{
+		"product": product,
+	}))
+}
+
+// UpdateProduct applies a partial update to one of the calling vendor's
+// products. Only the fields present in the body are changed.
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid product ID"))
+		return
+	}
+
+	var input productUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{"updatedAt": time.Now()}
+	if input.Name != nil {
+		set["name"] = *input.Name
+	}
+	if input.Description != nil {
+		set["description"] = *input.Description
+	}
+	if input.Price != nil {
+		set["price"] = *input.Price
+	}
+	if input.Stock != nil {
+		set["stock"] = *input.Stock
+	}
+	if input.Images != nil {
+		set["images"] = *input.Images
+	}
+	if input.Options != nil {
+		set["options"] = *input.Options
+	}
+	if input.CategoryID != nil {
+		category, err := resolveCategory(ctx, h.DB, *input.CategoryID)
+		if errors.Is(err, errCategoryNotFound) {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Category not found"))
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to validate category"))
+			return
+		}
+		set["categoryId"] = category.ID
+		set["categoryName"] = category.Name
+	}
+
+	filter := bson.M{
+		"_id":       productID,
+		"vendorId":  middleware.UserID(c),
+		"deletedAt": notDeleted,
+	}
+	perVariant := input.Price != nil || input.Stock != nil || input.Options != nil
+	if perVariant {
+		filter["variants.0"] = bson.M{"$exists": false}
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var product models.Product
+	err = h.DB.Collection("products").FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		delete(filter, "variants.0")
+		if count, _ := h.DB.Collection("products").CountDocuments(ctx, filter); perVariant && count > 0 {
+			c.JSON(http.StatusConflict, utils.ErrorResponse("Price, stock and options of this product are managed through its variants"))
+			return
+		}
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Product not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update product"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Product updated successfully", gin.H{
+		"product": product,
+	}))
+}
+
+// DeleteProduct soft deletes one of the calling vendor's products and gives
+// its slot back to the vendor's product limit.
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid product ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	vendorID := middleware.UserID(c)
+	now := time.Now()
+	res, err := h.DB.Collection("products").UpdateOne(ctx, bson.M{
+		"_id":       productID,
+		"vendorId":  vendorID,
+		"deletedAt": notDeleted,
+	}, bson.M{"$set": bson.M{"deletedAt": now, "updatedAt": now}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to delete product"))
+		return
+	}
+	if res.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Product not found"))
+		return
+	}
+
+	if err := releaseProductSlot(ctx, h.DB, vendorID); err != nil {
+		logrus.WithError(err).WithField("vendorId", vendorID.Hex()).Error("Failed to release product slot")
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Product deleted successfully", nil))
+}
@@ -0,0 +1,621 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/internal/payments"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	errNotRefundable      = errors.New("order cannot be refunded")
+	errRefundItemNotFound = errors.New("item is not part of this order")
+	errRefundExceedsItem  = errors.New("refund quantity exceeds what is left of the item")
+	errNothingToRefund    = errors.New("nothing left to refund")
+	errRefundFailed       = errors.New("payment provider rejected the refund")
+	errRefundNotFound     = errors.New("refund not found")
+	errRefundNotRecorded  = errors.New("refund has not been recorded yet")
+)
+
+type refundLineInput struct {
+	ProductID string `json:"productId" validate:"required"`
+	VariantID string `json:"variantId"`
+	Quantity  int    `json:"quantity" validate:"required,min=1"`
+}
+
+// refundInput refunds the listed items, or everything not yet refunded
+// when Items is empty. Restock defaults to true.
+type refundInput struct {
+	Items   []refundLineInput `json:"items" validate:"omitempty,max=100,dive"`
+	Reason  string            `json:"reason" validate:"required,max=500"`
+	Restock *bool             `json:"restock"`
+}
+
+type refundLine struct {
+	ProductID primitive.ObjectID
+	VariantID *primitive.ObjectID
+	Quantity  int
+}
+
+// refundRequest is a refund of one sub-order. Lines is nil to refund
+// everything not yet refunded.
+type refundRequest struct {
+	Lines     []refundLine
+	Reason    string
+	Restock   bool
+	ActorID   primitive.ObjectID
+	ActorRole string
+}
+
+// bindRefundRequest reads a refund request body, writing the error response
+// and returning false if it is invalid.
+func bindRefundRequest(c *gin.Context) (refundRequest, bool) {
+	var input refundInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return refundRequest{}, false
+	}
+	if err := reviewValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return refundRequest{}, false
+	}
+
+	req := refundRequest{
+		Reason:    input.Reason,
+		Restock:   input.Restock == nil || *input.Restock,
+		ActorID:   middleware.UserID(c),
+		ActorRole: middleware.Role(c),
+	}
+	for _, item := range input.Items {
+		productID, err := primitive.ObjectIDFromHex(item.ProductID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid product ID"))
+			return refundRequest{}, false
+		}
+		variantID, ok := optionalObjectID(c, item.VariantID, "Invalid variant ID")
+		if !ok {
+			return refundRequest{}, false
+		}
+		req.Lines = append(req.Lines, refundLine{ProductID: productID, VariantID: variantID, Quantity: item.Quantity})
+	}
+	return req, true
+}
+
+// refundItems works out what a request refunds from a sub-order, checking
+// each line against what is left of the item.
+func refundItems(sub *models.SubOrder, lines []refundLine) ([]models.RefundItem, error) {
+	if lines == nil {
+		for _, item := range sub.Items {
+			if item.Refundable() > 0 {
+				lines = append(lines, refundLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Refundable()})
+			}
+		}
+	}
+
+	left := make([]int, len(sub.Items))
+	for i, item := range sub.Items {
+		left[i] = item.Refundable()
+	}
+	var items []models.RefundItem
+	for _, line := range lines {
+		i := orderItemIndex(sub.Items, line)
+		if i < 0 {
+			return nil, errRefundItemNotFound
+		}
+		if line.Quantity > left[i] {
+			return nil, fmt.Errorf("%w: %d of %s left", errRefundExceedsItem, left[i], sub.Items[i].Name)
+		}
+		left[i] -= line.Quantity
+		item := sub.Items[i]
+		items = append(items, models.RefundItem{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			SKU:       item.SKU,
+			Name:      item.Name,
+			Quantity:  line.Quantity,
+			Amount:    item.Price * float64(line.Quantity),
+		})
+	}
+	if len(items) == 0 {
+		return nil, errNothingToRefund
+	}
+	return items, nil
+}
+
+func orderItemIndex(items []models.OrderItem, line refundLine) int {
+	for i, item := range items {
+		if item.Matches(line.ProductID, line.VariantID) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyRefundedQuantities adds a refund's quantities and amount to the
+// sub-order and its parent order, or takes them off again when sign is -1.
+func applyRefundedQuantities(ctx context.Context, db *mongo.Database, refund *models.Refund, sign int) error {
+	var sub models.SubOrder
+	if err := db.Collection("sub_orders").FindOne(ctx, bson.M{"_id": refund.SubOrderID}).Decode(&sub); err != nil {
+		return err
+	}
+	var order models.Order
+	if err := db.Collection("orders").FindOne(ctx, bson.M{"_id": refund.OrderID}).Decode(&order); err != nil {
+		return err
+	}
+
+	for _, refunded := range refund.Items {
+		for i := range sub.Items {
+			if sub.Items[i].Matches(refunded.ProductID, refunded.VariantID) {
+				sub.Items[i].RefundedQuantity += sign * refunded.Quantity
+			}
+		}
+		for i := range order.Items {
+			if order.Items[i].VendorID == refund.VendorID && order.Items[i].Matches(refunded.ProductID, refunded.VariantID) {
+				order.Items[i].RefundedQuantity += sign * refunded.Quantity
+			}
+		}
+	}
+
+	now := time.Now()
+	amount := float64(sign) * refund.Amount
+	if _, err := db.Collection("sub_orders").UpdateOne(ctx, bson.M{"_id": sub.ID}, bson.M{
+		"$set": bson.M{"items": sub.Items, "updatedAt": now},
+		"$inc": bson.M{"refundedAmount": amount},
+	}); err != nil {
+		return err
+	}
+	_, err := db.Collection("orders").UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{
+		"$set": bson.M{"items": order.Items, "updatedAt": now},
+		"$inc": bson.M{"refundedAmount": amount},
+	})
+	return err
+}
+
+// reserveRefund checks a refund can be made and records it as pending,
+// counting its quantities as refunded so a concurrent refund cannot take
+// them too. ctx should be a transaction's session context.
+func reserveRefund(ctx context.Context, db *mongo.Database, subOrderID primitive.ObjectID, req refundRequest) (*models.Refund, error) {
+	var sub models.SubOrder
+	if err := db.Collection("sub_orders").FindOne(ctx, bson.M{"_id": subOrderID}).Decode(&sub); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errOrderNotFound
+		}
+		return nil, err
+	}
+	var order models.Order
+	if err := db.Collection("orders").FindOne(ctx, bson.M{"_id": sub.OrderID}).Decode(&order); err != nil {
+		return nil, err
+	}
+	if order.PaymentID == "" || (order.PaymentStatus != models.PaymentStatusPaid && order.PaymentStatus != models.PaymentStatusPartiallyRefunded) {
+		return nil, fmt.Errorf("%w: it has not been paid for", errNotRefundable)
+	}
+	if !models.OrderRefundable(sub.Status) {
+		return nil, fmt.Errorf("%w: it is %s", errNotRefundable, sub.Status)
+	}
+
+	items, err := refundItems(&sub, req.Lines)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	refund := models.Refund{
+		ID:         primitive.NewObjectID(),
+		OrderID:    order.ID,
+		SubOrderID: sub.ID,
+		VendorID:   sub.VendorID,
+		UserID:     order.UserID,
+		PaymentID:  order.PaymentID,
+		Items:      items,
+		Reason:     req.Reason,
+		Restock:    req.Restock,
+		Status:     models.RefundStatusPending,
+		ActorID:    req.ActorID,
+		ActorRole:  req.ActorRole,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	for _, item := range items {
+		refund.Amount += item.Amount
+	}
+
+	if err := applyRefundedQuantities(ctx, db, &refund, 1); err != nil {
+		return nil, err
+	}
+	if _, err := db.Collection("refunds").InsertOne(ctx, refund); err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// completeRefund applies a refund the provider accepted: the items go back
+// into stock, the sale comes off the vendor's totals, the vendor's wallet
+// is debited, and a sub-order with nothing left to refund becomes refunded.
+// ctx should be a transaction's session context.
+func completeRefund(ctx context.Context, db *mongo.Database, refund *models.Refund, providerRefundID string) error {
+	now := time.Now()
+	if _, err := db.Collection("refunds").UpdateOne(ctx, bson.M{"_id": refund.ID}, bson.M{"$set": bson.M{
+		"status":           models.RefundStatusSucceeded,
+		"providerRefundId": providerRefundID,
+		"updatedAt":        now,
+	}}); err != nil {
+		return err
+	}
+	refund.Status, refund.ProviderRefundID = models.RefundStatusSucceeded, providerRefundID
+
+	if refund.Restock {
+		for _, item := range refund.Items {
+			if err := releaseStock(ctx, db, stockLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}); err != nil {
+				return err
+			}
+		}
+	}
+
+	var sub models.SubOrder
+	if err := db.Collection("sub_orders").FindOne(ctx, bson.M{"_id": refund.SubOrderID}).Decode(&sub); err != nil {
+		return err
+	}
+	fullyRefunded := true
+	for _, item := range sub.Items {
+		fullyRefunded = fullyRefunded && item.Refundable() == 0
+	}
+	if err := reverseVendorSale(ctx, db, refund.VendorID, refund.Amount, sub.CreatedAt, fullyRefunded); err != nil {
+		return err
+	}
+	if err := postRefund(ctx, db, refund); err != nil {
+		return err
+	}
+
+	t := orderTransition{
+		To:        models.OrderStatusRefunded,
+		ActorID:   &refund.ActorID,
+		ActorRole: refund.ActorRole,
+		Reason:    refund.Reason,
+	}
+	if fullyRefunded {
+		if _, err := transitionSubOrder(ctx, db, &sub, t); err != nil {
+			return err
+		}
+		if _, err := syncParentOrder(ctx, db, sub.OrderID, t); err != nil {
+			return err
+		}
+	}
+
+	var order models.Order
+	if err := db.Collection("orders").FindOne(ctx, bson.M{"_id": refund.OrderID}).Decode(&order); err != nil {
+		return err
+	}
+	paymentStatus := models.PaymentStatusPartiallyRefunded
+	// Allow for float rounding in the running total
+	if math.Abs(order.Total-order.RefundedAmount) < 0.005 {
+		paymentStatus = models.PaymentStatusRefunded
+	}
+	_, err := db.Collection("orders").UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{"$set": bson.M{"paymentStatus": paymentStatus}})
+	return err
+}
+
+// failRefund gives back the quantities of a refund the provider rejected.
+// ctx should be a transaction's session context.
+func failRefund(ctx context.Context, db *mongo.Database, refund *models.Refund, reason string) error {
+	if err := applyRefundedQuantities(ctx, db, refund, -1); err != nil {
+		return err
+	}
+	refund.Status, refund.FailureReason = models.RefundStatusFailed, reason
+	_, err := db.Collection("refunds").UpdateOne(ctx, bson.M{"_id": refund.ID}, bson.M{"$set": bson.M{
+		"status":        models.RefundStatusFailed,
+		"failureReason": reason,
+		"updatedAt":     time.Now(),
+	}})
+	return err
+}
+
+// refundSubOrder refunds some or all of a sub-order. The refund is reserved
+// first, then sent to the provider outside any transaction so it is never
+// sent twice, then completed or given back depending on the outcome. A
+// refund the provider has yet to settle stays pending until its webhook.
+func refundSubOrder(ctx context.Context, db *mongo.Database, provider payments.PaymentProvider, subOrderID primitive.ObjectID, req refundRequest) (*models.Refund, error) {
+	var refund *models.Refund
+	err := withTransaction(ctx, db, func(sc mongo.SessionContext) error {
+		var err error
+		refund, err = reserveRefund(sc, db, subOrderID, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log := logrus.WithFields(logrus.Fields{"refundId": refund.ID.Hex(), "orderId": refund.OrderID.Hex()})
+	result, providerErr := provider.Refund(ctx, payments.RefundRequest{
+		Reference: refund.PaymentID,
+		Amount:    refund.Amount,
+		Reason:    refund.Reason,
+	})
+	if providerErr != nil {
+		log.WithError(providerErr).Error("Payment provider rejected refund")
+		if err := withTransaction(ctx, db, func(sc mongo.SessionContext) error {
+			return failRefund(sc, db, refund, providerErr.Error())
+		}); err != nil {
+			log.WithError(err).Error("Failed to release rejected refund")
+		}
+		return refund, fmt.Errorf("%w: %v", errRefundFailed, providerErr)
+	}
+
+	switch result.Status {
+	case payments.StatusSucceeded:
+		if err := withTransaction(ctx, db, func(sc mongo.SessionContext) error {
+			return completeRefund(sc, db, refund, result.ID)
+		}); err != nil {
+			// The money has gone back; the records need fixing by hand
+			log.WithError(err).WithField("providerRefundId", result.ID).Error("Refund sent but not recorded")
+			return refund, err
+		}
+	case payments.StatusFailed:
+		reason := "The payment provider failed the refund"
+		if err := withTransaction(ctx, db, func(sc mongo.SessionContext) error {
+			return failRefund(sc, db, refund, reason)
+		}); err != nil {
+			log.WithError(err).Error("Failed to release rejected refund")
+		}
+		return refund, fmt.Errorf("%w: %s", errRefundFailed, reason)
+	default:
+		// The outcome arrives by webhook, which finds the refund by this ID
+		refund.ProviderRefundID = result.ID
+		if _, err := db.Collection("refunds").UpdateOne(ctx, bson.M{"_id": refund.ID}, bson.M{"$set": bson.M{
+			"providerRefundId": result.ID,
+			"updatedAt":        time.Now(),
+		}}); err != nil {
+			log.WithError(err).WithField("providerRefundId", result.ID).Error("Refund sent but not recorded")
+			return refund, err
+		}
+	}
+	return refund, nil
+}
+
+// settleRefund applies a refund webhook to the pending refund with the
+// provider's refund ID, or to the refunded payment carrying it. A refund
+// that has already been settled is left alone, so a redelivered outcome
+// changes nothing. ctx should be a transaction's session context.
+func settleRefund(ctx context.Context, db *mongo.Database, event *payments.Event) error {
+	var refund models.Refund
+	err := db.Collection("refunds").FindOne(ctx, bson.M{"providerRefundId": event.RefundID}).Decode(&refund)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if found, err := settlePaymentRefund(ctx, db, event); found || err != nil {
+			return err
+		}
+		// The webhook can arrive before refundSubOrder or reconciliation
+		// records the ID
+		count, err := db.Collection("refunds").CountDocuments(ctx, bson.M{
+			"paymentId":        event.Reference,
+			"status":           models.RefundStatusPending,
+			"providerRefundId": bson.M{"$exists": false},
+		})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			count, err = db.Collection("payments").CountDocuments(ctx, bson.M{
+				"reference": event.Reference,
+				"status":    models.PaymentStatusRefundDue,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if count > 0 {
+			return errRefundNotRecorded
+		}
+		return errRefundNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if refund.Status != models.RefundStatusPending {
+		return nil
+	}
+
+	switch event.Status {
+	case payments.StatusSucceeded:
+		return completeRefund(ctx, db, &refund, event.RefundID)
+	case payments.StatusFailed:
+		return failRefund(ctx, db, &refund, "The payment provider failed the refund")
+	}
+	return nil
+}
+
+// settlePaymentRefund applies a refund webhook to a payment reconciliation
+// refunded because its order could not take it. It reports whether the
+// refund was one of those. A failed refund puts the payment back to
+// refund_due so the next run tries again. ctx should be a transaction's
+// session context.
+func settlePaymentRefund(ctx context.Context, db *mongo.Database, event *payments.Event) (bool, error) {
+	paymentsColl := db.Collection("payments")
+	var payment models.Payment
+	err := paymentsColl.FindOne(ctx, bson.M{"refundId": event.RefundID}).Decode(&payment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if payment.Status != models.PaymentStatusRefundPending {
+		return true, nil
+	}
+
+	status := models.PaymentStatusRefunded
+	switch event.Status {
+	case payments.StatusSucceeded:
+	case payments.StatusFailed:
+		logrus.WithField("reference", payment.Reference).Error("Provider failed the refund of a payment, retrying")
+		status = models.PaymentStatusRefundDue
+	default:
+		return true, nil
+	}
+	_, err = paymentsColl.UpdateOne(ctx, bson.M{"_id": payment.ID, "status": models.PaymentStatusRefundPending}, bson.M{"$set": bson.M{
+		"status":    status,
+		"updatedAt": time.Now(),
+	}})
+	return true, err
+}
+
+func respondRefundError(c *gin.Context, err error, done []*models.Refund) {
+	status, message := http.StatusInternalServerError, "Failed to refund order"
+	switch {
+	case errors.Is(err, errOrderNotFound):
+		status, message = http.StatusNotFound, "Order not found"
+	case errors.Is(err, errRefundItemNotFound):
+		status, message = http.StatusBadRequest, "An item is not part of this order"
+	case errors.Is(err, errRefundExceedsItem):
+		status, message = http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, errNotRefundable), errors.Is(err, errNothingToRefund), errors.Is(err, errIllegalTransition):
+		status, message = http.StatusConflict, err.Error()
+	case errors.Is(err, errRefundFailed):
+		status, message = http.StatusBadGateway, "The payment provider rejected the refund"
+	}
+	if len(done) > 0 {
+		c.JSON(status, utils.Response{Error: message, Data: gin.H{"refunds": done}})
+		return
+	}
+	c.JSON(status, utils.ErrorResponse(message))
+}
+
+// RefundOrder lets an admin refund a whole order, or selected items of it.
+// Items are refunded against the sub-order of the vendor who sold them,
+// one refund per vendor.
+func (h *PaymentHandler) RefundOrder(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+	req, ok := bindRefundRequest(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	subOrders, err := loadSubOrders(ctx, h.DB, orderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch order"))
+		return
+	}
+	if len(subOrders) == 0 {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Order not found"))
+		return
+	}
+
+	// Split the requested lines by the vendor that sold them
+	perSubOrder := make(map[primitive.ObjectID][]refundLine)
+	for _, line := range req.Lines {
+		found := false
+		for _, sub := range subOrders {
+			if orderItemIndex(sub.Items, line) >= 0 {
+				perSubOrder[sub.ID] = append(perSubOrder[sub.ID], line)
+				found = true
+				break
+			}
+		}
+		if !found {
+			respondRefundError(c, errRefundItemNotFound, nil)
+			return
+		}
+	}
+
+	var refunds []*models.Refund
+	for _, sub := range subOrders {
+		subReq := req
+		if req.Lines != nil {
+			if subReq.Lines = perSubOrder[sub.ID]; subReq.Lines == nil {
+				continue
+			}
+		} else if !models.OrderRefundable(sub.Status) {
+			// Refunding everything skips vendors whose part was cancelled
+			continue
+		}
+
+		refund, err := refundSubOrder(ctx, h.DB, h.Provider, sub.ID, subReq)
+		if errors.Is(err, errNothingToRefund) && req.Lines == nil {
+			continue
+		}
+		if err != nil {
+			respondRefundError(c, err, refunds)
+			return
+		}
+		refunds = append(refunds, refund)
+	}
+	if len(refunds) == 0 {
+		respondRefundError(c, errNothingToRefund, nil)
+		return
+	}
+	c.JSON(http.StatusCreated, utils.SuccessResponse("Order refunded successfully", gin.H{"refunds": refunds}))
+}
+
+// RefundVendorOrder lets a vendor refund their sub-order, or selected items
+// of it.
+func (h *PaymentHandler) RefundVendorOrder(c *gin.Context) {
+	subOrderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+	req, ok := bindRefundRequest(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	count, err := h.DB.Collection("sub_orders").CountDocuments(ctx, bson.M{"_id": subOrderID, "vendorId": middleware.UserID(c)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch order"))
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Order not found"))
+		return
+	}
+
+	refund, err := refundSubOrder(ctx, h.DB, h.Provider, subOrderID, req)
+	if err != nil {
+		respondRefundError(c, err, nil)
+		return
+	}
+	c.JSON(http.StatusCreated, utils.SuccessResponse("Order refunded successfully", gin.H{"refund": refund}))
+}
+
+// ListMyOrderRefunds returns the refunds made on one of the customer's
+// orders.
+func (h *PaymentHandler) ListMyOrderRefunds(c *gin.Context) {
+	orderID, ok := orderIDParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := h.DB.Collection("refunds").Find(ctx, bson.M{"orderId": orderID, "userId": middleware.UserID(c)}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch refunds"))
+		return
+	}
+	refunds := []models.Refund{}
+	if err := cursor.All(ctx, &refunds); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch refunds"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Refunds fetched successfully", gin.H{"refunds": refunds}))
+}
@@ -3,12 +3,15 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/internal/payments"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func SetupRoutes(router *gin.Engine, db *mongo.Database) {
+func SetupRoutes(router *gin.Engine, db *mongo.Database, provider payments.PaymentProvider) {
 	logrus.Info("Setting up routes...")
 
 	router.GET("/", func(c *gin.Context) {
@@ -29,21 +32,139 @@ func SetupRoutes(router *gin.Engine, db *mongo.Database) {
 
 	if db != nil {
 		logrus.Info("Database connected - setting up database routes")
-		router.POST("/api/v1/auth/register", NewAuthHandler(db).CreateUser)
-		router.POST("/api/v1/auth/verify/:token", NewAuthHandler(db).VerifyEmail)
-		router.POST("/api/v1/auth/login", NewAuthHandler(db).LoginUser)
-		router.POST("/api/v1/auth/forgot-password", NewAuthHandler(db).ForgotPassword)
-		router.POST("/api/v1/auth/reset-password", NewAuthHandler(db).ResetPassword)
-		router.POST("/api/v1/onboarding/interests", NewOnboardingHandler(db).ClientUpdateInterest)
-		router.POST("/api/v1/onboarding/preference", NewOnboardingHandler(db).ClientUpdatePreference)
-		router.POST("/api/v1/onboarding/profile", NewOnboardingHandler(db).CompleteOnboardingFlow)
-		router.POST("/api/v1/onboarding/draft", NewOnboardingHandler(db).UserOnboardingDraft)
-		router.GET("/api/v1/onboarding/draft", NewOnboardingHandler(db).GetOnboardingDraft)
-		router.POST("/api/v1/auth/resend/:token", NewAuthHandler(db).ResendVerification)
-		router.POST("/api/v1/onboarding/seller/business-type", NewOnboardingHandler(db).SellerBusinessType)
-		router.POST("/api/v1/onboarding/seller/business-category", NewOnboardingHandler(db).SellerBusinessCategory)
-		router.POST("/api/v1/onboarding/seller/business-details", NewOnboardingHandler(db).SellerBusinessInfo)
-		router.POST("/api/v1/onboarding/seller/store-details", NewOnboardingHandler(db).StoreDetails)
+		authHandler := NewAuthHandler(db)
+		onboardingHandler := NewOnboardingHandler(db)
+		vendorHandler := NewVendorHandler(db)
+		sellerReviewHandler := NewSellerReviewHandler(db)
+		vendorAccountHandler := NewVendorAccountHandler(db)
+		productHandler := NewProductHandler(db)
+		categoryHandler := NewCategoryHandler(db)
+		cartHandler := NewCartHandler(db)
+		orderHandler := NewOrderHandler(db, provider)
+		paymentHandler := NewPaymentHandler(db, provider)
+		walletHandler := NewWalletHandler(db)
+		wishlistHandler := NewWishlistHandler(db)
+
+		api := router.Group("/api/v1")
+
+		auth := api.Group("/auth")
+		auth.POST("/register", authHandler.CreateUser)
+		auth.POST("/verify/:token", authHandler.VerifyEmail)
+		auth.POST("/resend-verification", authHandler.ResendVerification)
+		auth.POST("/login", authHandler.LoginUser)
+		auth.POST("/forgot-password", authHandler.ForgotPassword)
+		auth.POST("/reset-password", authHandler.ResetPassword)
+		auth.POST("/refresh", authHandler.RefreshToken)
+		auth.POST("/logout", authHandler.Logout)
+		auth.POST("/logout-all", middleware.RequireAuth(), authHandler.LogoutAll)
+
+		// Customers onboard as shoppers and may also go through seller onboarding;
+		// existing vendors can revisit the seller steps to upgrade their tier.
+		onboarding := api.Group("/onboarding", middleware.RequireAuth(), middleware.RequireRoles(models.RoleCustomer, models.RoleVendor))
+		onboarding.POST("/interests", onboardingHandler.ClientUpdateInterest)
+		onboarding.POST("/preference", onboardingHandler.ClientUpdatePreference)
+		onboarding.POST("/profile", onboardingHandler.CompleteOnboardingFlow)
+		onboarding.POST("/draft", onboardingHandler.UserOnboardingDraft)
+		onboarding.GET("/draft", onboardingHandler.GetOnboardingDraft)
+		onboarding.POST("/seller/business-type", onboardingHandler.SellerBusinessType)
+		onboarding.POST("/seller/business-category", onboardingHandler.SellerBusinessCategory)
+		onboarding.POST("/seller/business-details", onboardingHandler.SellerBusinessInfo)
+		onboarding.POST("/seller/store-details", onboardingHandler.StoreDetails)
+		onboarding.POST("/seller/documents/:type", onboardingHandler.UploadSellerDocument)
+		onboarding.POST("/seller/submit", onboardingHandler.SubmitSellerApplication)
+
+		vendor := api.Group("/vendor", middleware.RequireAuth())
+		vendor.POST("/apply", middleware.RequireRoles(models.RoleCustomer), vendorHandler.ApplyForVendor)
+		vendor.GET("/account", middleware.RequireRoles(models.RoleVendor), vendorAccountHandler.GetMyAccount)
+
+		vendorOrders := vendor.Group("/orders", middleware.RequireRoles(models.RoleVendor))
+		vendorOrders.GET("", orderHandler.ListVendorOrders)
+		vendorOrders.GET("/:id", orderHandler.GetVendorOrder)
+		vendorOrders.POST("/:id/status", orderHandler.UpdateVendorOrderStatus)
+		vendorOrders.POST("/:id/refunds", paymentHandler.RefundVendorOrder)
+
+		vendorWallet := vendor.Group("/wallet", middleware.RequireRoles(models.RoleVendor))
+		vendorWallet.GET("", walletHandler.GetMyWallet)
+		vendorWallet.GET("/statement", walletHandler.GetMyStatement)
+		vendorWallet.GET("/statement/export", walletHandler.ExportMyStatement)
+		vendorWallet.GET("/payout-account", walletHandler.GetPayoutAccount)
+		vendorWallet.PUT("/payout-account", walletHandler.SetPayoutAccount)
+		vendorWallet.GET("/payouts", walletHandler.ListMyPayouts)
+		vendorWallet.POST("/payouts", walletHandler.RequestPayout)
+
+		vendorProducts := vendor.Group("/products", middleware.RequireRoles(models.RoleVendor))
+		vendorProducts.POST("", productHandler.CreateProduct)
+		vendorProducts.GET("", productHandler.ListMyProducts)
+		vendorProducts.PUT("/:id", productHandler.UpdateProduct)
+		vendorProducts.DELETE("/:id", productHandler.DeleteProduct)
+		vendorProducts.POST("/:id/variants", productHandler.AddVariant)
+		vendorProducts.PUT("/:id/variants/:variantId", productHandler.UpdateVariant)
+		vendorProducts.DELETE("/:id/variants/:variantId", productHandler.DeleteVariant)
+
+		products := api.Group("/products")
+		products.GET("", productHandler.ListProducts)
+		products.GET("/search", productHandler.SearchProducts)
+		products.GET("/suggest", productHandler.SuggestProducts)
+		products.GET("/:id", productHandler.GetProduct)
+
+		api.GET("/categories", categoryHandler.GetCategoryTree)
+
+		cart := api.Group("/cart", middleware.RequireAuth())
+		cart.GET("", cartHandler.GetCart)
+		cart.DELETE("", cartHandler.ClearCart)
+		cart.POST("/items", cartHandler.AddCartItem)
+		cart.PUT("/items/:productId", cartHandler.UpdateCartItem)
+		cart.DELETE("/items/:productId", cartHandler.RemoveCartItem)
+
+		wishlist := api.Group("/wishlist", middleware.RequireAuth())
+		wishlist.GET("", wishlistHandler.GetWishlist)
+		wishlist.POST("/items", wishlistHandler.AddWishlistItem)
+		wishlist.DELETE("/items/:productId", wishlistHandler.RemoveWishlistItem)
+		wishlist.POST("/items/:productId/move-to-cart", wishlistHandler.MoveWishlistItemToCart)
+		wishlist.PUT("/notifications", wishlistHandler.UpdateWishlistNotifications)
+
+		api.POST("/checkout", middleware.RequireAuth(), orderHandler.Checkout)
+
+		orders := api.Group("/orders", middleware.RequireAuth())
+		orders.GET("", orderHandler.ListMyOrders)
+		orders.GET("/:id", orderHandler.GetMyOrder)
+		orders.POST("/:id/cancel", orderHandler.CancelMyOrder)
+		orders.POST("/:id/pay", paymentHandler.PayOrder)
+		orders.GET("/:id/refunds", paymentHandler.ListMyOrderRefunds)
+
+		api.POST("/payments/webhook", paymentHandler.Webhook)
+
+		// Visitors identify their cart with the X-Cart-Token header
+		guestCart := api.Group("/guest-cart")
+		guestCart.GET("", cartHandler.GetGuestCart)
+		guestCart.DELETE("", cartHandler.ClearGuestCart)
+		guestCart.POST("/items", cartHandler.AddGuestCartItem)
+		guestCart.PUT("/items/:productId", cartHandler.UpdateGuestCartItem)
+		guestCart.DELETE("/items/:productId", cartHandler.RemoveGuestCartItem)
+
+		admin := api.Group("/admin", middleware.RequireAuth(), middleware.RequireRoles(models.RoleAdmin))
+		admin.GET("/vendor-applications", vendorHandler.ListVendorApplications)
+		admin.GET("/vendor-applications/:id", vendorHandler.GetVendorApplication)
+		admin.POST("/vendor-applications/:id/approve", vendorHandler.ApproveVendorApplication)
+		admin.POST("/vendor-applications/:id/reject", vendorHandler.RejectVendorApplication)
+		admin.GET("/seller-applications", sellerReviewHandler.ListApplications)
+		admin.GET("/seller-applications/:id", sellerReviewHandler.GetApplication)
+		admin.POST("/seller-applications/:id/transition", sellerReviewHandler.TransitionApplication)
+		admin.POST("/seller-applications/:id/documents/:type/review", sellerReviewHandler.ReviewDocument)
+		admin.GET("/tier-policies", vendorAccountHandler.ListTierPolicies)
+		admin.PUT("/tier-policies/:tier", vendorAccountHandler.UpdateTierPolicy)
+		admin.POST("/vendor-accounts/:id/tier", vendorAccountHandler.ChangeAccountTier)
+		admin.POST("/categories", categoryHandler.CreateCategory)
+		admin.PUT("/categories/:id", categoryHandler.UpdateCategory)
+		admin.POST("/categories/:id/move", categoryHandler.MoveCategory)
+		admin.DELETE("/categories/:id", categoryHandler.DeleteCategory)
+		admin.POST("/orders/:id/status", orderHandler.TransitionOrder)
+		admin.POST("/orders/:id/refunds", paymentHandler.RefundOrder)
+		admin.GET("/payouts", walletHandler.ListPayouts)
+		admin.GET("/payouts/:id", walletHandler.GetPayout)
+		admin.POST("/payouts/:id/approve", walletHandler.ApprovePayout)
+		admin.POST("/payouts/:id/paid", walletHandler.MarkPayoutPaid)
+		admin.POST("/payouts/:id/reject", walletHandler.RejectPayout)
 
 	} else {
 		logrus.Warn("Database not connected - running with limited functionality")
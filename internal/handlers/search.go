@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/internal/search"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const maxSuggestions = 8
+
+// priceBuckets are the lower bounds of the price facet buckets.
+var priceBuckets = bson.A{0, 1000, 5000, 20000, 100000, 500000}
+
+type facetCount struct {
+	ID    interface{} `bson:"_id" json:"value"`
+	Name  string      `bson:"name,omitempty" json:"name,omitempty"`
+	Count int         `bson:"count" json:"count"`
+}
+
+type searchResult struct {
+	Products []models.Product `bson:"products"`
+	Total    []struct {
+		Count int64 `bson:"count"`
+	} `bson:"total"`
+	Categories []facetCount `bson:"categories"`
+	Prices     []facetCount `bson:"prices"`
+	Vendors    []facetCount `bson:"vendors"`
+}
+
+// correctQuery replaces words the catalog has never seen with the closest
+// known word. It reports whether anything changed.
+func correctQuery(query string) (string, bool) {
+	terms := search.Tokenize(query)
+	changed := false
+	for i, term := range terms {
+		if corrected := search.Products.Correct(term); corrected != term {
+			terms[i] = corrected
+			changed = true
+		}
+	}
+	return strings.Join(terms, " "), changed
+}
+
+// SearchProducts runs a relevance ranked full-text search over product names,
+// category names and descriptions. Misspelled words are corrected against
+// the catalog vocabulary, and the response carries category, price and
+// vendor facets for the whole result set.
+func (h *ProductHandler) SearchProducts(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("q is required"))
+		return
+	}
+	page, limit, skip := pageParams(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	searchQuery := query
+	corrected, changed := correctQuery(query)
+	if changed {
+		searchQuery = corrected
+	}
+
+	match := bson.M{"$text": bson.M{"$search": searchQuery}, "deletedAt": notDeleted}
+	if raw := c.Query("category"); raw != "" {
+		categoryID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid category ID"))
+			return
+		}
+		ids, err := categoryWithDescendants(ctx, h.DB, categoryID)
+		if errors.Is(err, errCategoryNotFound) {
+			c.JSON(http.StatusNotFound, utils.ErrorResponse("Category not found"))
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch category"))
+			return
+		}
+		match["categoryId"] = bson.M{"$in": ids}
+	}
+	if raw := c.Query("vendor"); raw != "" {
+		vendorID, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid vendor ID"))
+			return
+		}
+		match["vendorId"] = vendorID
+	}
+	price := bson.M{}
+	for param, op := range map[string]string{"minPrice": "$gte", "maxPrice": "$lte"} {
+		if raw := c.Query(param); raw != "" {
+			value, err := strconv.ParseFloat(raw, 64)
+			if err != nil || value < 0 {
+				c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid "+param))
+				return
+			}
+			price[op] = value
+		}
+	}
+	if len(price) > 0 {
+		match["price"] = price
+	}
+
+	cursor, err := h.DB.Collection("products").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}},
+		{{Key: "$facet", Value: bson.M{
+			"products": bson.A{
+				bson.M{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "salesCount", Value: -1}, {Key: "_id", Value: 1}}},
+				bson.M{"$skip": skip},
+				bson.M{"$limit": limit},
+			},
+			"total": bson.A{bson.M{"$count": "count"}},
+			"categories": bson.A{
+				bson.M{"$group": bson.M{"_id": "$categoryId", "name": bson.M{"$first": "$categoryName"}, "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+				bson.M{"$limit": 20},
+			},
+			"prices": bson.A{
+				bson.M{"$bucket": bson.M{
+					"groupBy":    "$price",
+					"boundaries": priceBuckets,
+					"default":    "500000+",
+					"output":     bson.M{"count": bson.M{"$sum": 1}},
+				}},
+			},
+			"vendors": bson.A{
+				bson.M{"$group": bson.M{"_id": "$vendorId", "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+				bson.M{"$limit": 20},
+			},
+		}}},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to search products"))
+		return
+	}
+	var results []searchResult
+	if err := cursor.All(ctx, &results); err != nil || len(results) == 0 {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to search products"))
+		return
+	}
+	result := results[0]
+
+	var total int64
+	if len(result.Total) > 0 {
+		total = result.Total[0].Count
+	}
+	if result.Products == nil {
+		result.Products = []models.Product{}
+	}
+
+	data := gin.H{
+		"products": result.Products,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+		"facets": gin.H{
+			"categories": nonNilFacets(result.Categories),
+			"prices":     nonNilFacets(result.Prices),
+			"vendors":    nonNilFacets(result.Vendors),
+		},
+	}
+	if changed {
+		data["correctedQuery"] = corrected
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Search completed successfully", data))
+}
+
+func nonNilFacets(facets []facetCount) []facetCount {
+	if facets == nil {
+		return []facetCount{}
+	}
+	return facets
+}
+
+// SuggestProducts completes the last word of a partial query from the
+// catalog vocabulary for the search box.
+func (h *ProductHandler) SuggestProducts(c *gin.Context) {
+	query := strings.ToLower(c.Query("q"))
+	words := strings.Fields(query)
+	suggestions := []string{}
+	if len(words) > 0 && !strings.HasSuffix(query, " ") {
+		head := strings.Join(words[:len(words)-1], " ")
+		for _, term := range search.Products.Complete(words[len(words)-1], maxSuggestions) {
+			if head != "" {
+				term = head + " " + term
+			}
+			suggestions = append(suggestions, term)
+		}
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Suggestions fetched successfully", gin.H{"suggestions": suggestions}))
+}
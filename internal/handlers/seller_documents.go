@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	maxDocumentSize      = 10 << 20 // 10 MB
+	maxDocumentsPerField = 5
+)
+
+var allowedDocumentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"application/pdf": true,
+}
+
+// sellerDocumentSlot maps the :type route parameter to the SellerApplication
+// field the document belongs to. Fields holding several documents accept a
+// documentType naming what each one is, from DocumentTypes.
+type sellerDocumentSlot struct {
+	Field         string
+	Multiple      bool
+	DocumentTypes []string
+}
+
+var sellerDocumentSlots = map[string]sellerDocumentSlot{
+	"id":            {Field: "idDocument"},
+	"selfie":        {Field: "selfieVerification"},
+	"address_proof": {Field: "addressProof"},
+	"proof_of_activity": {Field: "proofOfActivity", Multiple: true, DocumentTypes: []string{
+		"social_media", "website", "invoice", "bank_statement", "other",
+	}},
+	"business_document": {Field: "businessDocuments", Multiple: true, DocumentTypes: []string{
+		"business_license", "registration_certificate", "tax_document", "other",
+	}},
+}
+
+// documentAt returns the document stored in a field of the application, or
+// at index for fields holding several documents.
+func documentAt(docs models.SellerDocuments, field string, index int) *models.VerificationDocument {
+	pick := func(list []models.VerificationDocument) *models.VerificationDocument {
+		if index < 0 || index >= len(list) {
+			return nil
+		}
+		return &list[index]
+	}
+	switch field {
+	case "idDocument":
+		return docs.IDDocument
+	case "selfieVerification":
+		return docs.SelfieVerification
+	case "addressProof":
+		return docs.AddressProof
+	case "proofOfActivity":
+		return pick(docs.ProofOfActivity)
+	case "businessDocuments":
+		return pick(docs.BusinessDocuments)
+	}
+	return nil
+}
+
+func applicationDocuments(app *models.SellerApplication) models.SellerDocuments {
+	return models.SellerDocuments{
+		IDDocument:         app.IDDocument,
+		SelfieVerification: app.SelfieVerification,
+		ProofOfActivity:    app.ProofOfActivity,
+		AddressProof:       app.AddressProof,
+		BusinessDocuments:  app.BusinessDocuments,
+	}
+}
+
+// documentPath is the dotted path of a document inside its parent document.
+func documentPath(slot sellerDocumentSlot, index int) string {
+	if slot.Multiple {
+		return slot.Field + "." + strconv.Itoa(index)
+	}
+	return slot.Field
+}
+
+// UploadSellerDocument uploads a verification document. Before submission
+// it is attached to the onboarding draft; once an application is under
+// review, only documents an admin rejected may be uploaded again.
+func (h *OnboardingHandler) UploadSellerDocument(c *gin.Context) {
+	slot, ok := sellerDocumentSlots[c.Param("type")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Unknown document type"))
+		return
+	}
+	userID := middleware.UserID(c)
+
+	// index selects which document to replace for fields holding several
+	index := -1
+	if raw := c.PostForm("index"); raw != "" {
+		i, err := strconv.Atoi(raw)
+		if err != nil || i < 0 || !slot.Multiple {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid document index"))
+			return
+		}
+		index = i
+	}
+
+	documentType := c.Param("type")
+	if raw := c.PostForm("documentType"); slot.Multiple && raw != "" {
+		if !slices.Contains(slot.DocumentTypes, raw) {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("documentType must be one of: "+strings.Join(slot.DocumentTypes, ", ")))
+			return
+		}
+		documentType = raw
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("File is required"))
+		return
+	}
+	if file.Size > maxDocumentSize {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("File must be 10MB or smaller"))
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to process file"))
+		return
+	}
+	defer src.Close()
+
+	// Trust the file contents rather than the client supplied Content-Type
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Failed to read file"))
+		return
+	}
+	mimeType := http.DetectContentType(head[:n])
+	if !allowedDocumentTypes[mimeType] {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Only JPEG, PNG and PDF files are allowed"))
+		return
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to process file"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	// Work out where the document goes before spending time on the upload
+	applications := h.DB.Collection("seller_applications")
+	var application models.SellerApplication
+	err = applications.FindOne(ctx, bson.M{
+		"userID": userID,
+		"status": bson.M{"$in": []string{models.ApplicationStatusPending, models.ApplicationStatusUnderReview}},
+	}).Decode(&application)
+	underReview := err == nil
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch application"))
+		return
+	}
+
+	if underReview {
+		if slot.Multiple && index < 0 {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse("index is required when re-uploading a document"))
+			return
+		}
+		existing := documentAt(applicationDocuments(&application), slot.Field, index)
+		if existing == nil || existing.VerificationStatus != "rejected" {
+			c.JSON(http.StatusConflict, utils.ErrorResponse("Only rejected documents can be re-uploaded"))
+			return
+		}
+	} else {
+		if submitted, err := h.sellerDraftSubmitted(ctx, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch draft"))
+			return
+		} else if submitted {
+			c.JSON(http.StatusConflict, utils.ErrorResponse("Seller application already submitted"))
+			return
+		}
+	}
+
+	cld, err := cloudinary.NewFromParams(
+		os.Getenv("CLOUDINARY_CLOUD_NAME"),
+		os.Getenv("CLOUDINARY_API_KEY"),
+		os.Getenv("CLOUDINARY_API_SECRET"),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to initialize Cloudinary"))
+		return
+	}
+	uploadResult, err := cld.Upload.Upload(ctx, src, uploader.UploadParams{
+		Folder: "sellers/documents/" + userID.Hex(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to upload file"))
+		return
+	}
+
+	document := models.VerificationDocument{
+		DocumentType:       documentType,
+		FileName:           file.Filename,
+		FileURL:            uploadResult.SecureURL,
+		ThumbnailURL:       models.DocumentThumbnailURL(uploadResult.SecureURL),
+		FileSize:           file.Size,
+		MimeType:           mimeType,
+		UploadedAt:         time.Now(),
+		VerificationStatus: "pending",
+	}
+
+	if underReview {
+		// The version check makes sure the document is still the rejected one
+		field := documentPath(slot, index)
+		res, err := applications.UpdateOne(ctx, bson.M{
+			"_id":                         application.ID,
+			"version":                     application.Version,
+			field + ".verificationStatus": "rejected",
+		}, bson.M{
+			"$set": bson.M{field: document, "updatedAt": time.Now()},
+			"$inc": bson.M{"version": 1},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to save document"))
+			return
+		}
+		if res.ModifiedCount == 0 {
+			c.JSON(http.StatusConflict, utils.ErrorResponse("Application was modified, reload and try again"))
+			return
+		}
+	} else {
+		field := "stepData.documents." + slot.Field
+		filter := bson.M{"userID": userID, "role": "vendor"}
+		update := bson.M{"$set": bson.M{field: document, "updatedAt": time.Now()}, "$inc": bson.M{"version": 1}}
+		if slot.Multiple {
+			if index >= 0 {
+				field = field + "." + strconv.Itoa(index)
+				filter[field] = bson.M{"$exists": true}
+				update["$set"] = bson.M{field: document, "updatedAt": time.Now()}
+			} else {
+				filter[field+"."+strconv.Itoa(maxDocumentsPerField-1)] = bson.M{"$exists": false}
+				update = bson.M{
+					"$push": bson.M{field: document},
+					"$set":  bson.M{"updatedAt": time.Now()},
+					"$inc":  bson.M{"version": 1},
+				}
+			}
+		}
+		res, err := h.DB.Collection("drafts").UpdateOne(ctx, filter, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to save document"))
+			return
+		}
+		if res.MatchedCount == 0 {
+			c.JSON(http.StatusBadRequest, utils.ErrorResponse(fmt.Sprintf("No seller draft, index out of range, or the limit of %d documents was reached", maxDocumentsPerField)))
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, utils.SuccessResponse("Document uploaded", gin.H{
+		"field":    slot.Field,
+		"document": document,
+	}))
+}
+
+type documentReviewInput struct {
+	Status  string `json:"status" validate:"required,oneof=verified rejected"`
+	Reason  string `json:"reason" validate:"required_if=Status rejected,max=500"`
+	Index   int    `json:"index" validate:"gte=0"`
+	Version int    `json:"version" validate:"required,min=1"`
+}
+
+// ReviewDocument verifies or rejects a single document on an application.
+func (h *SellerReviewHandler) ReviewDocument(c *gin.Context) {
+	appID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid application ID"))
+		return
+	}
+	slot, ok := sellerDocumentSlots[c.Param("type")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Unknown document type"))
+		return
+	}
+
+	var input documentReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := reviewValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	field := documentPath(slot, input.Index)
+	now := time.Now()
+	reviewerID := middleware.UserID(c)
+	set := bson.M{
+		field + ".verificationStatus": input.Status,
+		field + ".verifiedAt":         now,
+		field + ".verifiedBy":         reviewerID,
+		field + ".rejectionReason":    input.Reason,
+		"updatedAt":                   now,
+	}
+
+	applications := h.DB.Collection("seller_applications")
+	res, err := applications.UpdateOne(ctx, bson.M{
+		"_id":     appID,
+		"version": input.Version,
+		"status":  bson.M{"$in": []string{models.ApplicationStatusPending, models.ApplicationStatusUnderReview}},
+		field:     bson.M{"$exists": true},
+	}, bson.M{"$set": set, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to review document"))
+		return
+	}
+	if res.MatchedCount == 0 {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Document not found, application is closed, or it was modified by someone else"))
+		return
+	}
+
+	var application models.SellerApplication
+	if err := applications.FindOne(ctx, bson.M{"_id": appID}).Decode(&application); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch application"))
+		return
+	}
+
+	if input.Status == "rejected" {
+		var user models.User
+		if err := h.DB.Collection("users").FindOne(ctx, bson.M{"_id": application.UserID}).Decode(&user); err == nil {
+			notifyByEmail(user.Email, "A document on your Vendora seller application needs attention", user.Name, fmt.Sprintf(
+				"<p>Your %s document was rejected.</p><p>Reason: %s</p><p>Please upload a new one so we can continue reviewing your application.</p>",
+				html.EscapeString(strings.ReplaceAll(c.Param("type"), "_", " ")), html.EscapeString(input.Reason)))
+		}
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Document reviewed", gin.H{
+		"application": application,
+	}))
+}
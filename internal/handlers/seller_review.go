@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SellerReviewHandler struct {
+	DB *mongo.Database
+}
+
+func NewSellerReviewHandler(db *mongo.Database) *SellerReviewHandler {
+	return &SellerReviewHandler{DB: db}
+}
+
+var reviewValidator = validator.New()
+
+var (
+	errApplicationNotFound = errors.New("application not found")
+	errIllegalTransition   = errors.New("illegal status transition")
+	errVersionConflict     = errors.New("application was modified by someone else")
+)
+
+type transitionInput struct {
+	Status          string `json:"status" validate:"required,oneof=pending under_review approved rejected"`
+	Version         int    `json:"version" validate:"required,min=1"`
+	Notes           string `json:"notes" validate:"max=1000"`
+	RejectionReason string `json:"rejectionReason" validate:"required_if=Status rejected,max=500"`
+	ApprovedTier    string `json:"approvedTier" validate:"omitempty,oneof=individual verified business"`
+}
+
+// sellerTransition describes a requested status change. Version must match
+// the application's current version; ReviewerID is nil for system actions.
+type sellerTransition struct {
+	To              string
+	Version         int
+	ReviewerID      *primitive.ObjectID
+	Notes           string
+	RejectionReason string
+	ApprovedTier    string
+}
+
+// transitionSellerApplication applies a state machine transition using the
+// application's version for optimistic locking, records it in the review
+// history and applies the side effects of the final statuses in the same
+// transaction.
+func transitionSellerApplication(ctx context.Context, db *mongo.Database, appID primitive.ObjectID, t sellerTransition) (*models.SellerApplication, error) {
+	applications := db.Collection("seller_applications")
+
+	var current models.SellerApplication
+	if err := applications.FindOne(ctx, bson.M{"_id": appID}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errApplicationNotFound
+		}
+		return nil, err
+	}
+	if current.Version != t.Version {
+		return nil, errVersionConflict
+	}
+	if !models.CanTransitionSellerApplication(current.Status, t.To) {
+		return nil, fmt.Errorf("%w: %s -> %s", errIllegalTransition, current.Status, t.To)
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"status":    t.To,
+		"updatedAt": now,
+	}
+	if t.Notes != "" {
+		set["reviewNotes"] = t.Notes
+	}
+	if t.To == models.ApplicationStatusApproved || t.To == models.ApplicationStatusRejected {
+		set["reviewedAt"] = now
+		set["reviewedBy"] = t.ReviewerID
+	}
+	if t.To == models.ApplicationStatusApproved {
+		if t.ApprovedTier == "" {
+			t.ApprovedTier = current.RequestedTier
+		}
+		set["approvedTier"] = t.ApprovedTier
+	}
+	if t.To == models.ApplicationStatusRejected {
+		set["rejectionReason"] = t.RejectionReason
+	}
+
+	// The status change and its history entry are written together so the
+	// audit trail never misses a transition. Only the email waits for the
+	// commit.
+	var updated models.SellerApplication
+	var applicant *models.User
+	err := withTransaction(ctx, db, func(sc mongo.SessionContext) error {
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err := applications.FindOneAndUpdate(sc, bson.M{
+			"_id":     appID,
+			"version": t.Version,
+			"status":  current.Status,
+		}, bson.M{"$set": set, "$inc": bson.M{"version": 1}}, opts).Decode(&updated)
+		if err == mongo.ErrNoDocuments {
+			return errVersionConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		review := models.SellerApplicationReview{
+			ID:              primitive.NewObjectID(),
+			ApplicationID:   appID,
+			FromStatus:      current.Status,
+			ToStatus:        t.To,
+			ReviewerID:      t.ReviewerID,
+			ApprovedTier:    t.ApprovedTier,
+			Notes:           t.Notes,
+			RejectionReason: t.RejectionReason,
+			Version:         updated.Version,
+			CreatedAt:       now,
+		}
+		if _, err := db.Collection("seller_application_reviews").InsertOne(sc, review); err != nil {
+			return fmt.Errorf("failed to record review history: %w", err)
+		}
+
+		// The decision's effects on the applicant commit with it, so an
+		// approval is never left without its vendor account and role
+		switch t.To {
+		case models.ApplicationStatusApproved:
+			applicant, err = onSellerApplicationApproved(sc, db, &updated)
+		case models.ApplicationStatusRejected:
+			applicant, err = onSellerApplicationRejected(sc, db, &updated)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.To {
+	case models.ApplicationStatusApproved:
+		notifyByEmail(applicant.Email, "Your Vendora seller application was approved", applicant.Name, fmt.Sprintf(
+			"<p>Good news! <strong>%s</strong> has been approved as a <strong>%s</strong> seller. You can now start selling on Vendora.</p>",
+			html.EscapeString(updated.StoreName), html.EscapeString(updated.ApprovedTier)))
+	case models.ApplicationStatusRejected:
+		notifyByEmail(applicant.Email, "Update on your Vendora seller application", applicant.Name, fmt.Sprintf(
+			"<p>Unfortunately your application for <strong>%s</strong> was not approved.</p><p>Reason: %s</p><p>You can update your details and apply again.</p>",
+			html.EscapeString(updated.StoreName), html.EscapeString(updated.RejectionReason)))
+	}
+	return &updated, nil
+}
+
+// onSellerApplicationApproved provisions the vendor account on the approved
+// tier and makes the applicant a vendor. ctx should be a transaction's
+// session context.
+func onSellerApplicationApproved(ctx context.Context, db *mongo.Database, app *models.SellerApplication) (*models.User, error) {
+	if _, err := provisionVendorAccount(ctx, db, app.UserID, app.ID, app.ApprovedTier); err != nil {
+		return nil, fmt.Errorf("failed to provision vendor account: %w", err)
+	}
+
+	var user models.User
+	err := db.Collection("users").FindOneAndUpdate(ctx, bson.M{"_id": app.UserID}, bson.M{"$set": bson.M{
+		"role":         models.RoleVendor,
+		"vendorStatus": "approved",
+		"updatedAt":    time.Now(),
+	}}).Decode(&user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update applicant: %w", err)
+	}
+
+	// Unlock the onboarding draft so the vendor can later apply for a higher tier
+	if err := unlockSellerDraft(ctx, db, app); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// onSellerApplicationRejected records the rejection on the applicant. ctx
+// should be a transaction's session context.
+func onSellerApplicationRejected(ctx context.Context, db *mongo.Database, app *models.SellerApplication) (*models.User, error) {
+	var user models.User
+	err := db.Collection("users").FindOneAndUpdate(ctx, bson.M{
+		"_id":  app.UserID,
+		"role": bson.M{"$ne": models.RoleVendor},
+	}, bson.M{"$set": bson.M{"vendorStatus": "rejected", "updatedAt": time.Now()}}).Decode(&user)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, fmt.Errorf("failed to update applicant: %w", err)
+	}
+	if err == mongo.ErrNoDocuments {
+		// Existing vendors whose upgrade was rejected keep their status
+		if err := db.Collection("users").FindOne(ctx, bson.M{"_id": app.UserID}).Decode(&user); err != nil {
+			return nil, fmt.Errorf("failed to load applicant: %w", err)
+		}
+	}
+
+	// Unlock the onboarding draft so the seller can fix it and apply again
+	if err := unlockSellerDraft(ctx, db, app); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// unlockSellerDraft clears the submitted marker on the draft that produced
+// the application, making it editable and submittable again.
+func unlockSellerDraft(ctx context.Context, db *mongo.Database, app *models.SellerApplication) error {
+	if _, err := db.Collection("drafts").UpdateOne(ctx, bson.M{
+		"userID":        app.UserID,
+		"role":          "vendor",
+		"applicationID": app.ID,
+	}, bson.M{"$unset": bson.M{"submittedAt": "", "applicationID": ""}}); err != nil {
+		return fmt.Errorf("failed to unlock draft: %w", err)
+	}
+	return nil
+}
+
+// ListApplications returns the seller application review queue. sort=risk
+// puts the riskiest applications first, sort=-risk the safest.
+func (h *SellerReviewHandler) ListApplications(c *gin.Context) {
+	page, limit, skip := pageParams(c)
+
+	filter := bson.M{}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	if tier := c.Query("tier"); tier != "" {
+		filter["requestedTier"] = tier
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	collection := h.DB.Collection("seller_applications")
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch applications"))
+		return
+	}
+
+	// Oldest first by default so applications are reviewed in the order they arrived
+	sort := bson.D{{Key: "appliedAt", Value: 1}}
+	switch c.Query("sort") {
+	case "risk":
+		sort = bson.D{{Key: "riskScore", Value: -1}, {Key: "appliedAt", Value: 1}}
+	case "-risk":
+		sort = bson.D{{Key: "riskScore", Value: 1}, {Key: "appliedAt", Value: 1}}
+	case "newest":
+		sort = bson.D{{Key: "appliedAt", Value: -1}}
+	}
+	opts := options.Find().SetSort(sort).SetSkip(skip).SetLimit(limit)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch applications"))
+		return
+	}
+	applications := []models.SellerApplication{}
+	if err := cursor.All(ctx, &applications); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch applications"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Applications fetched successfully", gin.H{
+		"applications": applications,
+		"page":         page,
+		"limit":        limit,
+		"total":        total,
+	}))
+}
+
+// GetApplication returns an application with its full review history.
+func (h *SellerReviewHandler) GetApplication(c *gin.Context) {
+	appID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid application ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var application models.SellerApplication
+	if err := h.DB.Collection("seller_applications").FindOne(ctx, bson.M{"_id": appID}).Decode(&application); err != nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Application not found"))
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := h.DB.Collection("seller_application_reviews").Find(ctx, bson.M{"applicationID": appID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch review history"))
+		return
+	}
+	history := []models.SellerApplicationReview{}
+	if err := cursor.All(ctx, &history); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch review history"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Application fetched successfully", gin.H{
+		"application": application,
+		"history":     history,
+	}))
+}
+
+// TransitionApplication moves an application to a new status. The client
+// sends the version it last saw; a mismatch means someone else changed the
+// application in the meantime.
+func (h *SellerReviewHandler) TransitionApplication(c *gin.Context) {
+	appID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid application ID"))
+		return
+	}
+
+	var input transitionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := reviewValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	if input.ApprovedTier != "" && input.Status != models.ApplicationStatusApproved {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("approvedTier can only be set when approving"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	reviewerID := middleware.UserID(c)
+	application, err := transitionSellerApplication(ctx, h.DB, appID, sellerTransition{
+		To:              input.Status,
+		Version:         input.Version,
+		ReviewerID:      &reviewerID,
+		Notes:           input.Notes,
+		RejectionReason: input.RejectionReason,
+		ApprovedTier:    input.ApprovedTier,
+	})
+	switch {
+	case errors.Is(err, errApplicationNotFound):
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Application not found"))
+		return
+	case errors.Is(err, errIllegalTransition):
+		c.JSON(http.StatusUnprocessableEntity, utils.ErrorResponse(err.Error()))
+		return
+	case errors.Is(err, errVersionConflict):
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Application was modified by someone else, reload and try again"))
+		return
+	case err != nil:
+		logrus.WithError(err).WithField("applicationId", appID.Hex()).Error("Failed to update seller application")
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update application"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Application updated", gin.H{
+		"application": application,
+	}))
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type refreshInput struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// issueTokens mints an access token and stores a new refresh token session.
+// A zero familyID starts a new token family (a fresh login).
+func (h *AuthHandler) issueTokens(ctx context.Context, c *gin.Context, user models.User, sessionID, familyID primitive.ObjectID) (string, string, error) {
+	accessToken, err := utils.GenerateToken(user.ID.Hex(), user.Role, utils.AccessTokenTTL)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	if sessionID.IsZero() {
+		sessionID = primitive.NewObjectID()
+	}
+	if familyID.IsZero() {
+		familyID = sessionID
+	}
+	now := time.Now()
+	session := models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshToken),
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+		ExpiresAt: now.Add(utils.RefreshTokenTTL),
+		CreatedAt: now,
+	}
+	if _, err := h.DB.Collection("sessions").InsertOne(ctx, session); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+func (h *AuthHandler) revokeSessions(ctx context.Context, filter bson.M, reason string) error {
+	filter["revokedAt"] = bson.M{"$exists": false}
+	_, err := h.DB.Collection("sessions").UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{"revokedAt": time.Now(), "revokeReason": reason},
+	})
+	return err
+}
+
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var input refreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	sessions := h.DB.Collection("sessions")
+	var session models.Session
+	if err := sessions.FindOne(ctx, bson.M{"tokenHash": utils.HashToken(input.RefreshToken)}).Decode(&session); err != nil {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Invalid refresh token"))
+		return
+	}
+
+	if session.RevokedAt != nil {
+		if session.RevokeReason == "rotated" {
+			h.handleRefreshReuse(ctx, session)
+			c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Refresh token reuse detected, please log in again"))
+			return
+		}
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Session has been revoked"))
+		return
+	}
+	if time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Refresh token has expired"))
+		return
+	}
+
+	// Rotate: revoke the presented token and point it at its replacement.
+	// The filter on revokedAt makes sure only one concurrent refresh wins.
+	nextID := primitive.NewObjectID()
+	res, err := sessions.UpdateOne(ctx, bson.M{
+		"_id":       session.ID,
+		"revokedAt": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{
+		"revokedAt":    time.Now(),
+		"revokeReason": "rotated",
+		"replacedBy":   nextID,
+	}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to refresh session"))
+		return
+	}
+	if res.ModifiedCount == 0 {
+		h.handleRefreshReuse(ctx, session)
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("Refresh token reuse detected, please log in again"))
+		return
+	}
+
+	// Reload the user so role changes (e.g. vendor approval) show up in the new access token
+	var user models.User
+	if err := h.DB.Collection("users").FindOne(ctx, bson.M{"_id": session.UserID}).Decode(&user); err != nil {
+		c.JSON(http.StatusUnauthorized, utils.ErrorResponse("User not found"))
+		return
+	}
+
+	accessToken, refreshToken, err := h.issueTokens(ctx, c, user, nextID, session.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to generate tokens"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Token refreshed", gin.H{
+		"accessToken":  accessToken,
+		"refreshToken": refreshToken,
+	}))
+}
+
+func (h *AuthHandler) handleRefreshReuse(ctx context.Context, session models.Session) {
+	logrus.WithFields(logrus.Fields{
+		"userId":   session.UserID.Hex(),
+		"familyId": session.FamilyID.Hex(),
+	}).Warn("Refresh token reuse detected, revoking token family")
+	if err := h.revokeSessions(ctx, bson.M{"familyId": session.FamilyID}, "reuse_detected"); err != nil {
+		logrus.WithError(err).Error("Failed to revoke token family")
+	}
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var input refreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := validate.Struct(input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse(err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var session models.Session
+	err := h.DB.Collection("sessions").FindOne(ctx, bson.M{"tokenHash": utils.HashToken(input.RefreshToken)}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		// Logging out with an unknown token is not an error for the client
+		c.JSON(http.StatusOK, utils.SuccessResponse("Logged out", nil))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to log out"))
+		return
+	}
+
+	if err := h.revokeSessions(ctx, bson.M{"familyId": session.FamilyID}, "logout"); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to log out"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Logged out", nil))
+}
+
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID := middleware.UserID(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := h.revokeSessions(ctx, bson.M{"userId": userID}, "logout_all"); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to log out"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Logged out of all sessions", nil))
+}
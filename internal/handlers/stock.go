@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	errProductUnavailable = errors.New("product is no longer available")
+	errVariantRequired    = errors.New("choose a variant of this product")
+	errVariantNotFound    = errors.New("variant not found")
+	errOutOfStock         = errors.New("not enough stock")
+)
+
+// stockLine identifies what is being sold: a simple product, or one variant
+// of a product with variants.
+type stockLine struct {
+	ProductID primitive.ObjectID
+	VariantID *primitive.ObjectID
+	Quantity  int
+}
+
+// sellable returns the unit price, available stock and SKU for a product or
+// one of its variants.
+func sellable(product *models.Product, variantID *primitive.ObjectID) (price float64, stock int, sku string, err error) {
+	if !product.HasVariants() {
+		if variantID != nil {
+			return 0, 0, "", errVariantNotFound
+		}
+		return product.Price, product.Stock, "", nil
+	}
+	if variantID == nil {
+		return 0, 0, "", errVariantRequired
+	}
+	variant := product.Variant(*variantID)
+	if variant == nil {
+		return 0, 0, "", errVariantNotFound
+	}
+	return variant.Price, variant.Stock, variant.SKU, nil
+}
+
+// reserveStock atomically takes stock for a line, failing with
+// errOutOfStock rather than letting stock go negative. Variant stock and the
+// product's total stock move together, and the units count towards the
+// product's sales. ctx may be a transaction's session context.
+func reserveStock(ctx context.Context, db *mongo.Database, line stockLine) error {
+	filter := bson.M{"_id": line.ProductID, "deletedAt": notDeleted}
+	inc := bson.M{"stock": -line.Quantity, "salesCount": line.Quantity}
+	if line.VariantID != nil {
+		filter["variants"] = bson.M{"$elemMatch": bson.M{"_id": *line.VariantID, "stock": bson.M{"$gte": line.Quantity}}}
+		inc["variants.$.stock"] = -line.Quantity
+	} else {
+		filter["variants.0"] = bson.M{"$exists": false}
+		filter["stock"] = bson.M{"$gte": line.Quantity}
+	}
+
+	res, err := db.Collection("products").UpdateOne(ctx, filter, bson.M{"$inc": inc})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		var product models.Product
+		if err := db.Collection("products").FindOne(ctx, bson.M{"_id": line.ProductID, "deletedAt": notDeleted}).Decode(&product); err == mongo.ErrNoDocuments {
+			return errProductUnavailable
+		} else if err != nil {
+			return err
+		}
+		if _, _, _, err := sellable(&product, line.VariantID); err != nil {
+			return err
+		}
+		return errOutOfStock
+	}
+	return nil
+}
+
+// releaseStock puts reserved stock back and takes the units off the
+// product's sales, e.g. when an order is cancelled. It also applies to
+// deleted products so their counts stay consistent.
+func releaseStock(ctx context.Context, db *mongo.Database, line stockLine) error {
+	filter := bson.M{"_id": line.ProductID}
+	inc := bson.M{"stock": line.Quantity, "salesCount": -line.Quantity}
+	if line.VariantID != nil {
+		filter["variants._id"] = *line.VariantID
+		inc["variants.$.stock"] = line.Quantity
+	}
+	_, err := db.Collection("products").UpdateOne(ctx, filter, bson.M{"$inc": inc})
+	return err
+}
+
+// syncVariantTotals recomputes a product's price and stock from its
+// variants in a single atomic update.
+func syncVariantTotals(ctx context.Context, db *mongo.Database, productID primitive.ObjectID) error {
+	_, err := db.Collection("products").UpdateOne(ctx, bson.M{
+		"_id":        productID,
+		"variants.0": bson.M{"$exists": true},
+	}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"price": bson.M{"$min": "$variants.price"},
+			"stock": bson.M{"$sum": "$variants.stock"},
+		}}},
+	})
+	return err
+}
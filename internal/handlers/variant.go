@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type variantUpdateInput struct {
+	SKU    *string   `json:"sku" validate:"omitempty,min=1,max=64"`
+	Price  *float64  `json:"price" validate:"omitempty,gt=0"`
+	Stock  *int      `json:"stock" validate:"omitempty,gte=0"`
+	Images *[]string `json:"images" validate:"omitempty,max=10,dive,url"`
+}
+
+// loadVendorProduct fetches one of the calling vendor's products, writing
+// the error response and returning nil if it cannot.
+func (h *ProductHandler) loadVendorProduct(ctx context.Context, c *gin.Context) *models.Product {
+	productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid product ID"))
+		return nil
+	}
+	var product models.Product
+	err = h.DB.Collection("products").FindOne(ctx, bson.M{
+		"_id":       productID,
+		"vendorId":  middleware.UserID(c),
+		"deletedAt": notDeleted,
+	}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Product not found"))
+		return nil
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch product"))
+		return nil
+	}
+	return &product
+}
+
+func (h *ProductHandler) respondWithProduct(ctx context.Context, c *gin.Context, status int, message string, productID primitive.ObjectID) {
+	if err := syncVariantTotals(ctx, h.DB, productID); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update product totals"))
+		return
+	}
+	var product models.Product
+	if err := h.DB.Collection("products").FindOne(ctx, bson.M{"_id": productID}).Decode(&product); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch product"))
+		return
+	}
+	c.JSON(status, utils.SuccessResponse(message, gin.H{"product": product}))
+}
+
+// AddVariant adds a variant to a product. The product's options must already
+// be defined; new values for an existing option are added to it. The
+// product's own stock is dropped when its first variant is added, since
+// stock is tracked per variant from then on.
+func (h *ProductHandler) AddVariant(c *gin.Context) {
+	var input variantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	product := h.loadVendorProduct(ctx, c)
+	if product == nil {
+		return
+	}
+	if len(product.Options) == 0 {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Set the product's options before adding variants"))
+		return
+	}
+
+	variant := input.variant()
+	for i, option := range product.Options {
+		if value, ok := variant.Options[option.Name]; ok && !slices.Contains(option.Values, value) {
+			product.Options[i].Values = append(product.Options[i].Values, value)
+		}
+	}
+	product.Variants = append(product.Variants, variant)
+	if err := product.ValidateVariants(); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	// Matching on updatedAt makes concurrent edits by the vendor fail rather
+	// than overwrite each other; stock reservations do not touch it.
+	res, err := h.DB.Collection("products").UpdateOne(ctx, bson.M{
+		"_id":       product.ID,
+		"updatedAt": product.UpdatedAt,
+		"deletedAt": notDeleted,
+	}, bson.M{
+		"$push": bson.M{"variants": variant},
+		"$set":  bson.M{"options": product.Options, "updatedAt": time.Now()},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("You already use this SKU on another product"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to add variant"))
+		return
+	}
+	if res.MatchedCount == 0 {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Product was modified, reload and try again"))
+		return
+	}
+
+	h.respondWithProduct(ctx, c, http.StatusCreated, "Variant added successfully", product.ID)
+}
+
+// UpdateVariant changes a variant's SKU, price, stock or images. Its option
+// values cannot change; add a new variant instead.
+func (h *ProductHandler) UpdateVariant(c *gin.Context) {
+	variantID, err := primitive.ObjectIDFromHex(c.Param("variantId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid variant ID"))
+		return
+	}
+	var input variantUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	product := h.loadVendorProduct(ctx, c)
+	if product == nil {
+		return
+	}
+	if product.Variant(variantID) == nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Variant not found"))
+		return
+	}
+
+	set := bson.M{"updatedAt": time.Now()}
+	if input.SKU != nil {
+		sku := strings.TrimSpace(*input.SKU)
+		for _, v := range product.Variants {
+			if v.ID != variantID && v.SKU == sku {
+				c.JSON(http.StatusConflict, utils.ErrorResponse("Another variant already uses this SKU"))
+				return
+			}
+		}
+		set["variants.$.sku"] = sku
+	}
+	if input.Price != nil {
+		set["variants.$.price"] = *input.Price
+	}
+	if input.Stock != nil {
+		set["variants.$.stock"] = *input.Stock
+	}
+	if input.Images != nil {
+		set["variants.$.images"] = *input.Images
+	}
+
+	res, err := h.DB.Collection("products").UpdateOne(ctx, bson.M{
+		"_id":          product.ID,
+		"deletedAt":    notDeleted,
+		"variants._id": variantID,
+	}, bson.M{"$set": set})
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("You already use this SKU on another product"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update variant"))
+		return
+	}
+	if res.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Variant not found"))
+		return
+	}
+
+	h.respondWithProduct(ctx, c, http.StatusOK, "Variant updated successfully", product.ID)
+}
+
+// DeleteVariant removes a variant. A product keeps at least one variant once
+// it has any; delete the product instead.
+func (h *ProductHandler) DeleteVariant(c *gin.Context) {
+	variantID, err := primitive.ObjectIDFromHex(c.Param("variantId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid variant ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	product := h.loadVendorProduct(ctx, c)
+	if product == nil {
+		return
+	}
+	if product.Variant(variantID) == nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Variant not found"))
+		return
+	}
+
+	res, err := h.DB.Collection("products").UpdateOne(ctx, bson.M{
+		"_id":        product.ID,
+		"deletedAt":  notDeleted,
+		"variants.1": bson.M{"$exists": true},
+	}, bson.M{
+		"$pull": bson.M{"variants": bson.M{"_id": variantID}},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to delete variant"))
+		return
+	}
+	if res.MatchedCount == 0 {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("A product must keep at least one variant"))
+		return
+	}
+
+	h.respondWithProduct(ctx, c, http.StatusOK, "Variant deleted successfully", product.ID)
+}
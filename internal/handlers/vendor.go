@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"context"
+	"fmt"
+	"html"
+	"io"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
 	"github.com/developia-II/ecommerce-backend/internal/models"
 	"github.com/developia-II/ecommerce-backend/utils"
 	"github.com/gin-gonic/gin"
@@ -13,6 +16,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type VendorHandler struct {
@@ -43,31 +47,12 @@ type VendorApplication struct {
 
 // ApplyForVendor handles vendor application submissions
 func (h *VendorHandler) ApplyForVendor(c *gin.Context) {
-	// Get user ID from JWT token
-	authHeader := c.Request.Header.Get("Authorization")
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Missing or invalid token"))
-		return
-	}
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-	claims, err := utils.VerifyToken(tokenString)
-	if err != nil {
-		c.JSON(http.StatusForbidden, utils.ErrorResponse("Invalid or expired token"))
-		return
-	}
-
-	userID := claims.UserID
+	objectId := middleware.UserID(c)
 	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Second*10)
 	defer cancel()
 
 	// Get user from database
 	collection := h.DB.Collection("users")
-	objectId, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid user ID"))
-		return
-	}
 
 	var user models.User
 	filter := bson.M{"_id": objectId}
@@ -102,6 +87,7 @@ func (h *VendorHandler) ApplyForVendor(c *gin.Context) {
 
 	// Create vendor application document
 	vendorApp := models.VendorApplication{
+		ID:                  primitive.NewObjectID(),
 		UserID:              objectId,
 		BusinessName:        application.BusinessName,
 		BusinessType:        application.BusinessType,
@@ -124,7 +110,7 @@ func (h *VendorHandler) ApplyForVendor(c *gin.Context) {
 
 	// Save to vendor_applications collection
 	appCollection := h.DB.Collection("vendor_applications")
-	_, err = appCollection.InsertOne(ctx, vendorApp)
+	_, err := appCollection.InsertOne(ctx, vendorApp)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to submit application"))
 		return
@@ -149,3 +135,176 @@ func (h *VendorHandler) ApplyForVendor(c *gin.Context) {
 		"message":       "Your application is under review. You'll be notified once it's approved.",
 	}))
 }
+
+type vendorReviewInput struct {
+	Notes string `json:"notes" validate:"max=1000"`
+}
+
+// ListVendorApplications lists legacy vendor applications for admins,
+// optionally filtered by status.
+func (h *VendorHandler) ListVendorApplications(c *gin.Context) {
+	page, limit, skip := pageParams(c)
+
+	filter := bson.M{}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	collection := h.DB.Collection("vendor_applications")
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch applications"))
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "appliedAt", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch applications"))
+		return
+	}
+	applications := []models.VendorApplication{}
+	if err := cursor.All(ctx, &applications); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch applications"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Applications fetched successfully", gin.H{
+		"applications": applications,
+		"page":         page,
+		"limit":        limit,
+		"total":        total,
+	}))
+}
+
+// GetVendorApplication returns a single application together with the applicant.
+func (h *VendorHandler) GetVendorApplication(c *gin.Context) {
+	appID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid application ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var application models.VendorApplication
+	if err := h.DB.Collection("vendor_applications").FindOne(ctx, bson.M{"_id": appID}).Decode(&application); err != nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Application not found"))
+		return
+	}
+
+	var applicant models.User
+	if err := h.DB.Collection("users").FindOne(ctx, bson.M{"_id": application.UserID}).Decode(&applicant); err != nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Applicant not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Application fetched successfully", gin.H{
+		"application": application,
+		"applicant": gin.H{
+			"id":           applicant.ID.Hex(),
+			"name":         applicant.Name,
+			"email":        applicant.Email,
+			"role":         applicant.Role,
+			"vendorStatus": applicant.VendorStatus,
+		},
+	}))
+}
+
+func (h *VendorHandler) ApproveVendorApplication(c *gin.Context) {
+	h.reviewVendorApplication(c, "approved")
+}
+
+func (h *VendorHandler) RejectVendorApplication(c *gin.Context) {
+	h.reviewVendorApplication(c, "rejected")
+}
+
+// reviewVendorApplication moves a pending application to its final status,
+// updates the applicant and lets them know the outcome by email.
+func (h *VendorHandler) reviewVendorApplication(c *gin.Context, status string) {
+	appID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid application ID"))
+		return
+	}
+
+	// The body is optional when approving
+	var input vendorReviewInput
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := vendorValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	if status == "rejected" && input.Notes == "" {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Notes are required when rejecting an application"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	appCollection := h.DB.Collection("vendor_applications")
+	update := bson.M{
+		"$set": bson.M{
+			"status":      status,
+			"reviewedAt":  now,
+			"reviewedBy":  middleware.UserID(c).Hex(),
+			"reviewNotes": input.Notes,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// Only pending applications can be reviewed, so two admins cannot both act on one
+	var application models.VendorApplication
+	err = appCollection.FindOneAndUpdate(ctx, bson.M{"_id": appID, "status": "pending"}, update, opts).Decode(&application)
+	if err == mongo.ErrNoDocuments {
+		if count, _ := appCollection.CountDocuments(ctx, bson.M{"_id": appID}); count == 0 {
+			c.JSON(http.StatusNotFound, utils.ErrorResponse("Application not found"))
+			return
+		}
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Application has already been reviewed"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to review application"))
+		return
+	}
+
+	userUpdate := bson.M{"vendorStatus": status, "updatedAt": now}
+	if status == "approved" {
+		// Legacy applications carry no tier, so sellers start on the individual tier
+		if _, err := provisionVendorAccount(ctx, h.DB, application.UserID, application.ID, models.TierIndividual); err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to create vendor account"))
+			return
+		}
+		userUpdate["role"] = models.RoleVendor
+	}
+	var user models.User
+	err = h.DB.Collection("users").FindOneAndUpdate(ctx, bson.M{"_id": application.UserID}, bson.M{"$set": userUpdate}).Decode(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update applicant"))
+		return
+	}
+
+	if status == "approved" {
+		notifyByEmail(user.Email, "Your Vendora vendor application was approved", user.Name, fmt.Sprintf(
+			"<p>Good news! Your application for <strong>%s</strong> has been approved. You can now start selling on Vendora.</p>",
+			html.EscapeString(application.BusinessName)))
+	} else {
+		notifyByEmail(user.Email, "Update on your Vendora vendor application", user.Name, fmt.Sprintf(
+			"<p>Unfortunately your application for <strong>%s</strong> was not approved.</p><p>Reviewer notes: %s</p>",
+			html.EscapeString(application.BusinessName), html.EscapeString(input.Notes)))
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Application "+status, gin.H{
+		"application": application,
+	}))
+}
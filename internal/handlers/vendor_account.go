@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type VendorAccountHandler struct {
+	DB *mongo.Database
+}
+
+func NewVendorAccountHandler(db *mongo.Database) *VendorAccountHandler {
+	return &VendorAccountHandler{DB: db}
+}
+
+// loadTierPolicy returns the stored policy for a tier, falling back to the
+// built-in defaults when none has been configured.
+func loadTierPolicy(ctx context.Context, db *mongo.Database, tier string) (models.TierPolicy, error) {
+	var policy models.TierPolicy
+	err := db.Collection("tier_policies").FindOne(ctx, bson.M{"_id": tier}).Decode(&policy)
+	if err == mongo.ErrNoDocuments {
+		policy, ok := models.DefaultTierPolicies[tier]
+		if !ok {
+			return models.TierPolicy{}, fmt.Errorf("unknown tier %q", tier)
+		}
+		return policy, nil
+	}
+	return policy, err
+}
+
+func tierLimits(policy models.TierPolicy) bson.M {
+	return bson.M{
+		"tier":            policy.Tier,
+		"maxProducts":     policy.MaxProducts,
+		"maxMonthlySales": policy.MaxMonthlySales,
+		"transactionFee":  policy.TransactionFee,
+		"payoutHoldDays":  policy.PayoutHoldDays,
+	}
+}
+
+// provisionVendorAccount creates the VendorAccount for an approved
+// application on the given tier. If the seller already has an account (a
+// tier upgrade application) the account is moved to the new tier instead.
+func provisionVendorAccount(ctx context.Context, db *mongo.Database, userID, applicationID primitive.ObjectID, tier string) (*models.VendorAccount, error) {
+	accounts := db.Collection("vendor_accounts")
+	var existing models.VendorAccount
+	err := accounts.FindOne(ctx, bson.M{"userID": userID}).Decode(&existing)
+	if err == nil {
+		return changeVendorTier(ctx, db, &existing, tier, &applicationID)
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	policy, err := loadTierPolicy(ctx, db, tier)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	account := models.VendorAccount{
+		ID:              primitive.NewObjectID(),
+		UserID:          userID,
+		ApplicationID:   applicationID,
+		Tier:            policy.Tier,
+		MaxProducts:     policy.MaxProducts,
+		MaxMonthlySales: policy.MaxMonthlySales,
+		TransactionFee:  policy.TransactionFee,
+		PayoutHoldDays:  policy.PayoutHoldDays,
+		SalesPeriod:     models.SalesPeriodOf(now),
+		TrustScore:      50,
+		Status:          "active",
+		IsVerified:      policy.Tier != models.TierIndividual,
+		ActivatedAt:     now,
+		UpdatedAt:       now,
+	}
+	if _, err := accounts.InsertOne(ctx, account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// changeVendorTier applies a tier's limits to an existing account, stamping
+// TierUpgradedAt when the move is an upgrade. applicationID links the account
+// to the application that justified the change, if any.
+func changeVendorTier(ctx context.Context, db *mongo.Database, current *models.VendorAccount, tier string, applicationID *primitive.ObjectID) (*models.VendorAccount, error) {
+	policy, err := loadTierPolicy(ctx, db, tier)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	set := tierLimits(policy)
+	if models.IsTierUpgrade(current.Tier, policy.Tier) {
+		set["tierUpgradedAt"] = now
+	}
+	set["isVerified"] = policy.Tier != models.TierIndividual
+	set["updatedAt"] = now
+	if applicationID != nil {
+		set["applicationID"] = *applicationID
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var account models.VendorAccount
+	if err := db.Collection("vendor_accounts").FindOneAndUpdate(ctx, bson.M{"_id": current.ID}, bson.M{"$set": set}, opts).Decode(&account); err != nil {
+		return nil, err
+	}
+	if err := lowerLimitAlerts(ctx, db, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetMyAccount returns the calling vendor's account with its limits and usage.
+func (h *VendorAccountHandler) GetMyAccount(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var account models.VendorAccount
+	if err := h.DB.Collection("vendor_accounts").FindOne(ctx, bson.M{"userID": middleware.UserID(c)}).Decode(&account); err != nil {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Vendor account not found"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Vendor account fetched successfully", gin.H{
+		"id":      account.ID.Hex(),
+		"account": account,
+	}))
+}
+
+// ChangeAccountTier lets an admin move a vendor account to another tier.
+func (h *VendorAccountHandler) ChangeAccountTier(c *gin.Context) {
+	accountID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid account ID"))
+		return
+	}
+
+	var input struct {
+		Tier string `json:"tier" validate:"required,oneof=individual verified business"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := reviewValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var current models.VendorAccount
+	err = h.DB.Collection("vendor_accounts").FindOne(ctx, bson.M{"_id": accountID}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Vendor account not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch vendor account"))
+		return
+	}
+
+	account, err := changeVendorTier(ctx, h.DB, &current, input.Tier, nil)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Vendor account not found"))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to change tier"))
+		return
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Vendor tier updated", gin.H{"account": account}))
+}
+
+// ListTierPolicies returns the effective policy for every tier.
+func (h *VendorAccountHandler) ListTierPolicies(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	policies := []models.TierPolicy{}
+	for _, tier := range []string{models.TierIndividual, models.TierVerified, models.TierBusiness} {
+		policy, err := loadTierPolicy(ctx, h.DB, tier)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch tier policies"))
+			return
+		}
+		policies = append(policies, policy)
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Tier policies fetched successfully", gin.H{"policies": policies}))
+}
+
+// UpdateTierPolicy stores a tier policy. With applyToExisting set, accounts
+// already on the tier get the new limits too; otherwise only new approvals
+// and tier changes use them.
+func (h *VendorAccountHandler) UpdateTierPolicy(c *gin.Context) {
+	var input struct {
+		models.TierPolicy
+		ApplyToExisting bool `json:"applyToExisting"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	policy := input.TierPolicy
+	policy.Tier = c.Param("tier")
+	if err := reviewValidator.Struct(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	adminID := middleware.UserID(c)
+	policy.UpdatedAt = time.Now()
+	policy.UpdatedBy = &adminID
+	opts := options.Replace().SetUpsert(true)
+	if _, err := h.DB.Collection("tier_policies").ReplaceOne(ctx, bson.M{"_id": policy.Tier}, policy, opts); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to save tier policy"))
+		return
+	}
+
+	var updated int64
+	if input.ApplyToExisting {
+		set := tierLimits(policy)
+		set["updatedAt"] = time.Now()
+		res, err := h.DB.Collection("vendor_accounts").UpdateMany(ctx, bson.M{"tier": policy.Tier}, bson.M{"$set": set})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Policy saved but failed to update existing accounts"))
+			return
+		}
+		updated = res.ModifiedCount
+
+		// Higher limits may put accounts back under warnings already sent
+		cursor, err := h.DB.Collection("vendor_accounts").Find(ctx, bson.M{
+			"tier": policy.Tier,
+			"$or": []bson.M{
+				{"productLimitAlert": bson.M{"$gt": 0}},
+				{"salesLimitAlert": bson.M{"$gt": 0}},
+			},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Policy saved but failed to update existing accounts"))
+			return
+		}
+		var alerted []models.VendorAccount
+		if err := cursor.All(ctx, &alerted); err != nil {
+			c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Policy saved but failed to update existing accounts"))
+			return
+		}
+		for i := range alerted {
+			if err := lowerLimitAlerts(ctx, h.DB, &alerted[i]); err != nil {
+				c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Policy saved but failed to update existing accounts"))
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, utils.SuccessResponse("Tier policy updated", gin.H{
+		"policy":          policy,
+		"accountsUpdated": updated,
+	}))
+}
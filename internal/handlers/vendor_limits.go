@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	errVendorAccountNotFound = errors.New("vendor account not found")
+	errVendorAccountInactive = errors.New("vendor account is not active")
+	errProductLimitReached   = errors.New("product limit reached for your tier")
+	errSalesLimitReached     = errors.New("monthly sales limit reached for your tier")
+)
+
+// Usage warnings are sent once per threshold, highest first.
+var limitWarningThresholds = []int{100, 80}
+
+// reserveProductSlot atomically counts a new product against the vendor's
+// MaxProducts. It fails with errProductLimitReached when the quota is used up.
+func reserveProductSlot(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) (*models.VendorAccount, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var account models.VendorAccount
+	err := db.Collection("vendor_accounts").FindOneAndUpdate(ctx, bson.M{
+		"userID": userID,
+		"status": "active",
+		"$expr":  bson.M{"$lt": bson.A{"$productCount", "$maxProducts"}},
+	}, bson.M{
+		"$inc": bson.M{"productCount": 1},
+		"$set": bson.M{"updatedAt": time.Now()},
+	}, opts).Decode(&account)
+	if err == mongo.ErrNoDocuments {
+		return nil, explainQuotaFailure(ctx, db, userID, errProductLimitReached)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	go warnOnLimitUsage(db, account)
+	return &account, nil
+}
+
+// releaseProductSlot gives a product slot back, e.g. when a product is deleted.
+func releaseProductSlot(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) error {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var account models.VendorAccount
+	err := db.Collection("vendor_accounts").FindOneAndUpdate(ctx, bson.M{
+		"userID":       userID,
+		"productCount": bson.M{"$gt": 0},
+	}, bson.M{
+		"$inc": bson.M{"productCount": -1},
+		"$set": bson.M{"updatedAt": time.Now()},
+	}, opts).Decode(&account)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return lowerLimitAlerts(ctx, db, &account)
+}
+
+// recordVendorSale atomically adds a sale to the vendor's monthly total and
+// counts the order towards TotalOrders, failing with errSalesLimitReached
+// if it would exceed MaxMonthlySales. A sale in a new month starts the
+// counter over even if the monthly reset job has not run yet. ctx may be a
+// transaction's session context, so the caller is responsible for calling
+// warnOnLimitUsage once the sale is committed.
+func recordVendorSale(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, amount float64) (*models.VendorAccount, error) {
+	now := time.Now()
+	period := models.SalesPeriodOf(now)
+	samePeriod := bson.M{"$eq": bson.A{"$salesPeriod", period}}
+	monthSales := bson.M{"$cond": bson.A{samePeriod, bson.M{"$add": bson.A{"$currentMonthSales", amount}}, amount}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"salesLimitAlert":   bson.M{"$cond": bson.A{samePeriod, "$salesLimitAlert", 0}},
+			"currentMonthSales": monthSales,
+			"salesPeriod":       period,
+			"totalSales":        bson.M{"$add": bson.A{"$totalSales", amount}},
+			"totalOrders":       bson.M{"$add": bson.A{"$totalOrders", 1}},
+			"lastSaleAt":        now,
+			"updatedAt":         now,
+		}}},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var account models.VendorAccount
+	err := db.Collection("vendor_accounts").FindOneAndUpdate(ctx, bson.M{
+		"userID": userID,
+		"status": "active",
+		"$expr":  bson.M{"$lte": bson.A{monthSales, "$maxMonthlySales"}},
+	}, pipeline, opts).Decode(&account)
+	if err == mongo.ErrNoDocuments {
+		return nil, explainQuotaFailure(ctx, db, userID, errSalesLimitReached)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// reverseVendorSale takes back a sale, e.g. after a cancellation or refund.
+// The monthly counter is only reduced if it still covers the sale's month,
+// and a sales warning it drops back under can be sent again.
+// wholeOrder also takes the order off TotalOrders, for a sub-order that was
+// cancelled or refunded in full.
+func reverseVendorSale(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, amount float64, soldAt time.Time, wholeOrder bool) error {
+	accounts := db.Collection("vendor_accounts")
+	now := time.Now()
+	inc := bson.M{"totalSales": -amount}
+	if wholeOrder {
+		inc["totalOrders"] = -1
+	}
+	if _, err := accounts.UpdateOne(ctx, bson.M{"userID": userID}, bson.M{
+		"$inc": inc,
+		"$set": bson.M{"updatedAt": now},
+	}); err != nil {
+		return err
+	}
+
+	period := models.SalesPeriodOf(soldAt)
+	if period != models.SalesPeriodOf(now) {
+		return nil
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var account models.VendorAccount
+	err := accounts.FindOneAndUpdate(ctx, bson.M{"userID": userID, "salesPeriod": period}, bson.M{
+		"$inc": bson.M{"currentMonthSales": -amount},
+	}, opts).Decode(&account)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return lowerLimitAlerts(ctx, db, &account)
+}
+
+// explainQuotaFailure works out why a conditional quota update matched
+// nothing: no account, an inactive account, or the quota itself.
+func explainQuotaFailure(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, quotaErr error) error {
+	var account models.VendorAccount
+	err := db.Collection("vendor_accounts").FindOne(ctx, bson.M{"userID": userID}).Decode(&account)
+	if err == mongo.ErrNoDocuments {
+		return errVendorAccountNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if account.Status != "active" {
+		return errVendorAccountInactive
+	}
+	return quotaErr
+}
+
+// warnOnLimitUsage emails the vendor the first time product or monthly sales
+// usage crosses 80% and 100% of the tier limit. The alert level is raised
+// with a conditional update so each warning is only sent once.
+func warnOnLimitUsage(db *mongo.Database, account models.VendorAccount) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if account.MaxProducts > 0 {
+		usage := float64(account.ProductCount) / float64(account.MaxProducts) * 100
+		if level := crossedThreshold(usage, account.ProductLimitAlert); level > 0 &&
+			raiseLimitAlert(ctx, db, account.ID, "productLimitAlert", level) {
+			sendLimitWarning(ctx, db, account.UserID, "product", level, fmt.Sprintf("%d of %d products", account.ProductCount, account.MaxProducts))
+		}
+	}
+	if account.MaxMonthlySales > 0 {
+		usage := account.CurrentMonthSales / account.MaxMonthlySales * 100
+		if level := crossedThreshold(usage, account.SalesLimitAlert); level > 0 &&
+			raiseLimitAlert(ctx, db, account.ID, "salesLimitAlert", level) {
+			sendLimitWarning(ctx, db, account.UserID, "monthly sales", level, fmt.Sprintf("%.2f of %.2f this month", account.CurrentMonthSales, account.MaxMonthlySales))
+		}
+	}
+}
+
+// lowerLimitAlerts brings the alert levels back down when usage has dropped
+// below the warnings already sent, e.g. after deleting products or moving to
+// a higher tier, so crossing the threshold again warns the vendor again.
+func lowerLimitAlerts(ctx context.Context, db *mongo.Database, account *models.VendorAccount) error {
+	set := bson.M{}
+	if account.MaxProducts > 0 {
+		usage := float64(account.ProductCount) / float64(account.MaxProducts) * 100
+		if level := reachedThreshold(usage); level < account.ProductLimitAlert {
+			set["productLimitAlert"] = level
+		}
+	}
+	if account.MaxMonthlySales > 0 {
+		usage := account.CurrentMonthSales / account.MaxMonthlySales * 100
+		if level := reachedThreshold(usage); level < account.SalesLimitAlert {
+			set["salesLimitAlert"] = level
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	_, err := db.Collection("vendor_accounts").UpdateOne(ctx, bson.M{"_id": account.ID}, bson.M{"$set": set})
+	return err
+}
+
+func crossedThreshold(usage float64, alreadySent int) int {
+	if level := reachedThreshold(usage); level > alreadySent {
+		return level
+	}
+	return 0
+}
+
+// reachedThreshold returns the highest warning threshold usage has reached,
+// or 0 if it is below all of them.
+func reachedThreshold(usage float64) int {
+	for _, threshold := range limitWarningThresholds {
+		if usage >= float64(threshold) {
+			return threshold
+		}
+	}
+	return 0
+}
+
+func raiseLimitAlert(ctx context.Context, db *mongo.Database, accountID primitive.ObjectID, field string, level int) bool {
+	res, err := db.Collection("vendor_accounts").UpdateOne(ctx, bson.M{
+		"_id": accountID,
+		field: bson.M{"$lt": level},
+	}, bson.M{"$set": bson.M{field: level}})
+	if err != nil {
+		logrus.WithError(err).WithField("accountId", accountID.Hex()).Error("Failed to record limit alert")
+		return false
+	}
+	return res.ModifiedCount > 0
+}
+
+func sendLimitWarning(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, limit string, level int, usage string) {
+	var user models.User
+	if err := db.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		logrus.WithError(err).WithField("userId", userID.Hex()).Error("Failed to load vendor for limit warning")
+		return
+	}
+
+	message := fmt.Sprintf("<p>You have used %d%% of your %s limit (%s).</p>", level, limit, usage)
+	if level >= 100 {
+		message += "<p>You will not be able to go over this limit until it resets or you upgrade your seller tier.</p>"
+	} else {
+		message += "<p>Consider upgrading your seller tier to avoid interruptions.</p>"
+	}
+	notifyByEmail(user.Email, fmt.Sprintf("You have reached %d%% of your %s limit", level, limit), user.Name, message)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const verificationTokenTTL = 24 * time.Hour
+
+// issueVerificationToken invalidates any outstanding email verification
+// tokens for the user and stores the hash of a freshly generated one. The
+// raw token is returned so it can be mailed out.
+func (h *AuthHandler) issueVerificationToken(ctx context.Context, userID primitive.ObjectID) (string, error) {
+	collection := h.DB.Collection("verification_tokens")
+	now := time.Now()
+
+	_, err := collection.UpdateMany(ctx, bson.M{
+		"userId":        userID,
+		"purpose":       models.TokenPurposeEmailVerification,
+		"usedAt":        bson.M{"$exists": false},
+		"invalidatedAt": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{"invalidatedAt": now}})
+	if err != nil {
+		return "", fmt.Errorf("failed to invalidate old tokens: %w", err)
+	}
+
+	token, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	record := models.VerificationToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Purpose:   models.TokenPurposeEmailVerification,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: now.Add(verificationTokenTTL),
+		CreatedAt: now,
+	}
+	if _, err := collection.InsertOne(ctx, record); err != nil {
+		return "", fmt.Errorf("failed to save token: %w", err)
+	}
+	return token, nil
+}
+
+func sendVerificationEmail(name, email, token, intro string) {
+	verificationLink := fmt.Sprintf("http://localhost:3000/verify?token=%s", token)
+	emailBody := fmt.Sprintf(`
+    <html>
+    <body style="font-family: Arial, sans-serif;">
+        <h2>Welcome to Vendora, %s!</h2>
+        <p>%s Please verify your email by clicking the button below:</p>
+        <a href="%s" style="background-color: #4CAF50; color: white; padding: 10px 20px; text-decoration: none; border-radius: 5px;">Verify Email</a>
+        <p>This link will expire in 24 hours.</p>
+        <p>If you didn't create this account, please ignore this email.</p>
+        <p>Best regards,<br>The Vendora Team</p>
+    </body>
+    </html>
+`, html.EscapeString(name), intro, verificationLink)
+
+	go func() {
+		if err := utils.SendEmail(email, "Verify Your Vendora Account", emailBody); err != nil {
+			logrus.WithError(err).WithField("email", email).Error("Failed to send verification email")
+		} else {
+			logrus.WithField("email", email).Info("Verification email sent successfully")
+		}
+	}()
+}
@@ -0,0 +1,486 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxWishlistItems = 200
+	// A user gets at most this many wishlist alerts a day; the rest are
+	// dropped
+	maxWishlistAlertsPerDay = 5
+)
+
+type WishlistHandler struct {
+	DB *mongo.Database
+}
+
+func NewWishlistHandler(db *mongo.Database) *WishlistHandler {
+	return &WishlistHandler{DB: db}
+}
+
+// wishlistItem is a wishlisted product as shown to the client.
+type wishlistItem struct {
+	models.Product
+	InStock bool `json:"inStock"`
+}
+
+type wishlistView struct {
+	Items             []wishlistItem `json:"items"`
+	NotifyPriceDrop   bool           `json:"notifyPriceDrop"`
+	NotifyBackInStock bool           `json:"notifyBackInStock"`
+}
+
+func loadWishlist(ctx context.Context, db *mongo.Database, userID primitive.ObjectID) (*models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := db.Collection("wishlists").FindOne(ctx, bson.M{"userId": userID}).Decode(&wishlist)
+	if err == mongo.ErrNoDocuments {
+		return &models.Wishlist{UserID: userID, ProductIDs: []primitive.ObjectID{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wishlist, nil
+}
+
+// hydrateWishlist looks up the wishlist's products, in the order they were
+// added. Products that have since been deleted are left out.
+func hydrateWishlist(ctx context.Context, db *mongo.Database, wishlist *models.Wishlist) (wishlistView, error) {
+	view := wishlistView{
+		Items:             []wishlistItem{},
+		NotifyPriceDrop:   wishlist.NotifyPriceDrop,
+		NotifyBackInStock: wishlist.NotifyBackInStock,
+	}
+	if len(wishlist.ProductIDs) == 0 {
+		return view, nil
+	}
+
+	cursor, err := db.Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": wishlist.ProductIDs}, "deletedAt": notDeleted})
+	if err != nil {
+		return view, err
+	}
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return view, err
+	}
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	for _, id := range wishlist.ProductIDs {
+		if product, ok := byID[id]; ok {
+			view.Items = append(view.Items, wishlistItem{Product: product, InStock: product.Stock > 0})
+		}
+	}
+	return view, nil
+}
+
+func (h *WishlistHandler) respondWishlist(ctx context.Context, c *gin.Context, status int, message string) {
+	wishlist, err := loadWishlist(ctx, h.DB, middleware.UserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch wishlist"))
+		return
+	}
+	view, err := hydrateWishlist(ctx, h.DB, wishlist)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch wishlist"))
+		return
+	}
+	c.JSON(status, utils.SuccessResponse(message, gin.H{"wishlist": view}))
+}
+
+func wishlistProductParam(c *gin.Context) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("productId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid product ID"))
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+// GetWishlist returns the user's wishlist with its products.
+func (h *WishlistHandler) GetWishlist(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	h.respondWishlist(ctx, c, http.StatusOK, "Wishlist fetched successfully")
+}
+
+// AddWishlistItem saves a product to the user's wishlist. Adding a product
+// that is already there does nothing.
+func (h *WishlistHandler) AddWishlistItem(c *gin.Context) {
+	var input struct {
+		ProductID string `json:"productId" validate:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	productID, err := primitive.ObjectIDFromHex(input.ProductID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid product ID"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	count, err := h.DB.Collection("products").CountDocuments(ctx, bson.M{"_id": productID, "deletedAt": notDeleted})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch product"))
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Product not found"))
+		return
+	}
+
+	userID := middleware.UserID(c)
+	wishlist, err := loadWishlist(ctx, h.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update wishlist"))
+		return
+	}
+	if !wishlist.Has(productID) && len(wishlist.ProductIDs) >= maxWishlistItems {
+		c.JSON(http.StatusUnprocessableEntity, utils.ErrorResponse(fmt.Sprintf("A wishlist can hold at most %d products", maxWishlistItems)))
+		return
+	}
+
+	now := time.Now()
+	if _, err := h.DB.Collection("wishlists").UpdateOne(ctx, bson.M{"userId": userID}, bson.M{
+		"$addToSet":    bson.M{"productIds": productID},
+		"$set":         bson.M{"updatedAt": now},
+		"$setOnInsert": bson.M{"createdAt": now, "notifyPriceDrop": false, "notifyBackInStock": false},
+	}, options.Update().SetUpsert(true)); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update wishlist"))
+		return
+	}
+	h.respondWishlist(ctx, c, http.StatusOK, "Product added to wishlist")
+}
+
+// RemoveWishlistItem takes a product off the user's wishlist.
+func (h *WishlistHandler) RemoveWishlistItem(c *gin.Context) {
+	productID, ok := wishlistProductParam(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := h.DB.Collection("wishlists").UpdateOne(ctx, bson.M{"userId": middleware.UserID(c)}, bson.M{
+		"$pull": bson.M{"productIds": productID},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update wishlist"))
+		return
+	}
+	h.respondWishlist(ctx, c, http.StatusOK, "Product removed from wishlist")
+}
+
+// MoveWishlistItemToCart adds a wishlisted product to the user's cart and
+// takes it off the wishlist. Products with variants need a variantId.
+func (h *WishlistHandler) MoveWishlistItemToCart(c *gin.Context) {
+	productID, ok := wishlistProductParam(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		VariantID string `json:"variantId"`
+		Quantity  int    `json:"quantity" validate:"omitempty,min=1,max=99"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+	if err := productValidator.Struct(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Validation failed: "+err.Error()))
+		return
+	}
+	if input.Quantity == 0 {
+		input.Quantity = 1
+	}
+	variantID, ok := optionalObjectID(c, input.VariantID, "Invalid variant ID")
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	userID := middleware.UserID(c)
+	wishlist, err := loadWishlist(ctx, h.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch wishlist"))
+		return
+	}
+	if !wishlist.Has(productID) {
+		c.JSON(http.StatusNotFound, utils.ErrorResponse("Product is not on your wishlist"))
+		return
+	}
+
+	store := cartStore{Collection: h.DB.Collection("carts"), Filter: bson.M{"userId": userID}}
+	cart, err := loadCart(ctx, store)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to fetch cart"))
+		return
+	}
+	if !setCartQuantity(ctx, c, h.DB, cart, productID, variantID, input.Quantity, true) {
+		return
+	}
+	view, _, err := refreshCart(ctx, h.DB, cart)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to refresh cart"))
+		return
+	}
+	if err := saveCart(ctx, store, cart); errors.Is(err, errCartConflict) {
+		c.JSON(http.StatusConflict, utils.ErrorResponse("Cart was updated elsewhere, please try again"))
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to save cart"))
+		return
+	}
+
+	if _, err := h.DB.Collection("wishlists").UpdateOne(ctx, bson.M{"userId": userID}, bson.M{
+		"$pull": bson.M{"productIds": productID},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	}); err != nil {
+		// The item is in the cart; it can be removed from the wishlist later
+		logrus.WithError(err).WithField("userId", userID.Hex()).Error("Failed to remove moved item from wishlist")
+	}
+	c.JSON(http.StatusOK, utils.SuccessResponse("Item moved to cart", gin.H{"cart": view}))
+}
+
+// UpdateWishlistNotifications opts the user in to or out of price drop and
+// back in stock emails. Omitted settings are left unchanged.
+func (h *WishlistHandler) UpdateWishlistNotifications(c *gin.Context) {
+	var input struct {
+		PriceDrop   *bool `json:"priceDrop"`
+		BackInStock *bool `json:"backInStock"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, utils.ErrorResponse("Invalid request body"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{"updatedAt": now}
+	setOnInsert := bson.M{"productIds": bson.A{}, "createdAt": now}
+	if input.PriceDrop != nil {
+		set["notifyPriceDrop"] = *input.PriceDrop
+	} else {
+		setOnInsert["notifyPriceDrop"] = false
+	}
+	if input.BackInStock != nil {
+		set["notifyBackInStock"] = *input.BackInStock
+	} else {
+		setOnInsert["notifyBackInStock"] = false
+	}
+	if _, err := h.DB.Collection("wishlists").UpdateOne(ctx, bson.M{"userId": middleware.UserID(c)}, bson.M{
+		"$set":         set,
+		"$setOnInsert": setOnInsert,
+	}, options.Update().SetUpsert(true)); err != nil {
+		c.JSON(http.StatusInternalServerError, utils.ErrorResponse("Failed to update notification settings"))
+		return
+	}
+	h.respondWishlist(ctx, c, http.StatusOK, "Notification settings updated")
+}
+
+// takeWishlistAlertSlot counts an alert against the user's daily limit.
+// The count only goes up while it is under the limit; once it is reached
+// the filter stops matching and the upsert collides with the day's
+// counter, so concurrent runs can never send more than the limit between
+// them. It returns false when the user has had their fill for the day.
+func takeWishlistAlertSlot(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, now time.Time) (bool, error) {
+	day := now.UTC().Format("2006-01-02")
+	_, err := db.Collection("wishlist_alert_counts").UpdateOne(ctx,
+		bson.M{"userId": userID, "day": day, "count": bson.M{"$lt": maxWishlistAlertsPerDay}},
+		bson.M{
+			"$inc":         bson.M{"count": 1},
+			"$setOnInsert": bson.M{"expiresAt": now.Add(48 * time.Hour)},
+		},
+		options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// claimWishlistAlert records an alert about to be sent to a user. It
+// returns false when the same alert was already sent, or the user has had
+// their fill of alerts for the day.
+func claimWishlistAlert(ctx context.Context, db *mongo.Database, userID primitive.ObjectID, product *models.Product, kind string, previous *models.ProductWatch, now time.Time) (bool, error) {
+	ok, err := takeWishlistAlertSlot(ctx, db, userID, now)
+	if !ok {
+		return false, err
+	}
+	_, err = db.Collection("wishlist_notifications").InsertOne(ctx, models.WishlistNotification{
+		UserID:    userID,
+		ProductID: product.ID,
+		Kind:      kind,
+		Key:       models.WishlistAlertKey(kind, previous, product, now),
+		SentAt:    now,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		// Already sent; give the slot back
+		_, err = db.Collection("wishlist_alert_counts").UpdateOne(ctx,
+			bson.M{"userId": userID, "day": now.UTC().Format("2006-01-02")},
+			bson.M{"$inc": bson.M{"count": -1}})
+		return false, err
+	}
+	return err == nil, err
+}
+
+// sendWishlistAlert emails everyone who opted in to kind about a product on
+// their wishlist.
+func sendWishlistAlert(ctx context.Context, db *mongo.Database, product *models.Product, kind string, previous *models.ProductWatch, now time.Time) (int, error) {
+	optIn, subject, message := "notifyBackInStock", "Back in stock", fmt.Sprintf(
+		"<p>Good news: <strong>%s</strong> from your wishlist is back in stock. Get it before it sells out again.</p>",
+		html.EscapeString(product.Name))
+	if kind == models.WishlistAlertPriceDrop {
+		optIn, subject, message = "notifyPriceDrop", "Price drop on your wishlist", fmt.Sprintf(
+			"<p>The price of <strong>%s</strong> from your wishlist dropped from %s %.2f to %s %.2f.</p>",
+			html.EscapeString(product.Name), paymentCurrency(), previous.Price, paymentCurrency(), product.Price)
+	}
+
+	cursor, err := db.Collection("wishlists").Find(ctx, bson.M{"productIds": product.ID, optIn: true},
+		options.Find().SetProjection(bson.M{"userId": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var wishlists []models.Wishlist
+	if err := cursor.All(ctx, &wishlists); err != nil {
+		return 0, err
+	}
+	if len(wishlists) == 0 {
+		return 0, nil
+	}
+	userIDs := make([]primitive.ObjectID, 0, len(wishlists))
+	for _, w := range wishlists {
+		userIDs = append(userIDs, w.UserID)
+	}
+	cursor, err = db.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}},
+		options.Find().SetProjection(bson.M{"email": 1, "name": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, user := range users {
+		ok, err := claimWishlistAlert(ctx, db, user.ID, product, kind, previous, now)
+		if err != nil {
+			return sent, err
+		}
+		if ok {
+			notifyByEmail(user.Email, subject, user.Name, message)
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// SendWishlistAlerts emails users who opted in when a product on their
+// wishlist gets cheaper or comes back into stock. Each product's price and
+// stock are remembered between runs; the run that moves the snapshot on is
+// the one that sends the alerts.
+func SendWishlistAlerts(db *mongo.Database) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ids, err := db.Collection("wishlists").Distinct(ctx, "productIds", bson.M{"$or": bson.A{
+			bson.M{"notifyPriceDrop": true},
+			bson.M{"notifyBackInStock": true},
+		}})
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		cursor, err := db.Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "deletedAt": notDeleted},
+			options.Find().SetProjection(bson.M{"name": 1, "price": 1, "stock": 1, "variants._id": 1, "variants.stock": 1}))
+		if err != nil {
+			return err
+		}
+		var products []models.Product
+		if err := cursor.All(ctx, &products); err != nil {
+			return err
+		}
+		watches := db.Collection("product_watches")
+		cursor, err = watches.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return err
+		}
+		var seen []models.ProductWatch
+		if err := cursor.All(ctx, &seen); err != nil {
+			return err
+		}
+		previous := make(map[primitive.ObjectID]*models.ProductWatch, len(seen))
+		for i := range seen {
+			previous[seen[i].ProductID] = &seen[i]
+		}
+
+		now := time.Now()
+		sent := 0
+		for i := range products {
+			product := &products[i]
+			watch, ok := previous[product.ID]
+			if !ok {
+				// First sight of the product; nothing to compare with yet
+				if _, err := watches.UpdateOne(ctx, bson.M{"_id": product.ID}, bson.M{
+					"$setOnInsert": models.NewProductWatch(product, now),
+				}, options.Update().SetUpsert(true)); err != nil {
+					return err
+				}
+				continue
+			}
+			if watch.Unchanged(product) {
+				continue
+			}
+
+			// The snapshot only moves on from the one this run read
+			res, err := watches.ReplaceOne(ctx, bson.M{"_id": product.ID, "checkedAt": watch.CheckedAt}, models.NewProductWatch(product, now))
+			if err != nil {
+				return err
+			}
+			if res.ModifiedCount == 0 {
+				// Another run got there first
+				continue
+			}
+			for _, kind := range watch.Alerts(product) {
+				n, err := sendWishlistAlert(ctx, db, product, kind, watch, now)
+				sent += n
+				if err != nil {
+					logrus.WithError(err).WithFields(logrus.Fields{"productId": product.ID.Hex(), "kind": kind}).Error("Failed to send wishlist alerts")
+				}
+			}
+		}
+		if sent > 0 {
+			logrus.WithField("emails", sent).Info("Sent wishlist alerts")
+		}
+		return nil
+	}
+}
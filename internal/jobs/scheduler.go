@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Every runs fn once immediately and then on every tick of interval until
+// ctx is cancelled. Each run gets its own timeout of one interval so a slow
+// run cannot pile up behind the next one.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(ctx, name, interval, fn)
+			select {
+			case <-ctx.Done():
+				logrus.WithField("job", name).Info("Stopping background job")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func run(ctx context.Context, name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithField("job", name).Errorf("Background job panicked: %v", r)
+		}
+	}()
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := fn(runCtx); err != nil {
+		logrus.WithError(err).WithField("job", name).Error("Background job failed")
+		return
+	}
+	logrus.WithFields(logrus.Fields{"job": name, "took": time.Since(start).String()}).Debug("Background job finished")
+}
@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/developia-II/ecommerce-backend/internal/search"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RefreshSearchVocabulary rebuilds the vocabulary used for typo correction
+// and autocomplete from product names, descriptions and category names, the
+// same fields the text index searches.
+func RefreshSearchVocabulary(db *mongo.Database, vocabulary *search.Vocabulary) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		counts := map[string]int{}
+		add := func(text string) {
+			for _, term := range search.Tokenize(text) {
+				counts[term]++
+			}
+		}
+
+		opts := options.Find().SetProjection(bson.M{"name": 1, "categoryName": 1, "description": 1})
+		cursor, err := db.Collection("products").Find(ctx, bson.M{"deletedAt": bson.M{"$exists": false}}, opts)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+		for cursor.Next(ctx) {
+			var product struct {
+				Name         string `bson:"name"`
+				CategoryName string `bson:"categoryName"`
+				Description  string `bson:"description"`
+			}
+			if err := cursor.Decode(&product); err != nil {
+				return err
+			}
+			add(product.Name)
+			add(product.CategoryName)
+			add(product.Description)
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+
+		cursor, err = db.Collection("categories").Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"name": 1}))
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+		for cursor.Next(ctx) {
+			var category struct {
+				Name string `bson:"name"`
+			}
+			if err := cursor.Decode(&category); err != nil {
+				return err
+			}
+			add(category.Name)
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+
+		vocabulary.Replace(counts)
+		return nil
+	}
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ResetMonthlySales zeroes CurrentMonthSales on every vendor account whose
+// counter belongs to an earlier month. It is idempotent, so it can run as
+// often as needed; running it hourly resets accounts shortly after midnight
+// UTC on the first of the month.
+func ResetMonthlySales(db *mongo.Database) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		period := models.SalesPeriodOf(time.Now())
+		res, err := db.Collection("vendor_accounts").UpdateMany(ctx, bson.M{
+			"salesPeriod": bson.M{"$ne": period},
+		}, bson.M{"$set": bson.M{
+			"currentMonthSales": 0,
+			"salesPeriod":       period,
+			"salesLimitAlert":   0,
+			"updatedAt":         time.Now(),
+		}})
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount > 0 {
+			logrus.WithFields(logrus.Fields{"period": period, "accounts": res.ModifiedCount}).Info("Reset monthly vendor sales")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	contextUserID = "userID"
+	contextRole   = "role"
+)
+
+// RequireAuth verifies the bearer access token and stores the caller's user
+// ID and role in the gin context. Any failure results in the same 401.
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.ErrorResponse("Missing or invalid token"))
+			return
+		}
+
+		claims, err := utils.VerifyToken(strings.TrimPrefix(authHeader, "Bearer "))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.ErrorResponse("Missing or invalid token"))
+			return
+		}
+
+		userID, err := primitive.ObjectIDFromHex(claims.UserID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.ErrorResponse("Missing or invalid token"))
+			return
+		}
+
+		c.Set(contextUserID, userID)
+		c.Set(contextRole, claims.Role)
+		c.Next()
+	}
+}
+
+// RequireRoles only lets through callers whose token carries one of the
+// given roles. It must run after RequireAuth.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[role] = true
+	}
+
+	return func(c *gin.Context) {
+		if _, ok := c.Get(contextUserID); !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, utils.ErrorResponse("Missing or invalid token"))
+			return
+		}
+		if !allowed[Role(c)] {
+			c.AbortWithStatusJSON(http.StatusForbidden, utils.ErrorResponse("You do not have permission to access this resource"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// UserID returns the authenticated user's ID set by RequireAuth.
+func UserID(c *gin.Context) primitive.ObjectID {
+	if v, ok := c.Get(contextUserID); ok {
+		if id, ok := v.(primitive.ObjectID); ok {
+			return id
+		}
+	}
+	return primitive.NilObjectID
+}
+
+// Role returns the authenticated user's role set by RequireAuth.
+func Role(c *gin.Context) string {
+	return c.GetString(contextRole)
+}
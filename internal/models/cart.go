@@ -7,16 +7,68 @@ import (
 )
 
 type CartItem struct {
-	ProductID primitive.ObjectID `json:"productId" bson:"productId"`
-	Name      string             `json:"name" bson:"name"`
-	Price     float64            `json:"price" bson:"price"`
-	Quantity  int                `json:"quantity" bson:"quantity"`
+	ProductID primitive.ObjectID  `json:"productId" bson:"productId"`
+	VariantID *primitive.ObjectID `json:"variantId,omitempty" bson:"variantId,omitempty"`
+	SKU       string              `json:"sku,omitempty" bson:"sku,omitempty"`
+	Name      string              `json:"name" bson:"name"`
+	Price     float64             `json:"price" bson:"price"` // Unit price as of the last refresh
+	Quantity  int                 `json:"quantity" bson:"quantity"`
+}
+
+// Matches reports whether the item is the given product, or variant of it.
+func (i CartItem) Matches(productID primitive.ObjectID, variantID *primitive.ObjectID) bool {
+	return sameItem(i.ProductID, i.VariantID, productID, variantID)
+}
+
+// sameItem reports whether two product and variant pairs name the same
+// item. A product without a variant never matches one of its variants.
+func sameItem(productID primitive.ObjectID, variantID *primitive.ObjectID, otherProductID primitive.ObjectID, otherVariantID *primitive.ObjectID) bool {
+	if productID != otherProductID || (variantID == nil) != (otherVariantID == nil) {
+		return false
+	}
+	return variantID == nil || *variantID == *otherVariantID
 }
 
 type Cart struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
 	Items     []CartItem         `json:"items" bson:"items"`
+	Version   int                `json:"-" bson:"version"`             // For optimistic locking
+	ExpiresAt *time.Time         `json:"-" bson:"expiresAt,omitempty"` // Guest carts only
 	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
 	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
+
+// Find returns the index of the item for a product or variant, or -1.
+func (c *Cart) Find(productID primitive.ObjectID, variantID *primitive.ObjectID) int {
+	for i, item := range c.Items {
+		if item.Matches(productID, variantID) {
+			return i
+		}
+	}
+	return -1
+}
+
+// MergeCartItems combines two carts' items. Lines for the same product, or
+// the same variant of a product, are collapsed into one with the quantities
+// added up and capped at maxQuantity. The order of first appearance is kept.
+func MergeCartItems(items, other []CartItem, maxQuantity int) []CartItem {
+	merged := make([]CartItem, 0, len(items)+len(other))
+	for _, list := range [][]CartItem{items, other} {
+		for _, item := range list {
+			found := false
+			for i := range merged {
+				if merged[i].Matches(item.ProductID, item.VariantID) {
+					merged[i].Quantity = min(merged[i].Quantity+item.Quantity, maxQuantity)
+					found = true
+					break
+				}
+			}
+			if !found {
+				item.Quantity = min(item.Quantity, maxQuantity)
+				merged = append(merged, item)
+			}
+		}
+	}
+	return merged
+}
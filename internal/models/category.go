@@ -1,10 +1,64 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Category struct {
 	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
 	Name        string              `json:"name" bson:"name" validate:"required"`
 	Description string              `json:"description,omitempty" bson:"description"`
 	ParentID    *primitive.ObjectID `json:"parentId,omitempty" bson:"parentId"` // For subcategories
+	CreatedAt   time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// CategoryNode is a category in the nested tree. ProductCount counts the
+// category's own products, TotalProductCount includes its subcategories.
+type CategoryNode struct {
+	Category
+	ProductCount      int             `json:"productCount"`
+	TotalProductCount int             `json:"totalProductCount"`
+	Children          []*CategoryNode `json:"children"`
+}
+
+// BuildCategoryTree nests categories under their parents, sorted by name.
+// Categories whose parent is missing are treated as roots so nothing is lost.
+func BuildCategoryTree(categories []Category, productCounts map[primitive.ObjectID]int) []*CategoryNode {
+	nodes := make(map[primitive.ObjectID]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{
+			Category:     category,
+			ProductCount: productCounts[category.ID],
+			Children:     []*CategoryNode{},
+		}
+	}
+
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok && parent != node {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	var finish func(nodes []*CategoryNode) int
+	finish = func(nodes []*CategoryNode) int {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+		total := 0
+		for _, node := range nodes {
+			node.TotalProductCount = node.ProductCount + finish(node.Children)
+			total += node.TotalProductCount
+		}
+		return total
+	}
+	finish(roots)
+	return roots
 }
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of ledger entry.
+const (
+	LedgerEntrySale    = "sale"
+	LedgerEntryFee     = "fee"
+	LedgerEntryRefund  = "refund"
+	LedgerEntryRelease = "release"
+	LedgerEntryPayout  = "payout"
+	// A rejected payout put back in the vendor's balance
+	LedgerEntryPayoutReversal = "payout_reversal"
+)
+
+// Platform ledger accounts. Vendors each have a pending and an available
+// account; see VendorPendingAccount and VendorAvailableAccount.
+const (
+	// Money collected from customers that is not yet owed to anyone
+	AccountPlatformClearing = "platform:clearing"
+	// Transaction fees the platform has earned
+	AccountPlatformFees = "platform:fees"
+	// Money withdrawn by vendors, on its way to or in their bank accounts
+	AccountPlatformPayouts = "platform:payouts"
+)
+
+// VendorPendingAccount holds a vendor's earnings during the payout hold.
+func VendorPendingAccount(vendorID primitive.ObjectID) string {
+	return "vendor:" + vendorID.Hex() + ":pending"
+}
+
+// VendorAvailableAccount holds a vendor's earnings that can be paid out.
+func VendorAvailableAccount(vendorID primitive.ObjectID) string {
+	return "vendor:" + vendorID.Hex() + ":available"
+}
+
+// LedgerPosting moves Amount into Account, or out of it when negative.
+type LedgerPosting struct {
+	Account string  `json:"account" bson:"account"`
+	Amount  float64 `json:"amount" bson:"amount"`
+}
+
+// LedgerEntry is one double-entry transaction: its postings always sum to
+// zero. VendorID is the vendor it concerns. A sale's AvailableAt is when its
+// funds leave the payout hold, and ReleasedAt is set once they have;
+// RefundedAt is when a refund against it was last posted.
+type LedgerEntry struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	VendorID    primitive.ObjectID  `json:"vendorId" bson:"vendorId"`
+	Type        string              `json:"type" bson:"type"`
+	Postings    []LedgerPosting     `json:"postings" bson:"postings"`
+	OrderID     *primitive.ObjectID `json:"orderId,omitempty" bson:"orderId,omitempty"`
+	SubOrderID  *primitive.ObjectID `json:"subOrderId,omitempty" bson:"subOrderId,omitempty"`
+	RefundID    *primitive.ObjectID `json:"refundId,omitempty" bson:"refundId,omitempty"`
+	PayoutID    *primitive.ObjectID `json:"payoutId,omitempty" bson:"payoutId,omitempty"`
+	Description string              `json:"description" bson:"description"`
+	AvailableAt *time.Time          `json:"availableAt,omitempty" bson:"availableAt,omitempty"`
+	ReleasedAt  *time.Time          `json:"releasedAt,omitempty" bson:"releasedAt,omitempty"`
+	RefundedAt  *time.Time          `json:"refundedAt,omitempty" bson:"refundedAt,omitempty"`
+	CreatedAt   time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// RoundAmount rounds an amount of money to two decimal places.
+func RoundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// Balanced reports whether the entry's postings sum to zero.
+func (e *LedgerEntry) Balanced() bool {
+	var sum float64
+	for _, p := range e.Postings {
+		sum += p.Amount
+	}
+	return len(e.Postings) >= 2 && math.Abs(sum) < 0.005
+}
+
+// AmountFor is the entry's net movement on one account.
+func (e *LedgerEntry) AmountFor(account string) float64 {
+	var sum float64
+	for _, p := range e.Postings {
+		if p.Account == account {
+			sum += p.Amount
+		}
+	}
+	return RoundAmount(sum)
+}
+
+// LedgerBalances sums entries into a balance per account.
+func LedgerBalances(entries []LedgerEntry) map[string]float64 {
+	balances := map[string]float64{}
+	for _, e := range entries {
+		for _, p := range e.Postings {
+			balances[p.Account] += p.Amount
+		}
+	}
+	for account, balance := range balances {
+		balances[account] = RoundAmount(balance)
+	}
+	return balances
+}
+
+// TransactionFeeFor is the platform's fee on a sale at a percentage rate.
+func TransactionFeeFor(amount, percent float64) float64 {
+	return RoundAmount(amount * percent / 100)
+}
+
+// SaleEntries credits a vendor's pending account with a paid sub-order and
+// takes the platform's fee at feePercent. The sale becomes available for
+// payout holdDays after paidAt. The fee entry is omitted when there is no
+// fee.
+func SaleEntries(sub *SubOrder, feePercent float64, holdDays int, paidAt time.Time) []LedgerEntry {
+	pending := VendorPendingAccount(sub.VendorID)
+	availableAt := paidAt.AddDate(0, 0, holdDays)
+	entries := []LedgerEntry{{
+		VendorID: sub.VendorID,
+		Type:     LedgerEntrySale,
+		Postings: []LedgerPosting{
+			{Account: AccountPlatformClearing, Amount: -sub.Total},
+			{Account: pending, Amount: sub.Total},
+		},
+		OrderID:     &sub.OrderID,
+		SubOrderID:  &sub.ID,
+		Description: "Sale on order " + sub.OrderID.Hex(),
+		AvailableAt: &availableAt,
+		CreatedAt:   paidAt,
+	}}
+
+	if fee := TransactionFeeFor(sub.Total, feePercent); fee > 0 {
+		entries = append(entries, LedgerEntry{
+			VendorID: sub.VendorID,
+			Type:     LedgerEntryFee,
+			Postings: []LedgerPosting{
+				{Account: pending, Amount: -fee},
+				{Account: AccountPlatformFees, Amount: fee},
+			},
+			OrderID:     &sub.OrderID,
+			SubOrderID:  &sub.ID,
+			Description: fmt.Sprintf("Transaction fee (%g%%) on order %s", feePercent, sub.OrderID.Hex()),
+			CreatedAt:   paidAt,
+		})
+	}
+	return entries
+}
+
+// RefundEntry takes a refund back out of the vendor's account: the pending
+// one while the sale is on hold, the available one once it is released.
+// The platform returns the matching share of the fee it took on the sale,
+// so the vendor only bears the refund net of fee.
+func RefundEntry(refund *Refund, sale, fee *LedgerEntry, released bool, at time.Time) LedgerEntry {
+	account := VendorAvailableAccount(refund.VendorID)
+	if !released {
+		account = VendorPendingAccount(refund.VendorID)
+	}
+
+	var feeShare float64
+	if sale != nil && fee != nil {
+		if gross := sale.AmountFor(VendorPendingAccount(refund.VendorID)); gross > 0 {
+			feeShare = RoundAmount(fee.AmountFor(AccountPlatformFees) * refund.Amount / gross)
+		}
+	}
+
+	postings := []LedgerPosting{
+		{Account: account, Amount: -RoundAmount(refund.Amount - feeShare)},
+		{Account: AccountPlatformClearing, Amount: refund.Amount},
+	}
+	if feeShare > 0 {
+		postings = append(postings, LedgerPosting{Account: AccountPlatformFees, Amount: -feeShare})
+	}
+	return LedgerEntry{
+		VendorID:    refund.VendorID,
+		Type:        LedgerEntryRefund,
+		Postings:    postings,
+		OrderID:     &refund.OrderID,
+		SubOrderID:  &refund.SubOrderID,
+		RefundID:    &refund.ID,
+		Description: "Refund on order " + refund.OrderID.Hex(),
+		CreatedAt:   at,
+	}
+}
+
+// ReleaseEntry moves what is left of a sale from the vendor's pending
+// account to their available account at the end of the payout hold.
+func ReleaseEntry(sale *LedgerEntry, amount float64, at time.Time) LedgerEntry {
+	return LedgerEntry{
+		VendorID: sale.VendorID,
+		Type:     LedgerEntryRelease,
+		Postings: []LedgerPosting{
+			{Account: VendorPendingAccount(sale.VendorID), Amount: -amount},
+			{Account: VendorAvailableAccount(sale.VendorID), Amount: amount},
+		},
+		OrderID:     sale.OrderID,
+		SubOrderID:  sale.SubOrderID,
+		Description: "Funds released for order " + sale.OrderID.Hex(),
+		CreatedAt:   at,
+	}
+}
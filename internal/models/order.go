@@ -6,21 +6,226 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	OrderStatusPendingPayment = "pending_payment"
+	OrderStatusPaid           = "paid"
+	OrderStatusProcessing     = "processing"
+	OrderStatusShipped        = "shipped"
+	OrderStatusDelivered      = "delivered"
+	OrderStatusCancelled      = "cancelled"
+	OrderStatusRefunded       = "refunded"
+
+	PaymentStatusPending = "pending"
+	PaymentStatusPaid    = "paid"
+	PaymentStatusFailed  = "failed"
+
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+
+	// PaymentStatusRefundDue marks a successful charge the order could not
+	// take, e.g. a second payment or one for a cancelled order. Only
+	// payments use it; reconciliation sends the money back.
+	PaymentStatusRefundDue = "refund_due"
+	// PaymentStatusRefundPending marks a refund_due payment whose refund the
+	// provider accepted but has yet to settle.
+	PaymentStatusRefundPending = "refund_pending"
+
+	// OrderActorSystem is the ActorRole of changes made by jobs and webhooks
+	OrderActorSystem = "system"
+)
+
+// orderTransitions lists the statuses each order status may move to.
+// Cancelled and refunded are final.
+var orderTransitions = map[string][]string{
+	OrderStatusPendingPayment: {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:           {OrderStatusProcessing, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusProcessing:     {OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusShipped:        {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered:      {OrderStatusRefunded},
+}
+
+// CanTransitionOrder reports whether an order may move from one status to
+// another.
+func CanTransitionOrder(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderCancellable reports whether an order in this status can still be
+// cancelled by the customer, i.e. it has not shipped.
+func OrderCancellable(status string) bool {
+	return CanTransitionOrder(status, OrderStatusCancelled)
+}
+
+// OrderRefundable reports whether an order or sub-order in this status can
+// be refunded, i.e. it was paid for and not cancelled.
+func OrderRefundable(status string) bool {
+	return CanTransitionOrder(status, OrderStatusRefunded)
+}
+
+// OrderStatusFinal reports whether an order in this status can no longer
+// change.
+func OrderStatusFinal(status string) bool {
+	return len(orderTransitions[status]) == 0
+}
+
+// OrderStatusChange is one entry in an order's timeline. ActorID is nil for
+// system actions.
+type OrderStatusChange struct {
+	From      string              `json:"from,omitempty" bson:"from,omitempty"`
+	To        string              `json:"to" bson:"to"`
+	ActorID   *primitive.ObjectID `json:"actorId,omitempty" bson:"actorId,omitempty"`
+	ActorRole string              `json:"actorRole" bson:"actorRole"`
+	Reason    string              `json:"reason,omitempty" bson:"reason,omitempty"`
+	At        time.Time           `json:"at" bson:"at"`
+}
+
 type OrderItem struct {
-	ProductID primitive.ObjectID `json:"productId" bson:"productId"`
-	Quantity  int                `json:"quantity" bson:"quantity"`
-	Price     float64            `json:"price" bson:"price"`
+	ProductID primitive.ObjectID  `json:"productId" bson:"productId"`
+	VariantID *primitive.ObjectID `json:"variantId,omitempty" bson:"variantId,omitempty"`
+	SKU       string              `json:"sku,omitempty" bson:"sku,omitempty"`
+	VendorID  primitive.ObjectID  `json:"vendorId" bson:"vendorId"`
+	Name      string              `json:"name" bson:"name"`
+	Quantity  int                 `json:"quantity" bson:"quantity"`
+	Price     float64             `json:"price" bson:"price"` // Unit price of the product or variant at order time
+
+	RefundedQuantity int `json:"refundedQuantity,omitempty" bson:"refundedQuantity,omitempty"`
+}
+
+// Matches reports whether the item is the given product, or variant of it.
+func (i OrderItem) Matches(productID primitive.ObjectID, variantID *primitive.ObjectID) bool {
+	return sameItem(i.ProductID, i.VariantID, productID, variantID)
+}
+
+// Refundable is how many units of the item have not been refunded yet.
+func (i OrderItem) Refundable() int {
+	return i.Quantity - i.RefundedQuantity
+}
+
+// Subtotal is the line total of the item.
+func (i OrderItem) Subtotal() float64 {
+	return i.Price * float64(i.Quantity)
+}
+
+// OrderTotal sums the line totals of items.
+func OrderTotal(items []OrderItem) float64 {
+	var total float64
+	for _, item := range items {
+		total += item.Subtotal()
+	}
+	return total
 }
 
 type Order struct {
-	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID          primitive.ObjectID `json:"userId" bson:"userId"`
-	Items           []OrderItem        `json:"items" bson:"items"`
-	Total           float64            `json:"total" bson:"total"`
-	Status          string             `json:"status" bson:"status"`
-	ShippingAddress string             `json:"shippingAddress" bson:"shippingAddress"`
-	PaymentStatus   string             `json:"paymentStatus" bson:"paymentStatus"`
-	PaymentID       string             `json:"paymentId" bson:"paymentId"`
-	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt       time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID          primitive.ObjectID  `json:"userId" bson:"userId"`
+	Items           []OrderItem         `json:"items" bson:"items"`
+	Total           float64             `json:"total" bson:"total"`
+	RefundedAmount  float64             `json:"refundedAmount" bson:"refundedAmount"`
+	Status          string              `json:"status" bson:"status"`
+	ShippingAddress string              `json:"shippingAddress" bson:"shippingAddress"`
+	PaymentStatus   string              `json:"paymentStatus" bson:"paymentStatus"`
+	PaymentID       string              `json:"paymentId" bson:"paymentId"`
+	PaidAt          *time.Time          `json:"paidAt,omitempty" bson:"paidAt,omitempty"`
+	StatusHistory   []OrderStatusChange `json:"statusHistory" bson:"statusHistory"`
+	IdempotencyKey  string              `json:"-" bson:"idempotencyKey,omitempty"` // Client key that makes checkout safe to retry
+	CreatedAt       time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// orderStatusRank orders the active statuses by how far along they are.
+var orderStatusRank = map[string]int{
+	OrderStatusPendingPayment: 0,
+	OrderStatusPaid:           1,
+	OrderStatusProcessing:     2,
+	OrderStatusShipped:        3,
+	OrderStatusDelivered:      4,
+}
+
+// ParentOrderStatus derives a parent order's status from its sub-orders'.
+// Sub-orders that were cancelled or refunded are left out, and the parent is
+// only as far along as the least advanced of the rest. If none are left the
+// parent is refunded when any sub-order was, and cancelled otherwise. With no
+// sub-orders at all there is nothing to derive from, so it returns "".
+func ParentOrderStatus(statuses []string) string {
+	if len(statuses) == 0 {
+		return ""
+	}
+	parent, refunded := "", false
+	for _, status := range statuses {
+		rank, active := orderStatusRank[status]
+		if !active {
+			refunded = refunded || status == OrderStatusRefunded
+			continue
+		}
+		if parent == "" || rank < orderStatusRank[parent] {
+			parent = status
+		}
+	}
+	switch {
+	case parent != "":
+		return parent
+	case refunded:
+		return OrderStatusRefunded
+	default:
+		return OrderStatusCancelled
+	}
+}
+
+// SubOrderShipping is how a vendor sent their part of an order.
+type SubOrderShipping struct {
+	Carrier        string     `json:"carrier,omitempty" bson:"carrier,omitempty"`
+	TrackingNumber string     `json:"trackingNumber,omitempty" bson:"trackingNumber,omitempty"`
+	ShippedAt      *time.Time `json:"shippedAt,omitempty" bson:"shippedAt,omitempty"`
+	DeliveredAt    *time.Time `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+}
+
+// SubOrder is one vendor's share of an order, fulfilled by that vendor on
+// its own. The parent Order's status follows its sub-orders.
+type SubOrder struct {
+	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	OrderID         primitive.ObjectID  `json:"orderId" bson:"orderId"`
+	VendorID        primitive.ObjectID  `json:"vendorId" bson:"vendorId"`
+	UserID          primitive.ObjectID  `json:"userId" bson:"userId"`
+	Items           []OrderItem         `json:"items" bson:"items"`
+	Total           float64             `json:"total" bson:"total"`
+	RefundedAmount  float64             `json:"refundedAmount" bson:"refundedAmount"`
+	Status          string              `json:"status" bson:"status"`
+	ShippingAddress string              `json:"shippingAddress" bson:"shippingAddress"`
+	Shipping        SubOrderShipping    `json:"shipping" bson:"shipping"`
+	StatusHistory   []OrderStatusChange `json:"statusHistory" bson:"statusHistory"`
+	CreatedAt       time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// SplitOrder divides an order's items into one sub-order per vendor, in
+// order of each vendor's first item.
+func SplitOrder(order *Order) []SubOrder {
+	var subOrders []SubOrder
+	index := make(map[primitive.ObjectID]int)
+	for _, item := range order.Items {
+		i, ok := index[item.VendorID]
+		if !ok {
+			i = len(subOrders)
+			index[item.VendorID] = i
+			subOrders = append(subOrders, SubOrder{
+				ID:              primitive.NewObjectID(),
+				OrderID:         order.ID,
+				VendorID:        item.VendorID,
+				UserID:          order.UserID,
+				Status:          order.Status,
+				ShippingAddress: order.ShippingAddress,
+				StatusHistory:   order.StatusHistory,
+				CreatedAt:       order.CreatedAt,
+				UpdatedAt:       order.UpdatedAt,
+			})
+		}
+		subOrders[i].Items = append(subOrders[i].Items, item)
+		subOrders[i].Total += item.Subtotal()
+	}
+	return subOrders
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Payment is one attempt to pay for an order through a payment provider.
+// Reference is ours and unique per attempt; Status uses the order
+// PaymentStatus values.
+type Payment struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID          primitive.ObjectID `json:"orderId" bson:"orderId"`
+	UserID           primitive.ObjectID `json:"userId" bson:"userId"`
+	Provider         string             `json:"provider" bson:"provider"`
+	Reference        string             `json:"reference" bson:"reference"`
+	Amount           float64            `json:"amount" bson:"amount"`
+	Currency         string             `json:"currency" bson:"currency"`
+	Status           string             `json:"status" bson:"status"`
+	FailureReason    string             `json:"failureReason,omitempty" bson:"failureReason,omitempty"`
+	AuthorizationURL string             `json:"authorizationUrl" bson:"authorizationUrl"`
+	PaidAt           *time.Time         `json:"paidAt,omitempty" bson:"paidAt,omitempty"`
+	RefundID         string             `json:"refundId,omitempty" bson:"refundId,omitempty"` // Provider refund of a refund_due payment
+	CheckedAt        *time.Time         `json:"-" bson:"checkedAt,omitempty"`                 // Last reconciliation check
+	CreatedAt        time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt        time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// PaymentEvent records a processed webhook delivery so a redelivery is
+// ignored. ID is the provider name and the provider's event ID.
+type PaymentEvent struct {
+	ID         string    `bson:"_id"`
+	Provider   string    `bson:"provider"`
+	Kind       string    `bson:"kind"`
+	Reference  string    `bson:"reference"`
+	Status     string    `bson:"status"`
+	ReceivedAt time.Time `bson:"receivedAt"`
+}
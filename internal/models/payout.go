@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PayoutStatusRequested = "requested"
+	PayoutStatusApproved  = "approved"
+	PayoutStatusPaid      = "paid"
+	PayoutStatusRejected  = "rejected"
+)
+
+var payoutTransitions = map[string][]string{
+	PayoutStatusRequested: {PayoutStatusApproved, PayoutStatusRejected},
+	PayoutStatusApproved:  {PayoutStatusPaid, PayoutStatusRejected},
+}
+
+// CanTransitionPayout reports whether a payout may move from one status to
+// another. Paid and rejected are final.
+func CanTransitionPayout(from, to string) bool {
+	for _, next := range payoutTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PayoutBankAccount is where a vendor's payouts are sent. AccountNumber is
+// stored encrypted and never leaves the server; responses carry
+// MaskedAccountNumber instead.
+type PayoutBankAccount struct {
+	BankName            string    `json:"bankName" bson:"bankName"`
+	BankCode            string    `json:"bankCode" bson:"bankCode"`
+	AccountName         string    `json:"accountName" bson:"accountName"`
+	AccountNumber       string    `json:"-" bson:"accountNumber"`
+	MaskedAccountNumber string    `json:"accountNumber" bson:"maskedAccountNumber"`
+	UpdatedAt           time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Payout is a vendor's request to withdraw from their available balance.
+// The amount is taken from the balance when requested and put back if the
+// payout is rejected. BankAccount is the vendor's account at request time.
+type Payout struct {
+	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	VendorID        primitive.ObjectID  `json:"vendorId" bson:"vendorId"`
+	Amount          float64             `json:"amount" bson:"amount"`
+	Currency        string              `json:"currency" bson:"currency"`
+	BankAccount     PayoutBankAccount   `json:"bankAccount" bson:"bankAccount"`
+	Status          string              `json:"status" bson:"status"`
+	Reference       string              `json:"reference,omitempty" bson:"reference,omitempty"`
+	RejectionReason string              `json:"rejectionReason,omitempty" bson:"rejectionReason,omitempty"`
+	ReviewedBy      *primitive.ObjectID `json:"reviewedBy,omitempty" bson:"reviewedBy,omitempty"`
+	RequestedAt     time.Time           `json:"requestedAt" bson:"requestedAt"`
+	ApprovedAt      *time.Time          `json:"approvedAt,omitempty" bson:"approvedAt,omitempty"`
+	PaidAt          *time.Time          `json:"paidAt,omitempty" bson:"paidAt,omitempty"`
+	RejectedAt      *time.Time          `json:"rejectedAt,omitempty" bson:"rejectedAt,omitempty"`
+	UpdatedAt       time.Time           `json:"updatedAt" bson:"updatedAt"`
+}
+
+// PayoutEntry takes a payout out of the vendor's available balance.
+func PayoutEntry(payout *Payout) LedgerEntry {
+	return LedgerEntry{
+		VendorID: payout.VendorID,
+		Type:     LedgerEntryPayout,
+		Postings: []LedgerPosting{
+			{Account: VendorAvailableAccount(payout.VendorID), Amount: -payout.Amount},
+			{Account: AccountPlatformPayouts, Amount: payout.Amount},
+		},
+		PayoutID:    &payout.ID,
+		Description: "Payout to " + payout.BankAccount.BankName + " " + payout.BankAccount.MaskedAccountNumber,
+		CreatedAt:   payout.RequestedAt,
+	}
+}
+
+// PayoutReversalEntry puts a rejected payout back in the vendor's
+// available balance.
+func PayoutReversalEntry(payout *Payout, at time.Time) LedgerEntry {
+	return LedgerEntry{
+		VendorID: payout.VendorID,
+		Type:     LedgerEntryPayoutReversal,
+		Postings: []LedgerPosting{
+			{Account: AccountPlatformPayouts, Amount: -payout.Amount},
+			{Account: VendorAvailableAccount(payout.VendorID), Amount: payout.Amount},
+		},
+		PayoutID:    &payout.ID,
+		Description: "Payout rejected",
+		CreatedAt:   at,
+	}
+}
+
+// PayoutsBlocked reports whether the account's status stops the vendor
+// being paid.
+func (a *VendorAccount) PayoutsBlocked() bool {
+	return a.Status == "suspended" || a.Status == "banned"
+}
@@ -1,19 +1,137 @@
 package models
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const MaxProductVariants = 100
+
 type Product struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name        string             `json:"name" bson:"name" validate:"required"`
-	Description string             `json:"description" bson:"description"`
-	Price       float64            `json:"price" bson:"price" validate:"required,gt=0"`
-	Stock       int                `json:"stock" bson:"stock" validate:"gte=0"`
-	CategoryID  primitive.ObjectID `json:"categoryId" bson:"categoryId"` // Reference
-	Images      []string           `json:"images" bson:"images"`         // URLs
-	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `json:"name" bson:"name" validate:"required"`
+	Description  string             `json:"description" bson:"description"`
+	Price        float64            `json:"price" bson:"price" validate:"required,gt=0"` // Lowest variant price when there are variants
+	Stock        int                `json:"stock" bson:"stock" validate:"gte=0"`         // Sum of variant stock when there are variants
+	CategoryID   primitive.ObjectID `json:"categoryId" bson:"categoryId"`                // Reference
+	CategoryName string             `json:"categoryName" bson:"categoryName"`            // Copy of the category's name for text search
+	VendorID     primitive.ObjectID `json:"vendorId" bson:"vendorId"`                    // Owning vendor's user ID
+	Images       []string           `json:"images" bson:"images"`                        // URLs
+	Options      []ProductOption    `json:"options,omitempty" bson:"options,omitempty"`
+	Variants     []ProductVariant   `json:"variants,omitempty" bson:"variants,omitempty"`
+	SalesCount   int                `json:"salesCount" bson:"salesCount"` // Units sold, used for popularity
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time          `json:"updatedAt" bson:"updatedAt"`
+	DeletedAt    *time.Time         `json:"-" bson:"deletedAt,omitempty"` // Soft delete
+}
+
+// ProductOption is a dimension products vary in, e.g. "size" with values
+// "S", "M" and "L".
+type ProductOption struct {
+	Name   string   `json:"name" bson:"name" validate:"required,max=30"`
+	Values []string `json:"values" bson:"values" validate:"required,min=1,max=30,dive,required,max=50"`
+}
+
+// ProductVariant is one sellable combination of option values with its own
+// SKU, price and stock.
+type ProductVariant struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id"`
+	SKU     string             `json:"sku" bson:"sku" validate:"required,max=64"`
+	Options map[string]string  `json:"options" bson:"options" validate:"required"` // Option name -> value
+	Price   float64            `json:"price" bson:"price" validate:"gt=0"`
+	Stock   int                `json:"stock" bson:"stock" validate:"gte=0"`
+	Images  []string           `json:"images,omitempty" bson:"images,omitempty" validate:"max=10,dive,url"`
+}
+
+// HasVariants reports whether the product is sold per variant rather than
+// with its own price and stock.
+func (p *Product) HasVariants() bool {
+	return len(p.Variants) > 0
+}
+
+// Variant returns the variant with the given ID, or nil.
+func (p *Product) Variant(id primitive.ObjectID) *ProductVariant {
+	for i := range p.Variants {
+		if p.Variants[i].ID == id {
+			return &p.Variants[i]
+		}
+	}
+	return nil
+}
+
+// SyncVariantTotals sets Price to the lowest variant price and Stock to the
+// total variant stock, so listings, sorting and stock filters keep working
+// on the product level.
+func (p *Product) SyncVariantTotals() {
+	if !p.HasVariants() {
+		return
+	}
+	p.Price, p.Stock = p.Variants[0].Price, 0
+	for _, v := range p.Variants {
+		if v.Price < p.Price {
+			p.Price = v.Price
+		}
+		p.Stock += v.Stock
+	}
+}
+
+// ValidateVariants checks that every variant picks exactly one valid value
+// for each of the product's options, and that no two variants share a SKU
+// or an option combination.
+func (p *Product) ValidateVariants() error {
+	if !p.HasVariants() {
+		return nil
+	}
+	if len(p.Variants) > MaxProductVariants {
+		return fmt.Errorf("a product can have at most %d variants", MaxProductVariants)
+	}
+	if len(p.Options) == 0 {
+		return fmt.Errorf("variants require product options")
+	}
+
+	allowed := make(map[string]map[string]bool, len(p.Options))
+	for _, option := range p.Options {
+		if allowed[option.Name] != nil {
+			return fmt.Errorf("duplicate option %q", option.Name)
+		}
+		allowed[option.Name] = map[string]bool{}
+		for _, value := range option.Values {
+			allowed[option.Name][value] = true
+		}
+	}
+
+	skus := map[string]bool{}
+	combinations := map[string]bool{}
+	for _, v := range p.Variants {
+		if skus[v.SKU] {
+			return fmt.Errorf("duplicate SKU %q", v.SKU)
+		}
+		skus[v.SKU] = true
+
+		if len(v.Options) != len(allowed) {
+			return fmt.Errorf("variant %q must set a value for each option", v.SKU)
+		}
+		keys := make([]string, 0, len(v.Options))
+		for name, value := range v.Options {
+			values, ok := allowed[name]
+			if !ok {
+				return fmt.Errorf("variant %q uses unknown option %q", v.SKU, name)
+			}
+			if !values[value] {
+				return fmt.Errorf("variant %q uses unknown %s %q", v.SKU, name, value)
+			}
+			keys = append(keys, name+"="+value)
+		}
+		sort.Strings(keys)
+		combination := strings.Join(keys, ",")
+		if combinations[combination] {
+			return fmt.Errorf("more than one variant for %s", combination)
+		}
+		combinations[combination] = true
+	}
+	return nil
 }
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
+// RefundItem is a quantity of one order item being refunded.
+type RefundItem struct {
+	ProductID primitive.ObjectID  `json:"productId" bson:"productId"`
+	VariantID *primitive.ObjectID `json:"variantId,omitempty" bson:"variantId,omitempty"`
+	SKU       string              `json:"sku,omitempty" bson:"sku,omitempty"`
+	Name      string              `json:"name" bson:"name"`
+	Quantity  int                 `json:"quantity" bson:"quantity"`
+	Amount    float64             `json:"amount" bson:"amount"`
+}
+
+// Refund returns money for some or all of one vendor's sub-order against
+// the order's payment. ActorID is who asked for it.
+type Refund struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrderID          primitive.ObjectID `json:"orderId" bson:"orderId"`
+	SubOrderID       primitive.ObjectID `json:"subOrderId" bson:"subOrderId"`
+	VendorID         primitive.ObjectID `json:"vendorId" bson:"vendorId"`
+	UserID           primitive.ObjectID `json:"userId" bson:"userId"`
+	PaymentID        string             `json:"paymentId" bson:"paymentId"`
+	ProviderRefundID string             `json:"providerRefundId,omitempty" bson:"providerRefundId,omitempty"`
+	Items            []RefundItem       `json:"items" bson:"items"`
+	Amount           float64            `json:"amount" bson:"amount"`
+	Reason           string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	Restock          bool               `json:"restock" bson:"restock"`
+	Status           string             `json:"status" bson:"status"`
+	FailureReason    string             `json:"failureReason,omitempty" bson:"failureReason,omitempty"`
+	ActorID          primitive.ObjectID `json:"actorId" bson:"actorId"`
+	ActorRole        string             `json:"actorRole" bson:"actorRole"`
+	CreatedAt        time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt        time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ApplicationStatusDraft       = "draft"
+	ApplicationStatusPending     = "pending"
+	ApplicationStatusUnderReview = "under_review"
+	ApplicationStatusApproved    = "approved"
+	ApplicationStatusRejected    = "rejected"
+)
+
+// sellerApplicationTransitions lists the statuses each status may move to.
+// Approved and rejected are final; a rejected seller submits a new application.
+var sellerApplicationTransitions = map[string][]string{
+	ApplicationStatusDraft:       {ApplicationStatusPending},
+	ApplicationStatusPending:     {ApplicationStatusUnderReview, ApplicationStatusApproved, ApplicationStatusRejected},
+	ApplicationStatusUnderReview: {ApplicationStatusPending, ApplicationStatusApproved, ApplicationStatusRejected},
+}
+
+// CanTransitionSellerApplication reports whether an application may move
+// from one status to another.
+func CanTransitionSellerApplication(from, to string) bool {
+	for _, next := range sellerApplicationTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SellerApplicationReview is an append-only record of a single status
+// transition on a SellerApplication. ReviewerID is nil for system actions.
+type SellerApplicationReview struct {
+	ID              primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	ApplicationID   primitive.ObjectID  `json:"applicationId" bson:"applicationID"`
+	FromStatus      string              `json:"fromStatus" bson:"fromStatus"`
+	ToStatus        string              `json:"toStatus" bson:"toStatus"`
+	ReviewerID      *primitive.ObjectID `json:"reviewerId,omitempty" bson:"reviewerID,omitempty"`
+	ApprovedTier    string              `json:"approvedTier,omitempty" bson:"approvedTier,omitempty"`
+	Notes           string              `json:"notes,omitempty" bson:"notes,omitempty"`
+	RejectionReason string              `json:"rejectionReason,omitempty" bson:"rejectionReason,omitempty"`
+	Version         int                 `json:"version" bson:"version"` // Application version after the transition
+	CreatedAt       time.Time           `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a server-side record of a refresh token. Every refresh rotates
+// the token and creates a new session in the same family; presenting an
+// already rotated token revokes the whole family.
+type Session struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID  `json:"userId" bson:"userId"`
+	FamilyID     primitive.ObjectID  `json:"familyId" bson:"familyId"`
+	TokenHash    string              `json:"-" bson:"tokenHash"`
+	UserAgent    string              `json:"userAgent,omitempty" bson:"userAgent,omitempty"`
+	IPAddress    string              `json:"ipAddress,omitempty" bson:"ipAddress,omitempty"`
+	ExpiresAt    time.Time           `json:"expiresAt" bson:"expiresAt"`
+	RevokedAt    *time.Time          `json:"revokedAt,omitempty" bson:"revokedAt,omitempty"`
+	RevokeReason string              `json:"revokeReason,omitempty" bson:"revokeReason,omitempty"` // "rotated", "logout", "logout_all", "reuse_detected"
+	ReplacedBy   *primitive.ObjectID `json:"replacedBy,omitempty" bson:"replacedBy,omitempty"`
+	CreatedAt    time.Time           `json:"createdAt" bson:"createdAt"`
+}
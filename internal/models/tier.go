@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TierIndividual = "individual"
+	TierVerified   = "verified"
+	TierBusiness   = "business"
+)
+
+// TierPolicy holds the limits applied to a VendorAccount on a given tier.
+// Policies live in the tier_policies collection so they can be changed at
+// runtime; DefaultTierPolicies is used for tiers that have no stored policy.
+type TierPolicy struct {
+	Tier            string              `json:"tier" bson:"_id" validate:"required,oneof=individual verified business"`
+	MaxProducts     int                 `json:"maxProducts" bson:"maxProducts" validate:"gte=1"`
+	MaxMonthlySales float64             `json:"maxMonthlySales" bson:"maxMonthlySales" validate:"gt=0"`
+	TransactionFee  float64             `json:"transactionFee" bson:"transactionFee" validate:"gte=0,lte=100"` // Percentage
+	PayoutHoldDays  int                 `json:"payoutHoldDays" bson:"payoutHoldDays" validate:"gte=0"`
+	UpdatedAt       time.Time           `json:"updatedAt" bson:"updatedAt"`
+	UpdatedBy       *primitive.ObjectID `json:"updatedBy,omitempty" bson:"updatedBy,omitempty"`
+}
+
+var DefaultTierPolicies = map[string]TierPolicy{
+	TierIndividual: {Tier: TierIndividual, MaxProducts: 50, MaxMonthlySales: 500000, TransactionFee: 5, PayoutHoldDays: 14},
+	TierVerified:   {Tier: TierVerified, MaxProducts: 500, MaxMonthlySales: 5000000, TransactionFee: 3.5, PayoutHoldDays: 7},
+	TierBusiness:   {Tier: TierBusiness, MaxProducts: 10000, MaxMonthlySales: 50000000, TransactionFee: 2.5, PayoutHoldDays: 3},
+}
+
+// SalesPeriodOf returns the VendorAccount.SalesPeriod a moment falls in.
+func SalesPeriodOf(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+var tierRanks = map[string]int{TierIndividual: 1, TierVerified: 2, TierBusiness: 3}
+
+// IsTierUpgrade reports whether moving from one tier to another raises the
+// vendor's limits. Unknown tiers are never an upgrade.
+func IsTierUpgrade(from, to string) bool {
+	fromRank, ok := tierRanks[from]
+	if !ok {
+		return false
+	}
+	return tierRanks[to] > fromRank
+}
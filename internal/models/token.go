@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const TokenPurposeEmailVerification = "email_verification"
+
+// VerificationToken is a single-use token sent to the user by email.
+// Only the SHA-256 hash of the token is stored.
+type VerificationToken struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `json:"userId" bson:"userId"`
+	Purpose       string             `json:"purpose" bson:"purpose"`
+	TokenHash     string             `json:"-" bson:"tokenHash"`
+	ExpiresAt     time.Time          `json:"expiresAt" bson:"expiresAt"`
+	UsedAt        *time.Time         `json:"usedAt,omitempty" bson:"usedAt,omitempty"`
+	InvalidatedAt *time.Time         `json:"invalidatedAt,omitempty" bson:"invalidatedAt,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+}
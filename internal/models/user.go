@@ -1,11 +1,19 @@
 package models
 
 import (
+	"path"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	RoleCustomer = "customer"
+	RoleVendor   = "vendor"
+	RoleAdmin    = "admin"
+)
+
 type RegisterInput struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
@@ -120,6 +128,18 @@ type VerificationDocument struct {
 	VerifiedBy         *primitive.ObjectID `json:"verifiedBy,omitempty" bson:"verifiedBy,omitempty"` // Admin who verified
 	RejectionReason    string              `json:"rejectionReason,omitempty" bson:"rejectionReason,omitempty"`
 }
+
+// DocumentThumbnailURL derives a Cloudinary thumbnail from an uploaded
+// file's URL. PDFs are rendered from their first page.
+func DocumentThumbnailURL(fileURL string) string {
+	i := strings.Index(fileURL, "/upload/")
+	if i < 0 {
+		return ""
+	}
+	thumb := fileURL[:i] + "/upload/c_thumb,w_300,h_300,pg_1/" + fileURL[i+len("/upload/"):]
+	return strings.TrimSuffix(thumb, path.Ext(thumb)) + ".jpg"
+}
+
 type SellerBusinessInfo struct {
 	BusinessType       string `json:"type" bson:"type" validate:"required,oneof=unregistered sole-proprietor partnership llc corporation nonprofit"`
 	BusinessSize       string `json:"size" bson:"size" validate:"required,oneof=just_me 2-10 11-50 51-100 101+"`
@@ -179,6 +199,11 @@ type VendorAccount struct {
 	ProductCount      int     `json:"productCount" bson:"productCount"`
 	CurrentMonthSales float64 `json:"currentMonthSales" bson:"currentMonthSales"`
 	TotalSales        float64 `json:"totalSales" bson:"totalSales"`
+	SalesPeriod       string  `json:"salesPeriod" bson:"salesPeriod"` // "2006-01", month CurrentMonthSales belongs to
+
+	// Highest usage warning already sent (0, 80 or 100 percent)
+	ProductLimitAlert int `json:"-" bson:"productLimitAlert"`
+	SalesLimitAlert   int `json:"-" bson:"salesLimitAlert"`
 
 	// Trust Score (builds over time)
 	TrustScore      int `json:"trustScore" bson:"trustScore"` // 0-100
@@ -190,6 +215,10 @@ type VendorAccount struct {
 	Status     string `json:"status" bson:"status"` // "active", "suspended", "banned"
 	IsVerified bool   `json:"isVerified" bson:"isVerified"`
 
+	// Payouts
+	PayoutAccount       *PayoutBankAccount `json:"payoutAccount,omitempty" bson:"payoutAccount,omitempty"`
+	LastPayoutRequestAt *time.Time         `json:"lastPayoutRequestAt,omitempty" bson:"lastPayoutRequestAt,omitempty"`
+
 	// Timestamps
 	ActivatedAt time.Time  `json:"activatedAt" bson:"activatedAt"`
 	LastSaleAt  *time.Time `json:"lastSaleAt,omitempty" bson:"lastSaleAt,omitempty"`
@@ -204,7 +233,71 @@ type UserOnboardingDraft struct {
 	StepData      map[string]interface{} `json:"stepData" bson:"stepData"`
 	UpdatedAt     time.Time              `json:"updatedAt" bson:"updatedAt"`
 	Version       int                    `json:"version" bson:"version"`
+
+	// Set once a seller draft has been submitted as a SellerApplication
+	SubmittedAt   *time.Time          `json:"submittedAt,omitempty" bson:"submittedAt,omitempty"`
+	ApplicationID *primitive.ObjectID `json:"applicationId,omitempty" bson:"applicationID,omitempty"`
+}
+
+// SellerDraft is a vendor onboarding draft with its stepData decoded into
+// the shapes written by the seller onboarding steps.
+type SellerDraft struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty"`
+	UserID        primitive.ObjectID  `bson:"userID"`
+	StepData      SellerDraftData     `bson:"stepData"`
+	SubmittedAt   *time.Time          `bson:"submittedAt,omitempty"`
+	ApplicationID *primitive.ObjectID `bson:"applicationID,omitempty"`
 }
+
+type SellerDraftData struct {
+	BusinessInfo    *SellerBusinessInfo `bson:"businessInfo,omitempty"`
+	Categories      []string            `bson:"categories,omitempty"`
+	BusinessDetails *BusinessDetails    `bson:"businessDetails,omitempty"`
+	StoreDetails    *StoreDetails       `bson:"storeDetails,omitempty"`
+	Documents       SellerDocuments     `bson:"documents,omitempty"`
+}
+
+// SellerDocuments holds the verification documents uploaded during seller
+// onboarding, using the same field names as SellerApplication.
+type SellerDocuments struct {
+	IDDocument         *VerificationDocument  `json:"idDocument,omitempty" bson:"idDocument,omitempty"`
+	SelfieVerification *VerificationDocument  `json:"selfieVerification,omitempty" bson:"selfieVerification,omitempty"`
+	ProofOfActivity    []VerificationDocument `json:"proofOfActivity,omitempty" bson:"proofOfActivity,omitempty"`
+	AddressProof       *VerificationDocument  `json:"addressProof,omitempty" bson:"addressProof,omitempty"`
+	BusinessDocuments  []VerificationDocument `json:"businessDocuments,omitempty" bson:"businessDocuments,omitempty"`
+}
+
+// MissingDocuments lists what the requested tier still needs before the
+// application can be submitted. Every tier needs an ID and a selfie,
+// verified sellers add proof of address and activity, and business sellers
+// also need registration documents and a tax ID.
+func (a *SellerApplication) MissingDocuments() []string {
+	var missing []string
+	if a.IDDocument == nil {
+		missing = append(missing, "idDocument")
+	}
+	if a.SelfieVerification == nil {
+		missing = append(missing, "selfieVerification")
+	}
+	if a.RequestedTier == "verified" || a.RequestedTier == "business" {
+		if a.AddressProof == nil {
+			missing = append(missing, "addressProof")
+		}
+		if len(a.ProofOfActivity) == 0 {
+			missing = append(missing, "proofOfActivity")
+		}
+	}
+	if a.RequestedTier == "business" {
+		if len(a.BusinessDocuments) == 0 {
+			missing = append(missing, "businessDocuments")
+		}
+		if a.TaxID == "" {
+			missing = append(missing, "taxId")
+		}
+	}
+	return missing
+}
+
 type SocialMediaLink struct {
 	Platform string `json:"platform" bson:"platform"`
 	Handle   string `json:"handle" bson:"handle"`
@@ -1,14 +1,116 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Kinds of wishlist alert.
+const (
+	WishlistAlertPriceDrop   = "price_drop"
+	WishlistAlertBackInStock = "back_in_stock"
+)
+
+// Wishlist holds the products a user saved for later. The Notify flags opt
+// the user in to emails when one of them gets cheaper or comes back into
+// stock.
 type Wishlist struct {
-	ID         primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
-	UserID     primitive.ObjectID   `json:"userId" bson:"userId"`
-	ProductIDs []primitive.ObjectID `json:"productIds" bson:"productIds"`
-	CreatedAt  time.Time            `json:"createdAt" bson:"createdAt"`
+	ID                primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID            primitive.ObjectID   `json:"userId" bson:"userId"`
+	ProductIDs        []primitive.ObjectID `json:"productIds" bson:"productIds"`
+	NotifyPriceDrop   bool                 `json:"notifyPriceDrop" bson:"notifyPriceDrop"`
+	NotifyBackInStock bool                 `json:"notifyBackInStock" bson:"notifyBackInStock"`
+	CreatedAt         time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt         time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Has reports whether the product is on the wishlist.
+func (w *Wishlist) Has(productID primitive.ObjectID) bool {
+	for _, id := range w.ProductIDs {
+		if id == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// ProductWatch is a wishlisted product's price and stock when alerts last
+// looked at it. VariantStock holds each variant's stock by variant ID, as
+// the product's own stock hides a variant selling out while others last.
+type ProductWatch struct {
+	ProductID    primitive.ObjectID `bson:"_id"`
+	Price        float64            `bson:"price"`
+	Stock        int                `bson:"stock"`
+	VariantStock map[string]int     `bson:"variantStock,omitempty"`
+	CheckedAt    time.Time          `bson:"checkedAt"`
+}
+
+// NewProductWatch snapshots a product's price and stock.
+func NewProductWatch(product *Product, at time.Time) ProductWatch {
+	watch := ProductWatch{ProductID: product.ID, Price: product.Price, Stock: product.Stock, CheckedAt: at}
+	if product.HasVariants() {
+		watch.VariantStock = make(map[string]int, len(product.Variants))
+		for _, v := range product.Variants {
+			watch.VariantStock[v.ID.Hex()] = v.Stock
+		}
+	}
+	return watch
+}
+
+// Unchanged reports whether the product's price and stock are as watched.
+func (w *ProductWatch) Unchanged(product *Product) bool {
+	if w.Price != product.Price || w.Stock != product.Stock || len(w.VariantStock) != len(product.Variants) {
+		return false
+	}
+	for _, v := range product.Variants {
+		if stock, ok := w.VariantStock[v.ID.Hex()]; !ok || stock != v.Stock {
+			return false
+		}
+	}
+	return true
+}
+
+// Alerts returns the wishlist alerts a product's change since the watch
+// warrants: a price drop when it got cheaper, and back in stock when it,
+// or any one of its variants, went from none to some. Variants the watch
+// has not seen yet are not restocks.
+func (w *ProductWatch) Alerts(product *Product) []string {
+	var alerts []string
+	if product.Price < w.Price {
+		alerts = append(alerts, WishlistAlertPriceDrop)
+	}
+	restocked := !product.HasVariants() && w.Stock <= 0 && product.Stock > 0
+	for _, v := range product.Variants {
+		if stock, ok := w.VariantStock[v.ID.Hex()]; ok && stock <= 0 && v.Stock > 0 {
+			restocked = true
+		}
+	}
+	if restocked {
+		alerts = append(alerts, WishlistAlertBackInStock)
+	}
+	return alerts
+}
+
+// WishlistNotification records an alert sent to a user. Key identifies the
+// event, so the same price drop or restock is only ever sent once.
+type WishlistNotification struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	ProductID primitive.ObjectID `json:"productId" bson:"productId"`
+	Kind      string             `json:"kind" bson:"kind"`
+	Key       string             `json:"key" bson:"key"`
+	SentAt    time.Time          `json:"sentAt" bson:"sentAt"`
+}
+
+// WishlistAlertKey identifies an alert for deduplication: the same drop
+// from one price to another, or a restock, counts as the same event for the
+// rest of the day.
+func WishlistAlertKey(kind string, previous *ProductWatch, product *Product, at time.Time) string {
+	day := at.UTC().Format("2006-01-02")
+	if kind == WishlistAlertPriceDrop {
+		return fmt.Sprintf("%s:%.2f:%.2f:%s", kind, previous.Price, product.Price, day)
+	}
+	return kind + ":" + day
 }
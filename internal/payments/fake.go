@@ -0,0 +1,114 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FakeSignatureHeader carries the fake provider's webhook signature.
+const FakeSignatureHeader = "X-Fake-Signature"
+
+var errFakeChargeNotFound = errors.New("charge not found")
+
+// Fake is an in-memory provider for local development and tests. Charges
+// succeed as soon as they are verified unless Outcome is changed. Webhooks
+// are JSON Events signed with the hex HMAC-SHA256 of the body.
+type Fake struct {
+	Secret  string
+	Outcome string
+
+	mu      sync.Mutex
+	charges map[string]*Verification
+	refunds int
+}
+
+func NewFake(secret string) *Fake {
+	return &Fake{Secret: secret, Outcome: StatusSucceeded, charges: map[string]*Verification{}}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+func (f *Fake) Initialize(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.charges[req.Reference]; ok {
+		return nil, fmt.Errorf("duplicate reference %q", req.Reference)
+	}
+	f.charges[req.Reference] = &Verification{
+		Reference: req.Reference,
+		Status:    StatusPending,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+	}
+	return &Charge{Reference: req.Reference, AuthorizationURL: "https://pay.example.com/fake/" + req.Reference}, nil
+}
+
+// Verify settles a pending charge with the configured Outcome.
+func (f *Fake) Verify(ctx context.Context, reference string) (*Verification, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	charge, ok := f.charges[reference]
+	if !ok {
+		return nil, errFakeChargeNotFound
+	}
+	if charge.Status == StatusPending && f.Outcome != StatusPending {
+		charge.Status = f.Outcome
+		if charge.Status == StatusSucceeded {
+			now := time.Now()
+			charge.PaidAt = &now
+		}
+	}
+	v := *charge
+	return &v, nil
+}
+
+func (f *Fake) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	charge, ok := f.charges[req.Reference]
+	if !ok {
+		return nil, errFakeChargeNotFound
+	}
+	if charge.Status != StatusSucceeded {
+		return nil, fmt.Errorf("charge %q has not succeeded", req.Reference)
+	}
+	f.refunds++
+	return &Refund{ID: fmt.Sprintf("fake-refund-%d", f.refunds), Status: StatusSucceeded, Amount: req.Amount}, nil
+}
+
+// Sign returns the signature ParseWebhook expects for body.
+func (f *Fake) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Fake) ParseWebhook(header http.Header, body []byte) (*Event, error) {
+	signature, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	expected, _ := hex.DecodeString(f.Sign(body))
+	if !hmac.Equal(signature, expected) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode fake webhook: %w", err)
+	}
+	if event.Kind != EventCharge && event.Kind != EventRefund {
+		return nil, ErrUnsupportedEvent
+	}
+	return &event, nil
+}
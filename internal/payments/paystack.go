@@ -0,0 +1,238 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const paystackBaseURL = "https://api.paystack.co"
+
+// Paystack talks to the Paystack API. Amounts are sent in the currency's
+// smallest unit, e.g. kobo.
+type Paystack struct {
+	SecretKey string
+	BaseURL   string
+	Client    *http.Client
+}
+
+func NewPaystack(secretKey string) *Paystack {
+	return &Paystack{
+		SecretKey: secretKey,
+		BaseURL:   paystackBaseURL,
+		Client:    &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *Paystack) Name() string {
+	return "paystack"
+}
+
+// paystackResponse is the envelope every Paystack API response comes in.
+type paystackResponse struct {
+	Status  bool            `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type paystackTransaction struct {
+	ID        int64   `json:"id"`
+	Reference string  `json:"reference"`
+	Status    string  `json:"status"`
+	Amount    int64   `json:"amount"`
+	Currency  string  `json:"currency"`
+	PaidAt    *string `json:"paid_at"`
+}
+
+func (p *Paystack) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	if p.SecretKey == "" {
+		return errors.New("PAYSTACK_SECRET_KEY not set")
+	}
+
+	var reader *bytes.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal paystack request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.BaseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create paystack request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.SecretKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("paystack request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope paystackResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("paystack returned status %d with an unreadable body: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 300 || !envelope.Status {
+		return fmt.Errorf("paystack returned status %d: %s", resp.StatusCode, envelope.Message)
+	}
+	if out != nil {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return fmt.Errorf("failed to decode paystack response: %w", err)
+		}
+	}
+	return nil
+}
+
+func (p *Paystack) Initialize(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	body := map[string]interface{}{
+		"reference": req.Reference,
+		"amount":    toMinor(req.Amount),
+		"currency":  req.Currency,
+		"email":     req.Email,
+	}
+	if req.CallbackURL != "" {
+		body["callback_url"] = req.CallbackURL
+	}
+	if len(req.Metadata) > 0 {
+		body["metadata"] = req.Metadata
+	}
+
+	var data struct {
+		AuthorizationURL string `json:"authorization_url"`
+		Reference        string `json:"reference"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/transaction/initialize", body, &data); err != nil {
+		return nil, err
+	}
+	return &Charge{Reference: data.Reference, AuthorizationURL: data.AuthorizationURL}, nil
+}
+
+func (p *Paystack) Verify(ctx context.Context, reference string) (*Verification, error) {
+	var tx paystackTransaction
+	if err := p.do(ctx, http.MethodGet, "/transaction/verify/"+url.PathEscape(reference), nil, &tx); err != nil {
+		return nil, err
+	}
+	v := &Verification{
+		Reference: tx.Reference,
+		Status:    paystackChargeStatus(tx.Status),
+		Amount:    fromMinor(tx.Amount),
+		Currency:  tx.Currency,
+	}
+	if tx.PaidAt != nil {
+		if paidAt, err := time.Parse(time.RFC3339, *tx.PaidAt); err == nil {
+			v.PaidAt = &paidAt
+		}
+	}
+	return v, nil
+}
+
+func (p *Paystack) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	body := map[string]interface{}{
+		"transaction": req.Reference,
+		"amount":      toMinor(req.Amount),
+	}
+	if req.Reason != "" {
+		body["merchant_note"] = req.Reason
+	}
+
+	var data struct {
+		ID     int64  `json:"id"`
+		Status string `json:"status"`
+		Amount int64  `json:"amount"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/refund", body, &data); err != nil {
+		return nil, err
+	}
+	return &Refund{
+		ID:     strconv.FormatInt(data.ID, 10),
+		Status: paystackRefundStatus(data.Status),
+		Amount: fromMinor(data.Amount),
+	}, nil
+}
+
+// ParseWebhook checks the x-paystack-signature header, the hex HMAC-SHA512
+// of the body keyed with the secret key.
+func (p *Paystack) ParseWebhook(header http.Header, body []byte) (*Event, error) {
+	signature, err := hex.DecodeString(header.Get("x-paystack-signature"))
+	if err != nil || p.SecretKey == "" {
+		return nil, ErrInvalidSignature
+	}
+	mac := hmac.New(sha512.New, []byte(p.SecretKey))
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload struct {
+		Event string `json:"event"`
+		Data  struct {
+			ID                   int64  `json:"id"`
+			Reference            string `json:"reference"`
+			TransactionReference string `json:"transaction_reference"`
+			Status               string `json:"status"`
+			Amount               int64  `json:"amount"`
+			Currency             string `json:"currency"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode paystack webhook: %w", err)
+	}
+
+	// Paystack events carry no ID of their own; the event name and the
+	// object ID identify a delivery.
+	event := &Event{
+		ID:       payload.Event + ":" + strconv.FormatInt(payload.Data.ID, 10),
+		Amount:   fromMinor(payload.Data.Amount),
+		Currency: payload.Data.Currency,
+	}
+	switch payload.Event {
+	case "charge.success":
+		event.Kind, event.Reference, event.Status = EventCharge, payload.Data.Reference, StatusSucceeded
+	case "refund.processed":
+		event.Kind, event.Reference, event.Status = EventRefund, payload.Data.TransactionReference, StatusSucceeded
+	case "refund.failed":
+		event.Kind, event.Reference, event.Status = EventRefund, payload.Data.TransactionReference, StatusFailed
+	default:
+		return nil, ErrUnsupportedEvent
+	}
+	if event.Kind == EventRefund {
+		event.RefundID = strconv.FormatInt(payload.Data.ID, 10)
+	}
+	return event, nil
+}
+
+func paystackChargeStatus(status string) string {
+	switch status {
+	case "success":
+		return StatusSucceeded
+	case "failed", "abandoned", "reversed":
+		return StatusFailed
+	default:
+		return StatusPending
+	}
+}
+
+func paystackRefundStatus(status string) string {
+	switch status {
+	case "processed":
+		return StatusSucceeded
+	case "failed":
+		return StatusFailed
+	default:
+		return StatusPending
+	}
+}
@@ -0,0 +1,132 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Outcomes of a charge or refund as reported by a provider.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Kinds of webhook event.
+const (
+	EventCharge = "charge"
+	EventRefund = "refund"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrUnsupportedEvent = errors.New("unsupported webhook event")
+)
+
+// ChargeRequest asks the provider to start collecting a payment. Reference
+// is ours and must be unique per attempt; Amount is in major units, e.g.
+// naira rather than kobo.
+type ChargeRequest struct {
+	Reference   string
+	Amount      float64
+	Currency    string
+	Email       string
+	CallbackURL string
+	Metadata    map[string]string
+}
+
+// Charge is a started payment. The customer completes it at
+// AuthorizationURL.
+type Charge struct {
+	Reference        string
+	AuthorizationURL string
+}
+
+// Verification is the provider's current view of a charge.
+type Verification struct {
+	Reference string
+	Status    string
+	Amount    float64
+	Currency  string
+	PaidAt    *time.Time
+}
+
+// RefundRequest returns some or all of a successful charge.
+type RefundRequest struct {
+	Reference string
+	Amount    float64
+	Reason    string
+}
+
+// Refund is a refund as accepted by the provider. Status is usually still
+// pending; the outcome arrives by webhook.
+type Refund struct {
+	ID     string
+	Status string
+	Amount float64
+}
+
+// Event is a verified webhook. ID is unique per delivery so duplicates can
+// be ignored; Reference is the charge it is about, and RefundID the
+// provider's refund for refund events.
+type Event struct {
+	ID        string
+	Kind      string
+	Reference string
+	RefundID  string
+	Status    string
+	Amount    float64
+	Currency  string
+}
+
+// PaymentProvider is a payment gateway.
+type PaymentProvider interface {
+	Name() string
+	Initialize(ctx context.Context, req ChargeRequest) (*Charge, error)
+	Verify(ctx context.Context, reference string) (*Verification, error)
+	Refund(ctx context.Context, req RefundRequest) (*Refund, error)
+	// ParseWebhook checks the request's signature and decodes it. Events
+	// the application does not act on fail with ErrUnsupportedEvent.
+	ParseWebhook(header http.Header, body []byte) (*Event, error)
+}
+
+// FromEnv returns the provider named by PAYMENT_PROVIDER: "paystack", the
+// default, or "fake" for local development.
+func FromEnv() PaymentProvider {
+	switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+	case "fake":
+		logrus.Warn("Using the fake payment provider - no real payments will be taken")
+		secret := os.Getenv("FAKE_PAYMENT_SECRET")
+		if secret == "" {
+			secret = "fake-secret"
+		}
+		return NewFake(secret)
+	case "", "paystack":
+		return NewPaystack(os.Getenv("PAYSTACK_SECRET_KEY"))
+	default:
+		logrus.WithField("provider", name).Warn("Unknown PAYMENT_PROVIDER, using paystack")
+		return NewPaystack(os.Getenv("PAYSTACK_SECRET_KEY"))
+	}
+}
+
+// Covers reports whether a paid amount is at least the amount due. Both are
+// compared in minor units, so float rounding in an order total cannot make
+// an exact payment look short.
+func Covers(paid, due float64) bool {
+	return toMinor(paid) >= toMinor(due)
+}
+
+// toMinor converts an amount in major units to the smallest currency unit.
+func toMinor(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromMinor(amount int64) float64 {
+	return float64(amount) / 100
+}
@@ -0,0 +1,72 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxScore caps the combined score of an application.
+const MaxScore = 100
+
+// Subject is everything a rule can look at when scoring an application.
+type Subject struct {
+	Application *models.SellerApplication
+	User        *models.User
+}
+
+// Flag is raised by a rule that matched. Score is added to the
+// application's risk score and Reason explains the flag to reviewers.
+type Flag struct {
+	Rule   string
+	Score  int
+	Reason string
+}
+
+func (f Flag) String() string {
+	return fmt.Sprintf("%s: %s", f.Rule, f.Reason)
+}
+
+// Rule is a single risk signal. Evaluate returns nil when the rule does not
+// apply to the subject.
+type Rule interface {
+	Name() string
+	Evaluate(ctx context.Context, s Subject) (*Flag, error)
+}
+
+// Engine runs a set of rules and sums their scores. Higher is riskier.
+type Engine struct {
+	rules []Rule
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Score evaluates every rule against the subject. A rule that errors is
+// logged and skipped so one broken signal cannot block a submission.
+func (e *Engine) Score(ctx context.Context, s Subject) (int, []Flag) {
+	score := 0
+	flags := []Flag{}
+	for _, rule := range e.rules {
+		flag, err := rule.Evaluate(ctx, s)
+		if err != nil {
+			logrus.WithError(err).WithField("rule", rule.Name()).Warn("Risk rule failed")
+			continue
+		}
+		if flag == nil {
+			continue
+		}
+		if flag.Rule == "" {
+			flag.Rule = rule.Name()
+		}
+		score += flag.Score
+		flags = append(flags, *flag)
+	}
+	if score > MaxScore {
+		score = MaxScore
+	}
+	return score, flags
+}
@@ -0,0 +1,126 @@
+package risk
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// DefaultRules returns the rule set used for seller applications.
+func DefaultRules(db *mongo.Database) []Rule {
+	return []Rule{
+		DisposableEmailRule{Domains: DisposableEmailDomains},
+		MissingPhoneRule{},
+		InexperiencedBusinessRule{},
+		StoreNameCollisionRule{DB: db},
+		DuplicateTaxIDRule{DB: db},
+	}
+}
+
+var DisposableEmailDomains = map[string]bool{
+	"mailinator.com":    true,
+	"guerrillamail.com": true,
+	"10minutemail.com":  true,
+	"tempmail.com":      true,
+	"temp-mail.org":     true,
+	"yopmail.com":       true,
+	"trashmail.com":     true,
+	"getnada.com":       true,
+	"sharklasers.com":   true,
+	"dispostable.com":   true,
+}
+
+type DisposableEmailRule struct {
+	Domains map[string]bool
+}
+
+func (DisposableEmailRule) Name() string { return "disposable_email" }
+
+func (r DisposableEmailRule) Evaluate(_ context.Context, s Subject) (*Flag, error) {
+	if s.User == nil {
+		return nil, nil
+	}
+	at := strings.LastIndex(s.User.Email, "@")
+	if at < 0 {
+		return nil, nil
+	}
+	domain := strings.ToLower(s.User.Email[at+1:])
+	if !r.Domains[domain] {
+		return nil, nil
+	}
+	return &Flag{Score: 25, Reason: fmt.Sprintf("email domain %s is a disposable email provider", domain)}, nil
+}
+
+type MissingPhoneRule struct{}
+
+func (MissingPhoneRule) Name() string { return "missing_phone" }
+
+func (MissingPhoneRule) Evaluate(_ context.Context, s Subject) (*Flag, error) {
+	if s.User == nil || strings.TrimSpace(s.User.Phone) != "" {
+		return nil, nil
+	}
+	return &Flag{Score: 10, Reason: "no phone number on the account"}, nil
+}
+
+type InexperiencedBusinessRule struct{}
+
+func (InexperiencedBusinessRule) Name() string { return "inexperienced_business" }
+
+func (InexperiencedBusinessRule) Evaluate(_ context.Context, s Subject) (*Flag, error) {
+	app := s.Application
+	if app.RequestedTier != models.TierBusiness || app.BusinessTypeInfo == nil || app.BusinessTypeInfo.BusinessExperience != "0-6months" {
+		return nil, nil
+	}
+	return &Flag{Score: 15, Reason: "business tier requested with 0-6 months of experience"}, nil
+}
+
+// StoreNameCollisionRule flags store names already used by another seller,
+// ignoring case. Rejected applications do not count.
+type StoreNameCollisionRule struct {
+	DB *mongo.Database
+}
+
+func (StoreNameCollisionRule) Name() string { return "store_name_collision" }
+
+func (r StoreNameCollisionRule) Evaluate(ctx context.Context, s Subject) (*Flag, error) {
+	name := strings.TrimSpace(s.Application.StoreName)
+	if name == "" {
+		return nil, nil
+	}
+	count, err := r.DB.Collection("seller_applications").CountDocuments(ctx, bson.M{
+		"userID":    bson.M{"$ne": s.Application.UserID},
+		"status":    bson.M{"$ne": models.ApplicationStatusRejected},
+		"storeName": bson.M{"$regex": "^" + regexp.QuoteMeta(name) + "$", "$options": "i"},
+	})
+	if err != nil || count == 0 {
+		return nil, err
+	}
+	return &Flag{Score: 20, Reason: fmt.Sprintf("store name %q is already used by another seller", name)}, nil
+}
+
+// DuplicateTaxIDRule flags tax IDs already submitted by another seller.
+type DuplicateTaxIDRule struct {
+	DB *mongo.Database
+}
+
+func (DuplicateTaxIDRule) Name() string { return "duplicate_tax_id" }
+
+func (r DuplicateTaxIDRule) Evaluate(ctx context.Context, s Subject) (*Flag, error) {
+	taxID := strings.TrimSpace(s.Application.TaxID)
+	if taxID == "" {
+		return nil, nil
+	}
+	count, err := r.DB.Collection("seller_applications").CountDocuments(ctx, bson.M{
+		"userID": bson.M{"$ne": s.Application.UserID},
+		"taxId":  taxID,
+	})
+	if err != nil || count == 0 {
+		return nil, err
+	}
+	return &Flag{Score: 40, Reason: "tax ID is already registered to another seller"}, nil
+}
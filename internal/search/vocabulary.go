@@ -0,0 +1,144 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Products is the vocabulary of the product catalog, shared by the search
+// handlers and the job that refreshes it.
+var Products = NewVocabulary()
+
+// Vocabulary is an in-memory set of catalog terms with their frequencies.
+// It backs typo correction and autocomplete; the ranking itself is left to
+// MongoDB's text index. It is safe for concurrent use.
+type Vocabulary struct {
+	mu       sync.RWMutex
+	counts   map[string]int
+	sorted   []string
+	byLength map[int][]string
+}
+
+func NewVocabulary() *Vocabulary {
+	return &Vocabulary{counts: map[string]int{}}
+}
+
+// Replace swaps in a freshly built set of term counts.
+func (v *Vocabulary) Replace(counts map[string]int) {
+	sorted := make([]string, 0, len(counts))
+	byLength := map[int][]string{}
+	for term := range counts {
+		sorted = append(sorted, term)
+		n := utf8.RuneCountInString(term)
+		byLength[n] = append(byLength[n], term)
+	}
+	sort.Strings(sorted)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.counts = counts
+	v.sorted = sorted
+	v.byLength = byLength
+}
+
+// Len returns the number of distinct terms.
+func (v *Vocabulary) Len() int {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return len(v.sorted)
+}
+
+// Correct returns the known term closest to term, allowing one edit for
+// short words and two for longer ones. Ties go to the more frequent term.
+// Known terms, and terms with no close match, are returned unchanged.
+func (v *Vocabulary) Correct(term string) string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if _, ok := v.counts[term]; ok || len(v.counts) == 0 {
+		return term
+	}
+	length := utf8.RuneCountInString(term)
+	maxDistance := 1
+	if length > 5 {
+		maxDistance = 2
+	}
+
+	// Terms whose length differs by more than maxDistance cannot be close
+	// enough, so only the neighbouring length buckets are compared
+	best, bestDistance, bestCount := term, maxDistance+1, 0
+	for n := length - maxDistance; n <= length+maxDistance; n++ {
+		for _, candidate := range v.byLength[n] {
+			count := v.counts[candidate]
+			d := Levenshtein(term, candidate)
+			if d < bestDistance || (d == bestDistance && count > bestCount) ||
+				(d == bestDistance && count == bestCount && candidate < best) {
+				best, bestDistance, bestCount = candidate, d, count
+			}
+		}
+	}
+	if bestDistance > maxDistance {
+		return term
+	}
+	return best
+}
+
+// Complete returns up to n known terms starting with prefix, most frequent
+// first.
+func (v *Vocabulary) Complete(prefix string, n int) []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	start := sort.SearchStrings(v.sorted, prefix)
+	var matches []string
+	for i := start; i < len(v.sorted) && strings.HasPrefix(v.sorted[i], prefix); i++ {
+		matches = append(matches, v.sorted[i])
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return v.counts[matches[i]] > v.counts[matches[j]]
+	})
+	if len(matches) > n {
+		matches = matches[:n]
+	}
+	return matches
+}
+
+// Tokenize lowercases text and splits it into words, dropping single
+// characters.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := fields[:0]
+	for _, f := range fields {
+		if len([]rune(f)) > 1 {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// Levenshtein returns the edit distance between a and b.
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/handlers"
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestCartToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	cartID := primitive.NewObjectID().Hex()
+	token, err := utils.SignCartToken(cartID, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+
+	parsed, err := utils.ParseCartToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, cartID, parsed)
+
+	other := primitive.NewObjectID().Hex()
+	_, rest, _ := strings.Cut(token, ".")
+	_, err = utils.ParseCartToken(other + "." + rest)
+	assert.ErrorIs(t, err, utils.ErrInvalidCartToken)
+
+	_, err = utils.ParseCartToken(cartID)
+	assert.ErrorIs(t, err, utils.ErrInvalidCartToken)
+}
+
+func TestCartTokenExpiry(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	cartID := primitive.NewObjectID().Hex()
+	expired, err := utils.SignCartToken(cartID, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	_, err = utils.ParseCartToken(expired)
+	assert.ErrorIs(t, err, utils.ErrCartTokenExpired)
+
+	// Pushing the expiry forward breaks the signature
+	parts := strings.Split(expired, ".")
+	extended := parts[0] + "." + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + "." + parts[2]
+	_, err = utils.ParseCartToken(extended)
+	assert.ErrorIs(t, err, utils.ErrInvalidCartToken)
+}
+
+func TestMergeCartItems(t *testing.T) {
+	shoe, shirt, bag := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	small, large := primitive.NewObjectID(), primitive.NewObjectID()
+
+	userItems := []models.CartItem{
+		{ProductID: shoe, Quantity: 1},
+		{ProductID: shirt, VariantID: &small, Quantity: 2},
+		{ProductID: shoe, Quantity: 2}, // duplicate line
+	}
+	guestItems := []models.CartItem{
+		{ProductID: shoe, Quantity: 4},
+		{ProductID: shirt, VariantID: &large, Quantity: 1},
+		{ProductID: shirt, VariantID: &small, Quantity: 98},
+		{ProductID: bag, Quantity: 1},
+	}
+
+	merged := models.MergeCartItems(userItems, guestItems, 99)
+	assert.Len(t, merged, 4)
+	assert.Equal(t, models.CartItem{ProductID: shoe, Quantity: 7}, merged[0])
+	assert.Equal(t, 99, merged[1].Quantity, "capped at the maximum")
+	assert.Equal(t, large, *merged[2].VariantID)
+	assert.Equal(t, bag, merged[3].ProductID)
+}
+
+func getCart(mt *mtest.T, userID primitive.ObjectID) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/cart", middleware.RequireAuth(), handlers.NewCartHandler(mt.DB).GetCart)
+
+	token, err := utils.GenerateToken(userID.Hex(), "customer", time.Minute)
+	assert.NoError(mt, err)
+	req := httptest.NewRequest(http.MethodGet, "/cart", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func cartItem(productID primitive.ObjectID, name string, price float64, quantity int) bson.D {
+	return bson.D{
+		{Key: "productId", Value: productID},
+		{Key: "name", Value: name},
+		{Key: "price", Value: price},
+		{Key: "quantity", Value: quantity},
+	}
+}
+
+func cartProduct(id primitive.ObjectID, name string, price float64, stock int) bson.D {
+	return bson.D{
+		{Key: "_id", Value: id},
+		{Key: "name", Value: name},
+		{Key: "price", Value: price},
+		{Key: "stock", Value: stock},
+	}
+}
+
+func TestRefreshCart(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("cart is brought up to date with the live products", func(mt *mtest.T) {
+		userID := primitive.NewObjectID()
+		repriced, soldOut, scarce, deleted := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.carts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "userId", Value: userID},
+				{Key: "items", Value: bson.A{
+					cartItem(repriced, "Sneakers", 100, 1),
+					cartItem(soldOut, "Scarf", 50, 2),
+					cartItem(scarce, "Socks", 10, 5),
+					cartItem(deleted, "Hat", 30, 1),
+				}},
+				{Key: "version", Value: 3},
+			}),
+			mtest.CreateCursorResponse(0, "test.products", mtest.FirstBatch,
+				cartProduct(repriced, "Sneakers", 120, 10),
+				cartProduct(soldOut, "Scarf", 50, 0),
+				cartProduct(scarce, "Socks", 10, 3),
+			),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}}}),
+		)
+
+		w := getCart(mt, userID)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+
+		var body struct {
+			Data struct {
+				Cart struct {
+					Items []struct {
+						ProductID        primitive.ObjectID `json:"productId"`
+						Price            float64            `json:"price"`
+						Quantity         int                `json:"quantity"`
+						LineTotal        float64            `json:"lineTotal"`
+						PriceChanged     bool               `json:"priceChanged"`
+						PreviousPrice    float64            `json:"previousPrice"`
+						SoldOut          bool               `json:"soldOut"`
+						QuantityAdjusted bool               `json:"quantityAdjusted"`
+					} `json:"items"`
+					Removed []struct {
+						ProductID primitive.ObjectID `json:"productId"`
+						Reason    string             `json:"reason"`
+					} `json:"removed"`
+					Subtotal  float64 `json:"subtotal"`
+					ItemCount int     `json:"itemCount"`
+				} `json:"cart"`
+			} `json:"data"`
+		}
+		assert.NoError(mt, json.Unmarshal(w.Body.Bytes(), &body))
+		view := body.Data.Cart
+
+		if assert.Len(mt, view.Items, 3) {
+			assert.True(mt, view.Items[0].PriceChanged)
+			assert.Equal(mt, 100.0, view.Items[0].PreviousPrice)
+			assert.Equal(mt, 120.0, view.Items[0].Price)
+
+			assert.True(mt, view.Items[1].SoldOut)
+			assert.Equal(mt, 2, view.Items[1].Quantity, "sold out items stay in the cart")
+			assert.Zero(mt, view.Items[1].LineTotal)
+
+			assert.True(mt, view.Items[2].QuantityAdjusted)
+			assert.Equal(mt, 3, view.Items[2].Quantity)
+		}
+		if assert.Len(mt, view.Removed, 1) {
+			assert.Equal(mt, deleted, view.Removed[0].ProductID)
+			assert.Equal(mt, "no longer available", view.Removed[0].Reason)
+		}
+		assert.Equal(mt, 150.0, view.Subtotal, "the sold out scarf is not charged")
+		assert.Equal(mt, 4, view.ItemCount)
+
+		saves := commands(mt, "findAndModify", "carts")
+		if assert.Len(mt, saves, 1) {
+			assert.Equal(mt, int32(3), saves[0].Lookup("query", "version").Int32())
+			items := saves[0].Lookup("update", "$set", "items").Array()
+			values, _ := items.Values()
+			if assert.Len(mt, values, 3, "the deleted product is dropped") {
+				assert.Equal(mt, 120.0, values[0].Document().Lookup("price").Double())
+				assert.Equal(mt, int32(3), values[2].Document().Lookup("quantity").Int32())
+			}
+		}
+	})
+
+	mt.Run("unchanged cart is not saved", func(mt *mtest.T) {
+		userID, productID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.carts", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "userId", Value: userID},
+				{Key: "items", Value: bson.A{cartItem(productID, "Sneakers", 100, 2)}},
+				{Key: "version", Value: 1},
+			}),
+			mtest.CreateCursorResponse(0, "test.products", mtest.FirstBatch, cartProduct(productID, "Sneakers", 100, 10)),
+		)
+
+		w := getCart(mt, userID)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), `"subtotal":200`)
+		assert.Equal(mt, []string{"find", "find"}, commandNames(mt))
+	})
+}
@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBuildCategoryTree(t *testing.T) {
+	fashion := models.Category{ID: primitive.NewObjectID(), Name: "Fashion"}
+	electronics := models.Category{ID: primitive.NewObjectID(), Name: "Electronics"}
+	shoes := models.Category{ID: primitive.NewObjectID(), Name: "Shoes", ParentID: &fashion.ID}
+	sneakers := models.Category{ID: primitive.NewObjectID(), Name: "Sneakers", ParentID: &shoes.ID}
+	missing := primitive.NewObjectID()
+	orphan := models.Category{ID: primitive.NewObjectID(), Name: "Orphan", ParentID: &missing}
+
+	counts := map[primitive.ObjectID]int{
+		fashion.ID:  1,
+		shoes.ID:    2,
+		sneakers.ID: 4,
+	}
+	roots := models.BuildCategoryTree([]models.Category{sneakers, fashion, orphan, shoes, electronics}, counts)
+
+	assert.Len(t, roots, 3)
+	assert.Equal(t, "Electronics", roots[0].Name)
+	assert.Equal(t, "Fashion", roots[1].Name)
+	assert.Equal(t, "Orphan", roots[2].Name)
+
+	assert.Equal(t, 1, roots[1].ProductCount)
+	assert.Equal(t, 7, roots[1].TotalProductCount)
+	assert.Len(t, roots[1].Children, 1)
+	assert.Equal(t, 6, roots[1].Children[0].TotalProductCount)
+	assert.Equal(t, "Sneakers", roots[1].Children[0].Children[0].Name)
+	assert.Empty(t, roots[0].Children)
+}
@@ -0,0 +1,237 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/handlers"
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/payments"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// checkoutFixture is a user with one item in their cart. The cart still
+// carries the price it was added at, which is lower than the live price.
+type checkoutFixture struct {
+	userID, productID, vendorID primitive.ObjectID
+}
+
+func newCheckoutFixture() checkoutFixture {
+	return checkoutFixture{
+		userID:    primitive.NewObjectID(),
+		productID: primitive.NewObjectID(),
+		vendorID:  primitive.NewObjectID(),
+	}
+}
+
+func (f checkoutFixture) cart() bson.D {
+	return bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "userId", Value: f.userID},
+		{Key: "items", Value: bson.A{bson.D{
+			{Key: "productId", Value: f.productID},
+			{Key: "name", Value: "Sneakers"},
+			{Key: "price", Value: 1.0},
+			{Key: "quantity", Value: 2},
+		}}},
+		{Key: "version", Value: 3},
+	}
+}
+
+func (f checkoutFixture) product(stock int) bson.D {
+	return bson.D{
+		{Key: "_id", Value: f.productID},
+		{Key: "name", Value: "Sneakers"},
+		{Key: "price", Value: 1500.0},
+		{Key: "stock", Value: stock},
+		{Key: "vendorId", Value: f.vendorID},
+	}
+}
+
+func (f checkoutFixture) vendorAccount() bson.D {
+	return bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "userID", Value: f.vendorID},
+		{Key: "maxProducts", Value: 50},
+		{Key: "productCount", Value: 1},
+		{Key: "maxMonthlySales", Value: 500000.0},
+		{Key: "currentMonthSales", Value: 3000.0},
+		{Key: "status", Value: "active"},
+	}
+}
+
+func checkout(mt *mtest.T, userID primitive.ObjectID, key string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/orders", middleware.RequireAuth(), handlers.NewOrderHandler(mt.DB, payments.NewFake("secret")).Checkout)
+
+	token, err := utils.GenerateToken(userID.Hex(), "customer", time.Minute)
+	assert.NoError(mt, err)
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"shippingAddress":"12 Allen Avenue, Ikeja"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// commands returns the started commands sent to a collection, in order.
+func commands(mt *mtest.T, name, collection string) []bson.Raw {
+	var found []bson.Raw
+	for _, e := range mt.GetAllStartedEvents() {
+		if e.CommandName == name && e.Command.Lookup(name).StringValue() == collection {
+			found = append(found, e.Command)
+		}
+	}
+	return found
+}
+
+func commandNames(mt *mtest.T) []string {
+	var names []string
+	for _, e := range mt.GetAllStartedEvents() {
+		names = append(names, e.CommandName)
+	}
+	return names
+}
+
+func TestCheckout(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("recomputes totals and takes stock in one transaction", func(mt *mtest.T) {
+		f := newCheckoutFixture()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.carts", mtest.FirstBatch, f.cart()),
+			mtest.CreateCursorResponse(0, "test.products", mtest.FirstBatch, f.product(5)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: f.vendorAccount()}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := checkout(mt, f.userID, "order-1")
+		assert.Equal(mt, http.StatusCreated, w.Code, w.Body.String())
+
+		// The client's stale cart price is ignored
+		inserts := commands(mt, "insert", "orders")
+		if assert.Len(mt, inserts, 1) {
+			order := inserts[0].Lookup("documents").Array().Index(0).Value().Document()
+			assert.Equal(mt, 3000.0, order.Lookup("total").Double())
+			assert.Equal(mt, 1500.0, order.Lookup("items").Array().Index(0).Value().Document().Lookup("price").Double())
+			assert.Equal(mt, "order-1", order.Lookup("idempotencyKey").StringValue())
+		}
+
+		// Stock is only taken when there is enough of it
+		updates := commands(mt, "update", "products")
+		if assert.Len(mt, updates, 1) {
+			update := updates[0].Lookup("updates").Array().Index(0).Value().Document()
+			assert.Equal(mt, int32(2), update.Lookup("q", "stock", "$gte").Int32())
+			assert.Equal(mt, int32(-2), update.Lookup("u", "$inc", "stock").Int32())
+			assert.False(mt, updates[0].Lookup("autocommit").Boolean(), "stock is taken inside the transaction")
+		}
+		assert.Contains(mt, commandNames(mt), "commitTransaction")
+
+		var body struct {
+			Data struct {
+				Order struct {
+					Total float64 `json:"total"`
+				} `json:"order"`
+			} `json:"data"`
+		}
+		assert.NoError(mt, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(mt, 3000.0, body.Data.Order.Total)
+	})
+
+	mt.Run("oversold item aborts without writing the order", func(mt *mtest.T) {
+		f := newCheckoutFixture()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.carts", mtest.FirstBatch, f.cart()),
+			mtest.CreateCursorResponse(0, "test.products", mtest.FirstBatch, f.product(5)),
+			// Someone else bought the stock between pricing and reserving
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateCursorResponse(0, "test.products", mtest.FirstBatch, f.product(1)),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := checkout(mt, f.userID, "order-2")
+		assert.Equal(mt, http.StatusConflict, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), "not enough stock")
+		assert.Contains(mt, w.Body.String(), f.productID.Hex())
+
+		assert.Empty(mt, commands(mt, "insert", "orders"))
+		assert.Empty(mt, commands(mt, "insert", "sub_orders"))
+		assert.Contains(mt, commandNames(mt), "abortTransaction")
+		assert.NotContains(mt, commandNames(mt), "commitTransaction")
+	})
+
+	mt.Run("short stock is reported before anything is reserved", func(mt *mtest.T) {
+		f := newCheckoutFixture()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.carts", mtest.FirstBatch, f.cart()),
+			mtest.CreateCursorResponse(0, "test.products", mtest.FirstBatch, f.product(1)),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := checkout(mt, f.userID, "order-3")
+		assert.Equal(mt, http.StatusConflict, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), `"available":1`)
+		assert.Empty(mt, commands(mt, "update", "products"))
+		assert.Empty(mt, commands(mt, "insert", "orders"))
+	})
+
+	mt.Run("replaying a key returns the first order", func(mt *mtest.T) {
+		f := newCheckoutFixture()
+		orderID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: orderID},
+			{Key: "userId", Value: f.userID},
+			{Key: "total", Value: 3000.0},
+			{Key: "idempotencyKey", Value: "order-4"},
+		}))
+
+		w := checkout(mt, f.userID, "order-4")
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), orderID.Hex())
+		assert.Equal(mt, []string{"find"}, commandNames(mt), "nothing is reserved or written again")
+	})
+
+	mt.Run("losing a concurrent replay returns the winner's order", func(mt *mtest.T) {
+		f := newCheckoutFixture()
+		orderID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.carts", mtest.FirstBatch, f.cart()),
+			mtest.CreateCursorResponse(0, "test.products", mtest.FirstBatch, f.product(5)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: orderID},
+				{Key: "userId", Value: f.userID},
+				{Key: "idempotencyKey", Value: "order-5"},
+			}),
+		)
+
+		w := checkout(mt, f.userID, "order-5")
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), orderID.Hex())
+		assert.Contains(mt, commandNames(mt), "abortTransaction", "the loser's stock reservation is rolled back")
+	})
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptSecretRoundTrip(t *testing.T) {
+	os.Setenv("DATA_ENCRYPTION_KEY", "test-encryption-key-12345")
+	defer os.Unsetenv("DATA_ENCRYPTION_KEY")
+
+	first, err := utils.EncryptSecret("0123456789")
+	assert.NoError(t, err)
+	assert.NotContains(t, first, "0123456789")
+
+	second, err := utils.EncryptSecret("0123456789")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	plaintext, err := utils.DecryptSecret(first)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", plaintext)
+}
+
+func TestDecryptSecretRejectsTampering(t *testing.T) {
+	os.Setenv("DATA_ENCRYPTION_KEY", "test-encryption-key-12345")
+	defer os.Unsetenv("DATA_ENCRYPTION_KEY")
+
+	sealed, err := utils.EncryptSecret("0123456789")
+	assert.NoError(t, err)
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)-3] ^= 1
+	_, err = utils.DecryptSecret(string(tampered))
+	assert.Error(t, err)
+
+	_, err = utils.DecryptSecret("not base64!")
+	assert.ErrorIs(t, err, utils.ErrInvalidCiphertext)
+
+	os.Setenv("DATA_ENCRYPTION_KEY", "another-key")
+	_, err = utils.DecryptSecret(sealed)
+	assert.ErrorIs(t, err, utils.ErrInvalidCiphertext)
+}
+
+func TestEncryptSecretRequiresKey(t *testing.T) {
+	os.Unsetenv("DATA_ENCRYPTION_KEY")
+	_, err := utils.EncryptSecret("0123456789")
+	assert.Error(t, err)
+}
+
+func TestMaskAccountNumber(t *testing.T) {
+	assert.Equal(t, "******6789", utils.MaskAccountNumber("0123456789"))
+	assert.Equal(t, "***", utils.MaskAccountNumber("123"))
+	assert.Equal(t, "", utils.MaskAccountNumber(""))
+}
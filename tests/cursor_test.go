@@ -0,0 +1,26 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := utils.Cursor{Sort: "price_asc", Value: 1999.5, ID: "64b7f0c2a1b2c3d4e5f60718"}
+
+	encoded := utils.EncodeCursor(cursor)
+	assert.NotContains(t, encoded, "=")
+
+	decoded, err := utils.DecodeCursor(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+}
+
+func TestDecodeCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "not base64!", "e30"} { // e30 is "{}"
+		_, err := utils.DecodeCursor(s)
+		assert.ErrorIs(t, err, utils.ErrInvalidCursor, s)
+	}
+}
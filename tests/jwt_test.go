@@ -20,3 +20,14 @@ func TestGenerateToken(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
 }
+
+func TestHashToken(t *testing.T) {
+	token, err := utils.GenerateSecureToken(32)
+	assert.NoError(t, err)
+
+	hash := utils.HashToken(token)
+	assert.Len(t, hash, 64)
+	assert.NotEqual(t, token, hash)
+	assert.Equal(t, hash, utils.HashToken(token))
+	assert.NotEqual(t, hash, utils.HashToken(token+"x"))
+}
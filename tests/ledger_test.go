@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTransactionFeeFor(t *testing.T) {
+	assert.Equal(t, 50.0, models.TransactionFeeFor(1000, 5))
+	assert.Equal(t, 3.5, models.TransactionFeeFor(100, 3.5))
+	assert.Equal(t, 0.33, models.TransactionFeeFor(13.33, 2.5))
+	assert.Equal(t, 0.0, models.TransactionFeeFor(1000, 0))
+}
+
+func TestSaleEntriesHoldFunds(t *testing.T) {
+	vendorID := primitive.NewObjectID()
+	sub := models.SubOrder{ID: primitive.NewObjectID(), OrderID: primitive.NewObjectID(), VendorID: vendorID, Total: 1000}
+	paidAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	entries := models.SaleEntries(&sub, 5, 14, paidAt)
+	assert.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.True(t, e.Balanced(), e.Type)
+	}
+	assert.Equal(t, paidAt.AddDate(0, 0, 14), *entries[0].AvailableAt)
+
+	balances := models.LedgerBalances(entries)
+	assert.Equal(t, 950.0, balances[models.VendorPendingAccount(vendorID)])
+	assert.Equal(t, 0.0, balances[models.VendorAvailableAccount(vendorID)])
+	assert.Equal(t, 50.0, balances[models.AccountPlatformFees])
+	assert.Equal(t, -1000.0, balances[models.AccountPlatformClearing])
+
+	// No fee, no fee entry
+	assert.Len(t, models.SaleEntries(&sub, 0, 3, paidAt), 1)
+}
+
+func TestLedgerBalancesAfterRefundAndRelease(t *testing.T) {
+	vendorID := primitive.NewObjectID()
+	sub := models.SubOrder{ID: primitive.NewObjectID(), OrderID: primitive.NewObjectID(), VendorID: vendorID, Total: 1000}
+	now := time.Now()
+
+	entries := models.SaleEntries(&sub, 5, 7, now)
+	sale, fee := entries[0], entries[1]
+
+	// A quarter is refunded while on hold; the vendor gets a quarter of the fee back
+	refund := models.Refund{ID: primitive.NewObjectID(), OrderID: sub.OrderID, SubOrderID: sub.ID, VendorID: vendorID, Amount: 250}
+	refundEntry := models.RefundEntry(&refund, &sale, &fee, false, now)
+	assert.True(t, refundEntry.Balanced())
+	entries = append(entries, refundEntry)
+
+	pending := models.VendorPendingAccount(vendorID)
+	left := models.LedgerBalances(entries)[pending]
+	assert.Equal(t, 712.5, left)
+
+	release := models.ReleaseEntry(&sale, left, now)
+	assert.True(t, release.Balanced())
+	entries = append(entries, release)
+
+	// Another refund after release comes out of the available balance
+	late := models.Refund{ID: primitive.NewObjectID(), OrderID: sub.OrderID, SubOrderID: sub.ID, VendorID: vendorID, Amount: 100}
+	entries = append(entries, models.RefundEntry(&late, &sale, &fee, true, now))
+
+	balances := models.LedgerBalances(entries)
+	assert.Equal(t, 0.0, balances[pending])
+	assert.Equal(t, 617.5, balances[models.VendorAvailableAccount(vendorID)])
+	assert.Equal(t, 32.5, balances[models.AccountPlatformFees])
+	assert.Equal(t, -650.0, balances[models.AccountPlatformClearing])
+
+	var sum float64
+	for _, balance := range balances {
+		sum += balance
+	}
+	assert.InDelta(t, 0, sum, 0.001)
+}
+
+func TestLedgerEntryBalanced(t *testing.T) {
+	entry := models.LedgerEntry{Postings: []models.LedgerPosting{
+		{Account: models.AccountPlatformClearing, Amount: -10},
+		{Account: models.AccountPlatformFees, Amount: 9.99},
+	}}
+	assert.False(t, entry.Balanced())
+
+	entry.Postings[1].Amount = 10
+	assert.True(t, entry.Balanced())
+
+	assert.False(t, (&models.LedgerEntry{Postings: []models.LedgerPosting{{Account: "x", Amount: 0}}}).Balanced())
+}
+
+func TestCanTransitionPayout(t *testing.T) {
+	assert.True(t, models.CanTransitionPayout("requested", "approved"))
+	assert.True(t, models.CanTransitionPayout("requested", "rejected"))
+	assert.True(t, models.CanTransitionPayout("approved", "paid"))
+	assert.True(t, models.CanTransitionPayout("approved", "rejected"))
+
+	assert.False(t, models.CanTransitionPayout("requested", "paid"))
+	assert.False(t, models.CanTransitionPayout("paid", "rejected"))
+	assert.False(t, models.CanTransitionPayout("rejected", "approved"))
+}
+
+func TestPayoutEntries(t *testing.T) {
+	vendorID := primitive.NewObjectID()
+	payout := models.Payout{ID: primitive.NewObjectID(), VendorID: vendorID, Amount: 1500, RequestedAt: time.Now()}
+
+	requested := models.PayoutEntry(&payout)
+	assert.True(t, requested.Balanced())
+	assert.Equal(t, -1500.0, requested.AmountFor(models.VendorAvailableAccount(vendorID)))
+
+	reversed := models.PayoutReversalEntry(&payout, time.Now())
+	assert.True(t, reversed.Balanced())
+
+	balances := models.LedgerBalances([]models.LedgerEntry{requested, reversed})
+	assert.Equal(t, 0.0, balances[models.VendorAvailableAccount(vendorID)])
+	assert.Equal(t, 0.0, balances[models.AccountPlatformPayouts])
+}
+
+func TestVendorAccountPayoutsBlocked(t *testing.T) {
+	for status, blocked := range map[string]bool{"active": false, "suspended": true, "banned": true} {
+		account := models.VendorAccount{Status: status}
+		assert.Equal(t, blocked, account.PayoutsBlocked(), status)
+	}
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin", middleware.RequireAuth(), middleware.RequireRoles("admin"), func(c *gin.Context) {
+		c.String(http.StatusOK, middleware.UserID(c).Hex())
+	})
+	return router
+}
+
+func TestRequireAuth_MissingToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	for _, header := range []string{"", "Token abc", "Bearer not-a-jwt"} {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		newAuthRouter().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestRequireRoles(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	userID := primitive.NewObjectID()
+	cases := map[string]int{
+		"customer": http.StatusForbidden,
+		"admin":    http.StatusOK,
+	}
+	for role, expected := range cases {
+		token, err := utils.GenerateToken(userID.Hex(), role, time.Minute)
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		newAuthRouter().ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Code, role)
+		if expected == http.StatusOK {
+			assert.Equal(t, userID.Hex(), w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCanTransitionOrder(t *testing.T) {
+	assert.True(t, models.CanTransitionOrder("pending_payment", "paid"))
+	assert.True(t, models.CanTransitionOrder("paid", "processing"))
+	assert.True(t, models.CanTransitionOrder("processing", "shipped"))
+	assert.True(t, models.CanTransitionOrder("shipped", "delivered"))
+	assert.True(t, models.CanTransitionOrder("delivered", "refunded"))
+
+	assert.False(t, models.CanTransitionOrder("pending_payment", "shipped"))
+	assert.False(t, models.CanTransitionOrder("shipped", "cancelled"))
+	assert.False(t, models.CanTransitionOrder("cancelled", "paid"))
+	assert.False(t, models.CanTransitionOrder("refunded", "delivered"))
+	assert.False(t, models.CanTransitionOrder("paid", "paid"))
+}
+
+func TestOrderCancellable(t *testing.T) {
+	for _, status := range []string{"pending_payment", "paid", "processing"} {
+		assert.True(t, models.OrderCancellable(status), status)
+	}
+	for _, status := range []string{"shipped", "delivered", "cancelled", "refunded"} {
+		assert.False(t, models.OrderCancellable(status), status)
+	}
+}
+
+func TestParentOrderStatus(t *testing.T) {
+	assert.Equal(t, "processing", models.ParentOrderStatus([]string{"shipped", "processing", "delivered"}))
+	assert.Equal(t, "shipped", models.ParentOrderStatus([]string{"shipped", "cancelled", "delivered"}))
+	assert.Equal(t, "delivered", models.ParentOrderStatus([]string{"delivered", "refunded"}))
+	assert.Equal(t, "refunded", models.ParentOrderStatus([]string{"cancelled", "refunded"}))
+	assert.Equal(t, "cancelled", models.ParentOrderStatus([]string{"cancelled", "cancelled"}))
+	assert.Equal(t, "", models.ParentOrderStatus(nil), "no sub-orders leaves the parent alone")
+}
+
+func TestSplitOrder(t *testing.T) {
+	vendorA, vendorB := primitive.NewObjectID(), primitive.NewObjectID()
+	order := &models.Order{
+		ID:     primitive.NewObjectID(),
+		Status: "pending_payment",
+		Items: []models.OrderItem{
+			{VendorID: vendorA, Price: 1000, Quantity: 1},
+			{VendorID: vendorB, Price: 500, Quantity: 3},
+			{VendorID: vendorA, Price: 200, Quantity: 2},
+		},
+	}
+
+	subOrders := models.SplitOrder(order)
+	if assert.Len(t, subOrders, 2) {
+		assert.Equal(t, vendorA, subOrders[0].VendorID)
+		assert.Len(t, subOrders[0].Items, 2)
+		assert.Equal(t, 1400.0, subOrders[0].Total)
+		assert.Equal(t, vendorB, subOrders[1].VendorID)
+		assert.Equal(t, 1500.0, subOrders[1].Total)
+		assert.Equal(t, order.ID, subOrders[1].OrderID)
+		assert.Equal(t, "pending_payment", subOrders[1].Status)
+	}
+}
+
+func TestOrderRefundable(t *testing.T) {
+	for _, status := range []string{"paid", "processing", "shipped", "delivered"} {
+		assert.True(t, models.OrderRefundable(status), status)
+	}
+	for _, status := range []string{"pending_payment", "cancelled", "refunded"} {
+		assert.False(t, models.OrderRefundable(status), status)
+	}
+}
+
+func TestOrderItem_Refundable(t *testing.T) {
+	variant := primitive.NewObjectID()
+	item := models.OrderItem{ProductID: primitive.NewObjectID(), VariantID: &variant, Quantity: 3, RefundedQuantity: 1}
+	assert.Equal(t, 2, item.Refundable())
+	assert.True(t, item.Matches(item.ProductID, &variant))
+	assert.False(t, item.Matches(item.ProductID, nil))
+}
@@ -0,0 +1,416 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/handlers"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/internal/payments"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func postWebhook(mt *mtest.T, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	provider := payments.NewFake("secret")
+	router := gin.New()
+	router.POST("/payments/webhook", handlers.NewPaymentHandler(mt.DB, provider).Webhook)
+
+	req := httptest.NewRequest(http.MethodPost, "/payments/webhook", strings.NewReader(body))
+	req.Header.Set(payments.FakeSignatureHeader, provider.Sign([]byte(body)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// pendingRefund is a refund of one of two items on a sub-order sold last
+// year, sent to the provider as fake-refund-1.
+func pendingRefund(status string) (refund, subOrder, order bson.D) {
+	orderID, subOrderID, productID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	vendorID := primitive.NewObjectID()
+	soldAt := time.Now().AddDate(-1, 0, 0)
+	item := bson.D{
+		{Key: "productId", Value: productID},
+		{Key: "vendorId", Value: vendorID},
+		{Key: "name", Value: "Sneakers"},
+		{Key: "price", Value: 1500.0},
+		{Key: "quantity", Value: 2},
+		{Key: "refundedQuantity", Value: 1},
+	}
+	refund = bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "orderId", Value: orderID},
+		{Key: "subOrderId", Value: subOrderID},
+		{Key: "vendorId", Value: vendorID},
+		{Key: "paymentId", Value: "ref-3"},
+		{Key: "providerRefundId", Value: "fake-refund-1"},
+		{Key: "items", Value: bson.A{bson.D{
+			{Key: "productId", Value: productID},
+			{Key: "name", Value: "Sneakers"},
+			{Key: "quantity", Value: 1},
+			{Key: "amount", Value: 1500.0},
+		}}},
+		{Key: "amount", Value: 1500.0},
+		{Key: "status", Value: status},
+	}
+	subOrder = bson.D{
+		{Key: "_id", Value: subOrderID},
+		{Key: "orderId", Value: orderID},
+		{Key: "vendorId", Value: vendorID},
+		{Key: "items", Value: bson.A{item}},
+		{Key: "status", Value: "delivered"},
+		{Key: "createdAt", Value: soldAt},
+	}
+	order = bson.D{
+		{Key: "_id", Value: orderID},
+		{Key: "items", Value: bson.A{item}},
+		{Key: "total", Value: 3000.0},
+		{Key: "refundedAmount", Value: 1500.0},
+		{Key: "status", Value: "delivered"},
+		{Key: "createdAt", Value: soldAt},
+	}
+	return refund, subOrder, order
+}
+
+// chargedPayment is a 3000 NGN payment for an order.
+func chargedPayment(reference, status string) bson.D {
+	return bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "orderId", Value: primitive.NewObjectID()},
+		{Key: "provider", Value: "fake"},
+		{Key: "reference", Value: reference},
+		{Key: "amount", Value: 3000.0},
+		{Key: "currency", Value: "NGN"},
+		{Key: "status", Value: status},
+	}
+}
+
+// firstUpdate returns the first update statement of an update command.
+func firstUpdate(command bson.Raw) bson.Raw {
+	return command.Lookup("updates").Array().Index(0).Value().Document()
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("charge for an order that no longer awaits payment is due a refund", func(mt *mtest.T) {
+		orderID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.payments", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "orderId", Value: orderID},
+				{Key: "provider", Value: "fake"},
+				{Key: "reference", Value: "ref-2"},
+				{Key: "amount", Value: 3000.0},
+				{Key: "currency", Value: "NGN"},
+				{Key: "status", Value: "pending"},
+			}),
+			// The order was cancelled, or paid by another attempt
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-2","kind":"charge","reference":"ref-2","status":"succeeded","amount":3000}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+
+		orderUpdates := commands(mt, "update", "orders")
+		if assert.Len(mt, orderUpdates, 1) {
+			update := firstUpdate(orderUpdates[0])
+			assert.Equal(mt, "pending_payment", update.Lookup("q", "status").StringValue())
+			assert.Equal(mt, "paid", update.Lookup("u", "$set", "paymentStatus").StringValue())
+		}
+		paymentUpdates := commands(mt, "update", "payments")
+		if assert.Len(mt, paymentUpdates, 1) {
+			assert.Equal(mt, "refund_due", firstUpdate(paymentUpdates[0]).Lookup("u", "$set", "status").StringValue())
+		}
+		assert.Empty(mt, commands(mt, "findAndModify", "orders"), "the order is not moved to paid")
+		assert.Contains(mt, commandNames(mt), "commitTransaction")
+	})
+
+	mt.Run("charge in another currency is due a refund", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.payments", mtest.FirstBatch, chargedPayment("ref-4", "pending")),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-7","kind":"charge","reference":"ref-4","status":"succeeded","amount":3000,"currency":"USD"}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+
+		paymentUpdates := commands(mt, "update", "payments")
+		if assert.Len(mt, paymentUpdates, 1) {
+			set := firstUpdate(paymentUpdates[0]).Lookup("u", "$set")
+			assert.Equal(mt, "refund_due", set.Document().Lookup("status").StringValue())
+			assert.Equal(mt, "amount mismatch", set.Document().Lookup("failureReason").StringValue())
+		}
+		orderUpdates := commands(mt, "update", "orders")
+		if assert.Len(mt, orderUpdates, 1) {
+			assert.Equal(mt, "failed", firstUpdate(orderUpdates[0]).Lookup("u", "$set", "paymentStatus").StringValue())
+		}
+		assert.Empty(mt, commands(mt, "findAndModify", "orders"), "the order is not moved to paid")
+	})
+
+	mt.Run("success for an abandoned payment is not dropped", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.payments", mtest.FirstBatch, chargedPayment("ref-5", "failed")),
+			// The order was cancelled once the payment was given up
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-8","kind":"charge","reference":"ref-5","status":"succeeded","amount":3000,"currency":"NGN"}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+
+		orderUpdates := commands(mt, "update", "orders")
+		if assert.Len(mt, orderUpdates, 1) {
+			assert.Equal(mt, "pending_payment", firstUpdate(orderUpdates[0]).Lookup("q", "status").StringValue(), "paid if the order still waits")
+		}
+		paymentUpdates := commands(mt, "update", "payments")
+		if assert.Len(mt, paymentUpdates, 1) {
+			update := firstUpdate(paymentUpdates[0])
+			assert.Equal(mt, "failed", update.Lookup("q", "status").StringValue())
+			assert.Equal(mt, "refund_due", update.Lookup("u", "$set", "status").StringValue())
+		}
+	})
+}
+
+func TestRefundWebhook(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("processed refund is completed", func(mt *mtest.T) {
+		refund, subOrder, order := pendingRefund("pending")
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.refunds", mtest.FirstBatch, refund),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.sub_orders", mtest.FirstBatch, subOrder),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.ledger_entries", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch, order),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-3","kind":"refund","reference":"ref-3","refundId":"fake-refund-1","status":"succeeded","amount":1500}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+
+		finds := commands(mt, "find", "refunds")
+		if assert.Len(mt, finds, 1) {
+			assert.Equal(mt, "fake-refund-1", finds[0].Lookup("filter", "providerRefundId").StringValue())
+		}
+		refundUpdates := commands(mt, "update", "refunds")
+		if assert.Len(mt, refundUpdates, 1) {
+			assert.Equal(mt, "succeeded", firstUpdate(refundUpdates[0]).Lookup("u", "$set", "status").StringValue())
+		}
+		assert.Len(mt, commands(mt, "insert", "ledger_entries"), 1, "the vendor is debited")
+		orderUpdates := commands(mt, "update", "orders")
+		if assert.Len(mt, orderUpdates, 1) {
+			assert.Equal(mt, "partially_refunded", firstUpdate(orderUpdates[0]).Lookup("u", "$set", "paymentStatus").StringValue())
+		}
+		assert.Contains(mt, commandNames(mt), "commitTransaction")
+	})
+
+	mt.Run("refund in the month of the sale can lower the sales warning", func(mt *mtest.T) {
+		refund, subOrder, order := pendingRefund("pending")
+		for i := range subOrder {
+			if subOrder[i].Key == "createdAt" {
+				subOrder[i].Value = time.Now()
+			}
+		}
+		vendorID := subOrder.Map()["vendorId"].(primitive.ObjectID)
+		accountID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.refunds", mtest.FirstBatch, refund),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.sub_orders", mtest.FirstBatch, subOrder),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			// Warned at 80%, the refund takes the month back down to 70%
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+				{Key: "_id", Value: accountID},
+				{Key: "userID", Value: vendorID},
+				{Key: "maxMonthlySales", Value: 500000.0},
+				{Key: "currentMonthSales", Value: 350000.0},
+				{Key: "salesLimitAlert", Value: 80},
+			}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.ledger_entries", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch, order),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-12","kind":"refund","reference":"ref-3","refundId":"fake-refund-1","status":"succeeded","amount":1500}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+
+		decrements := commands(mt, "findAndModify", "vendor_accounts")
+		if assert.Len(mt, decrements, 1) {
+			assert.Equal(mt, -1500.0, decrements[0].Lookup("update", "$inc", "currentMonthSales").Double())
+		}
+		updates := commands(mt, "update", "vendor_accounts")
+		if assert.Len(mt, updates, 2) {
+			lowered := firstUpdate(updates[1])
+			assert.Equal(mt, accountID, lowered.Lookup("q", "_id").ObjectID())
+			assert.Equal(mt, int32(0), lowered.Lookup("u", "$set", "salesLimitAlert").Int32())
+		}
+		assert.Contains(mt, commandNames(mt), "commitTransaction")
+	})
+
+	mt.Run("refund against a sale that was released meanwhile comes out of available", func(mt *mtest.T) {
+		refund, subOrder, order := pendingRefund("pending")
+		vendorID := subOrder.Map()["vendorId"].(primitive.ObjectID)
+		sale := bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "vendorId", Value: vendorID},
+			{Key: "type", Value: "sale"},
+			{Key: "postings", Value: bson.A{
+				bson.D{{Key: "account", Value: models.VendorPendingAccount(vendorID)}, {Key: "amount", Value: 3000.0}},
+				bson.D{{Key: "account", Value: models.AccountPlatformClearing}, {Key: "amount", Value: -3000.0}},
+			}},
+		}
+		released := append(sale, bson.E{Key: "releasedAt", Value: time.Now()})
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.refunds", mtest.FirstBatch, refund),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.sub_orders", mtest.FirstBatch, subOrder),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.ledger_entries", mtest.FirstBatch, sale),
+			// The write to the sale sees the release that committed first
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: released}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch, order),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-11","kind":"refund","reference":"ref-3","refundId":"fake-refund-1","status":"succeeded","amount":1500}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+
+		writes := commands(mt, "findAndModify", "ledger_entries")
+		if assert.Len(mt, writes, 1, "the refund writes to the sale so a concurrent release conflicts") {
+			_, err := writes[0].LookupErr("update", "$set", "refundedAt")
+			assert.NoError(mt, err)
+		}
+		inserts := commands(mt, "insert", "ledger_entries")
+		if assert.Len(mt, inserts, 1) {
+			entry := inserts[0].Lookup("documents").Array().Index(0).Value().Document()
+			debit := entry.Lookup("postings").Array().Index(0).Value().Document()
+			assert.Equal(mt, models.VendorAvailableAccount(vendorID), debit.Lookup("account").StringValue())
+		}
+	})
+
+	mt.Run("failed refund gives the quantities back", func(mt *mtest.T) {
+		refund, subOrder, order := pendingRefund("pending")
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.refunds", mtest.FirstBatch, refund),
+			mtest.CreateCursorResponse(0, "test.sub_orders", mtest.FirstBatch, subOrder),
+			mtest.CreateCursorResponse(0, "test.orders", mtest.FirstBatch, order),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-4","kind":"refund","reference":"ref-3","refundId":"fake-refund-1","status":"failed","amount":1500}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+
+		subUpdates := commands(mt, "update", "sub_orders")
+		if assert.Len(mt, subUpdates, 1) {
+			update := firstUpdate(subUpdates[0])
+			assert.Equal(mt, -1500.0, update.Lookup("u", "$inc", "refundedAmount").Double())
+			item := update.Lookup("u", "$set", "items").Array().Index(0).Value().Document()
+			_, err := item.LookupErr("refundedQuantity")
+			assert.Error(mt, err, "nothing of the item is refunded any more")
+		}
+		refundUpdates := commands(mt, "update", "refunds")
+		if assert.Len(mt, refundUpdates, 1) {
+			assert.Equal(mt, "failed", firstUpdate(refundUpdates[0]).Lookup("u", "$set", "status").StringValue())
+		}
+		assert.Empty(mt, commands(mt, "insert", "ledger_entries"))
+		assert.Contains(mt, commandNames(mt), "commitTransaction")
+	})
+
+	mt.Run("outcome for a settled refund changes nothing", func(mt *mtest.T) {
+		refund, _, _ := pendingRefund("succeeded")
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.refunds", mtest.FirstBatch, refund),
+			mtest.CreateSuccessResponse(),
+		)
+
+		// A second delivery of the outcome under another event ID
+		w := postWebhook(mt, `{"id":"evt-5","kind":"refund","reference":"ref-3","refundId":"fake-refund-1","status":"failed","amount":1500}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(mt, []string{"insert", "find", "commitTransaction"}, commandNames(mt))
+	})
+
+	mt.Run("refund of a payment the order could not take is settled", func(mt *mtest.T) {
+		payment := append(chargedPayment("ref-6", "refund_pending"), bson.E{Key: "refundId", Value: "fake-refund-3"})
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.refunds", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.payments", mtest.FirstBatch, payment),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-9","kind":"refund","reference":"ref-6","refundId":"fake-refund-3","status":"succeeded","amount":3000}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		paymentUpdates := commands(mt, "update", "payments")
+		if assert.Len(mt, paymentUpdates, 1) {
+			update := firstUpdate(paymentUpdates[0])
+			assert.Equal(mt, "refund_pending", update.Lookup("q", "status").StringValue())
+			assert.Equal(mt, "refunded", update.Lookup("u", "$set", "status").StringValue())
+		}
+	})
+
+	mt.Run("failed refund of a payment is tried again", func(mt *mtest.T) {
+		payment := append(chargedPayment("ref-7", "refund_pending"), bson.E{Key: "refundId", Value: "fake-refund-4"})
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.refunds", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.payments", mtest.FirstBatch, payment),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-10","kind":"refund","reference":"ref-7","refundId":"fake-refund-4","status":"failed","amount":3000}`)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		paymentUpdates := commands(mt, "update", "payments")
+		if assert.Len(mt, paymentUpdates, 1) {
+			assert.Equal(mt, "refund_due", firstUpdate(paymentUpdates[0]).Lookup("u", "$set", "status").StringValue())
+		}
+	})
+
+	mt.Run("outcome arriving before the refund ID is recorded is retried", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.refunds", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.payments", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.refunds", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		w := postWebhook(mt, `{"id":"evt-6","kind":"refund","reference":"ref-3","refundId":"fake-refund-2","status":"succeeded","amount":1500}`)
+		assert.Equal(mt, http.StatusInternalServerError, w.Code, w.Body.String())
+		assert.Contains(mt, commandNames(mt), "abortTransaction")
+		assert.Empty(mt, commands(mt, "update", "refunds"))
+	})
+}
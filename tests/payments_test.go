@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/developia-II/ecommerce-backend/internal/payments"
+	"github.com/stretchr/testify/assert"
+)
+
+func signPaystack(secret string, body []byte) string {
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestPaystack_ParseWebhook(t *testing.T) {
+	p := payments.NewPaystack("sk_test_secret")
+	body := []byte(`{"event":"charge.success","data":{"id":302961,"reference":"order-1","status":"success","amount":1250050,"currency":"NGN"}}`)
+
+	header := http.Header{}
+	header.Set("x-paystack-signature", signPaystack("sk_test_secret", body))
+	event, err := p.ParseWebhook(header, body)
+	assert.NoError(t, err)
+	assert.Equal(t, "charge.success:302961", event.ID)
+	assert.Equal(t, payments.EventCharge, event.Kind)
+	assert.Equal(t, "order-1", event.Reference)
+	assert.Equal(t, payments.StatusSucceeded, event.Status)
+	assert.Equal(t, 12500.50, event.Amount)
+	assert.Equal(t, "NGN", event.Currency)
+
+	refund := []byte(`{"event":"refund.processed","data":{"id":1450,"transaction_reference":"order-1","status":"processed","amount":500000}}`)
+	header.Set("x-paystack-signature", signPaystack("sk_test_secret", refund))
+	event, err = p.ParseWebhook(header, refund)
+	assert.NoError(t, err)
+	assert.Equal(t, payments.EventRefund, event.Kind)
+	assert.Equal(t, "order-1", event.Reference)
+	assert.Equal(t, "1450", event.RefundID)
+	assert.Equal(t, payments.StatusSucceeded, event.Status)
+
+	header.Set("x-paystack-signature", signPaystack("another_secret", body))
+	_, err = p.ParseWebhook(header, body)
+	assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+
+	header.Del("x-paystack-signature")
+	_, err = p.ParseWebhook(header, body)
+	assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+
+	other := []byte(`{"event":"transfer.success","data":{"id":1}}`)
+	header.Set("x-paystack-signature", signPaystack("sk_test_secret", other))
+	_, err = p.ParseWebhook(header, other)
+	assert.ErrorIs(t, err, payments.ErrUnsupportedEvent)
+}
+
+func TestPaystack_Verify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer sk_test_secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "/transaction/verify/order-1", r.URL.Path)
+		w.Write([]byte(`{"status":true,"message":"Verification successful","data":{"id":1,"reference":"order-1","status":"abandoned","amount":50000,"currency":"NGN"}}`))
+	}))
+	defer server.Close()
+
+	p := payments.NewPaystack("sk_test_secret")
+	p.BaseURL = server.URL
+	v, err := p.Verify(context.Background(), "order-1")
+	assert.NoError(t, err)
+	assert.Equal(t, payments.StatusFailed, v.Status)
+	assert.Equal(t, 500.0, v.Amount)
+	assert.Equal(t, "NGN", v.Currency)
+}
+
+func TestFakeProvider(t *testing.T) {
+	ctx := context.Background()
+	f := payments.NewFake("secret")
+
+	charge, err := f.Initialize(ctx, payments.ChargeRequest{Reference: "ref-1", Amount: 100, Currency: "NGN"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, charge.AuthorizationURL)
+	_, err = f.Initialize(ctx, payments.ChargeRequest{Reference: "ref-1", Amount: 100})
+	assert.Error(t, err)
+
+	_, err = f.Refund(ctx, payments.RefundRequest{Reference: "ref-1", Amount: 100})
+	assert.Error(t, err, "a pending charge cannot be refunded")
+
+	v, err := f.Verify(ctx, "ref-1")
+	assert.NoError(t, err)
+	assert.Equal(t, payments.StatusSucceeded, v.Status)
+	assert.NotNil(t, v.PaidAt)
+
+	refund, err := f.Refund(ctx, payments.RefundRequest{Reference: "ref-1", Amount: 40})
+	assert.NoError(t, err)
+	assert.Equal(t, 40.0, refund.Amount)
+
+	body := []byte(`{"id":"evt-1","kind":"charge","reference":"ref-1","status":"succeeded","amount":100}`)
+	header := http.Header{}
+	header.Set(payments.FakeSignatureHeader, f.Sign(body))
+	event, err := f.ParseWebhook(header, body)
+	assert.NoError(t, err)
+	assert.Equal(t, "evt-1", event.ID)
+	assert.Equal(t, "ref-1", event.Reference)
+
+	header.Set(payments.FakeSignatureHeader, payments.NewFake("other").Sign(body))
+	_, err = f.ParseWebhook(header, body)
+	assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+}
+
+func TestCovers(t *testing.T) {
+	// Adding up prices leaves the total a hair above what the provider charged
+	prices := []float64{0.1, 0.2}
+	total := prices[0] + prices[1]
+	assert.True(t, 0.3 < total)
+	assert.True(t, payments.Covers(0.3, total))
+	assert.True(t, payments.Covers(1999.99*3, 5999.97))
+
+	assert.False(t, payments.Covers(0.29, total))
+	assert.True(t, payments.Covers(500, 499.99))
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func variantProduct() models.Product {
+	return models.Product{
+		Name: "Ankara shirt",
+		Options: []models.ProductOption{
+			{Name: "size", Values: []string{"M", "L"}},
+			{Name: "colour", Values: []string{"red", "blue"}},
+		},
+		Variants: []models.ProductVariant{
+			{ID: primitive.NewObjectID(), SKU: "SHIRT-M-RED", Options: map[string]string{"size": "M", "colour": "red"}, Price: 12000, Stock: 3},
+			{ID: primitive.NewObjectID(), SKU: "SHIRT-L-BLUE", Options: map[string]string{"size": "L", "colour": "blue"}, Price: 9500, Stock: 4},
+		},
+	}
+}
+
+func TestValidateVariants(t *testing.T) {
+	p := variantProduct()
+	assert.NoError(t, p.ValidateVariants())
+
+	p.Variants[1].SKU = "SHIRT-M-RED"
+	assert.ErrorContains(t, p.ValidateVariants(), "duplicate SKU")
+
+	p = variantProduct()
+	p.Variants[1].Options = map[string]string{"size": "M", "colour": "red"}
+	assert.ErrorContains(t, p.ValidateVariants(), "more than one variant")
+
+	p = variantProduct()
+	p.Variants[0].Options = map[string]string{"size": "XL", "colour": "red"}
+	assert.ErrorContains(t, p.ValidateVariants(), "unknown size")
+
+	p = variantProduct()
+	p.Variants[0].Options = map[string]string{"size": "M"}
+	assert.ErrorContains(t, p.ValidateVariants(), "each option")
+
+	p = variantProduct()
+	p.Options = nil
+	assert.Error(t, p.ValidateVariants())
+}
+
+func TestSyncVariantTotals(t *testing.T) {
+	p := variantProduct()
+	p.SyncVariantTotals()
+	assert.Equal(t, 9500.0, p.Price)
+	assert.Equal(t, 7, p.Stock)
+	assert.Equal(t, "SHIRT-L-BLUE", p.Variant(p.Variants[1].ID).SKU)
+	assert.Nil(t, p.Variant(primitive.NewObjectID()))
+}
+
+func TestOrderTotal(t *testing.T) {
+	items := []models.OrderItem{{Price: 2500, Quantity: 2}, {Price: 1000, Quantity: 1}}
+	assert.Equal(t, 6000.0, models.OrderTotal(items))
+}
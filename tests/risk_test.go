@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/internal/risk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRiskEngine_Score(t *testing.T) {
+	engine := risk.NewEngine(
+		risk.DisposableEmailRule{Domains: risk.DisposableEmailDomains},
+		risk.MissingPhoneRule{},
+		risk.InexperiencedBusinessRule{},
+	)
+
+	app := &models.SellerApplication{
+		RequestedTier:    "business",
+		BusinessTypeInfo: &models.SellerBusinessInfo{BusinessExperience: "0-6months"},
+	}
+	user := &models.User{Email: "seller@Mailinator.com"}
+
+	score, flags := engine.Score(context.Background(), risk.Subject{Application: app, User: user})
+	assert.Equal(t, 50, score)
+	assert.Len(t, flags, 3)
+	assert.Equal(t, "disposable_email", flags[0].Rule)
+	assert.Contains(t, flags[0].String(), "mailinator.com")
+}
+
+func TestRiskEngine_LowRisk(t *testing.T) {
+	engine := risk.NewEngine(
+		risk.DisposableEmailRule{Domains: risk.DisposableEmailDomains},
+		risk.MissingPhoneRule{},
+		risk.InexperiencedBusinessRule{},
+	)
+
+	app := &models.SellerApplication{
+		RequestedTier:    "individual",
+		BusinessTypeInfo: &models.SellerBusinessInfo{BusinessExperience: "0-6months"},
+	}
+	user := &models.User{Email: "seller@gmail.com", Phone: "07027262819"}
+
+	score, flags := engine.Score(context.Background(), risk.Subject{Application: app, User: user})
+	assert.Equal(t, 0, score)
+	assert.Empty(t, flags)
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/developia-II/ecommerce-backend/internal/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, search.Levenshtein("shoe", "shoe"))
+	assert.Equal(t, 1, search.Levenshtein("shoe", "shoes"))
+	assert.Equal(t, 2, search.Levenshtein("snekaers", "sneakers"))
+	assert.Equal(t, 3, search.Levenshtein("kitten", "sitting"))
+	assert.Equal(t, 4, search.Levenshtein("", "bags"))
+}
+
+func TestVocabularyCorrect(t *testing.T) {
+	v := search.NewVocabulary()
+	v.Replace(map[string]int{"sneakers": 10, "speakers": 3, "shoe": 7, "shoes": 2, "bag": 4, "café": 1})
+
+	assert.Equal(t, "sneakers", v.Correct("sneakres"))
+	assert.Equal(t, "shoe", v.Correct("shoe"))
+	assert.Equal(t, "bag", v.Correct("bog"))
+	assert.Equal(t, "laptop", v.Correct("laptop"), "no close match keeps the word")
+	assert.Equal(t, "shoes", v.Correct("shoess"))
+	assert.Equal(t, "café", v.Correct("cafe"), "unknown terms are looked up by rune length")
+
+	v.Replace(map[string]int{"boots": 1})
+	assert.Equal(t, "sneakres", v.Correct("sneakres"), "replaced terms are forgotten")
+	assert.Equal(t, "boots", v.Correct("bots"))
+}
+
+func TestVocabularyComplete(t *testing.T) {
+	v := search.NewVocabulary()
+	v.Replace(map[string]int{"shirt": 5, "shoe": 9, "shoes": 2, "sneakers": 4})
+
+	assert.Equal(t, []string{"shoe", "shirt", "shoes"}, v.Complete("sh", 5))
+	assert.Equal(t, []string{"shoe"}, v.Complete("sh", 1))
+	assert.Empty(t, v.Complete("x", 5))
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"men", "running", "shoes", "42"}, search.Tokenize("Men's Running-Shoes (42)"))
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/handlers"
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestSellerApplication_MissingDocuments(t *testing.T) {
+	doc := &models.VerificationDocument{FileURL: "https://example.com/doc.png"}
+
+	app := models.SellerApplication{RequestedTier: "individual"}
+	assert.Equal(t, []string{"idDocument", "selfieVerification"}, app.MissingDocuments())
+
+	app.IDDocument = doc
+	app.SelfieVerification = doc
+	assert.Empty(t, app.MissingDocuments())
+
+	app.RequestedTier = "verified"
+	assert.Equal(t, []string{"addressProof", "proofOfActivity"}, app.MissingDocuments())
+
+	app.RequestedTier = "business"
+	app.AddressProof = doc
+	app.ProofOfActivity = []models.VerificationDocument{*doc}
+	assert.Equal(t, []string{"businessDocuments", "taxId"}, app.MissingDocuments())
+
+	app.BusinessDocuments = []models.VerificationDocument{*doc}
+	app.TaxID = "TIN-123"
+	assert.Empty(t, app.MissingDocuments())
+}
+
+func TestCanTransitionSellerApplication(t *testing.T) {
+	assert.True(t, models.CanTransitionSellerApplication("draft", "pending"))
+	assert.True(t, models.CanTransitionSellerApplication("pending", "under_review"))
+	assert.True(t, models.CanTransitionSellerApplication("under_review", "approved"))
+	assert.True(t, models.CanTransitionSellerApplication("under_review", "rejected"))
+
+	assert.False(t, models.CanTransitionSellerApplication("draft", "approved"))
+	assert.False(t, models.CanTransitionSellerApplication("approved", "rejected"))
+	assert.False(t, models.CanTransitionSellerApplication("rejected", "pending"))
+	assert.False(t, models.CanTransitionSellerApplication("pending", "pending"))
+}
+
+func TestIsTierUpgrade(t *testing.T) {
+	assert.True(t, models.IsTierUpgrade("individual", "verified"))
+	assert.True(t, models.IsTierUpgrade("individual", "business"))
+	assert.True(t, models.IsTierUpgrade("verified", "business"))
+
+	assert.False(t, models.IsTierUpgrade("verified", "verified"))
+	assert.False(t, models.IsTierUpgrade("business", "individual"))
+	assert.False(t, models.IsTierUpgrade("individual", "platinum"))
+	assert.False(t, models.IsTierUpgrade("", "business"))
+}
+
+func TestApproveSellerApplication(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("approval is rolled back when the applicant cannot be made a vendor", func(mt *mtest.T) {
+		appID, userID := primitive.NewObjectID(), primitive.NewObjectID()
+		application := func(status string, version int) bson.D {
+			return bson.D{
+				{Key: "_id", Value: appID},
+				{Key: "userID", Value: userID},
+				{Key: "storeName", Value: "Ada's Shoes"},
+				{Key: "requestedTier", Value: "individual"},
+				{Key: "approvedTier", Value: "individual"},
+				{Key: "status", Value: status},
+				{Key: "version", Value: version},
+			}
+		}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.seller_applications", mtest.FirstBatch, application("under_review", 2)),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: application("approved", 3)}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.vendor_accounts", mtest.FirstBatch),
+			mtest.CreateCursorResponse(0, "test.tier_policies", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Message: "update failed"}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.PATCH("/applications/:id", middleware.RequireAuth(), handlers.NewSellerReviewHandler(mt.DB).TransitionApplication)
+		token, err := utils.GenerateToken(primitive.NewObjectID().Hex(), "admin", time.Minute)
+		assert.NoError(mt, err)
+		req := httptest.NewRequest(http.MethodPatch, "/applications/"+appID.Hex(), strings.NewReader(`{"status":"approved","version":2}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(mt, http.StatusInternalServerError, w.Code, w.Body.String())
+		assert.Len(mt, commands(mt, "insert", "vendor_accounts"), 1)
+		assert.Contains(mt, commandNames(mt), "abortTransaction", "the approval does not commit without the vendor role")
+		assert.NotContains(mt, commandNames(mt), "commitTransaction")
+	})
+}
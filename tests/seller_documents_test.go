@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/handlers"
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestDocumentThumbnailURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		fileURL string
+		want    string
+	}{
+		{
+			name:    "image",
+			fileURL: "https://res.cloudinary.com/demo/image/upload/v123/sellers/documents/u1/id.png",
+			want:    "https://res.cloudinary.com/demo/image/upload/c_thumb,w_300,h_300,pg_1/v123/sellers/documents/u1/id.jpg",
+		},
+		{
+			name:    "pdf is rendered from its first page",
+			fileURL: "https://res.cloudinary.com/demo/image/upload/v123/license.pdf",
+			want:    "https://res.cloudinary.com/demo/image/upload/c_thumb,w_300,h_300,pg_1/v123/license.jpg",
+		},
+		{
+			name:    "not a cloudinary upload",
+			fileURL: "https://example.com/files/id.png",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, models.DocumentThumbnailURL(tt.fileURL))
+		})
+	}
+}
+
+// uploadDocument posts content as the file of a seller document upload,
+// along with any extra form fields.
+func uploadDocument(mt *mtest.T, docType string, content []byte, fields map[string]string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for k, v := range fields {
+		form.WriteField(k, v)
+	}
+	part, _ := form.CreateFormFile("file", "document.bin")
+	part.Write(content)
+	form.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/onboarding/seller/documents/:type", middleware.RequireAuth(), handlers.NewOnboardingHandler(mt.DB).UploadSellerDocument)
+	token, err := utils.GenerateToken(primitive.NewObjectID().Hex(), "customer", time.Minute)
+	assert.NoError(mt, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/onboarding/seller/documents/"+docType, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadSellerDocument(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	pdf := []byte("%PDF-1.4\n1 0 obj\n<<>>\nendobj\n")
+
+	mt.Run("file that is not an image or pdf is rejected", func(mt *mtest.T) {
+		// The client claims nothing; the contents say text
+		w := uploadDocument(mt, "id", []byte("just some text pretending to be a passport"), nil)
+
+		assert.Equal(mt, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), "Only JPEG, PNG and PDF files are allowed")
+		assert.Empty(mt, commandNames(mt))
+	})
+
+	mt.Run("file over the size limit is rejected", func(mt *mtest.T) {
+		big := append(pdf, bytes.Repeat([]byte{' '}, 10<<20)...)
+		w := uploadDocument(mt, "id", big, nil)
+
+		assert.Equal(mt, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), "10MB or smaller")
+		assert.Empty(mt, commandNames(mt))
+	})
+
+	mt.Run("documentType outside the slot's types is rejected", func(mt *mtest.T) {
+		w := uploadDocument(mt, "business_document", pdf, map[string]string{"documentType": "selfie"})
+
+		assert.Equal(mt, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), "documentType must be one of")
+		assert.Empty(mt, commandNames(mt))
+	})
+}
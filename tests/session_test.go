@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/handlers"
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// storedSession is the session of refresh token "old-token" in a family,
+// expiring in an hour unless extra says otherwise.
+func storedSession(userID, familyID primitive.ObjectID, extra ...bson.E) bson.D {
+	session := bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "userId", Value: userID},
+		{Key: "familyId", Value: familyID},
+		{Key: "tokenHash", Value: utils.HashToken("old-token")},
+	}
+	expiresAt := bson.E{Key: "expiresAt", Value: time.Now().Add(time.Hour)}
+	for _, e := range extra {
+		if e.Key == "expiresAt" {
+			expiresAt = e
+			continue
+		}
+		session = append(session, e)
+	}
+	return append(session, expiresAt)
+}
+
+func refresh(mt *mtest.T, token string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/auth/refresh", handlers.NewAuthHandler(mt.DB).RefreshToken)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refreshToken":"`+token+`"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshToken(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("rotation issues a new token and revokes the old one", func(mt *mtest.T) {
+		userID, familyID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.sessions", mtest.FirstBatch, storedSession(userID, familyID)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "email", Value: "ada@example.com"},
+				{Key: "role", Value: "customer"},
+			}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		w := refresh(mt, "old-token")
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		var body struct {
+			Data struct {
+				AccessToken  string `json:"accessToken"`
+				RefreshToken string `json:"refreshToken"`
+			} `json:"data"`
+		}
+		assert.NoError(mt, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.NotEmpty(mt, body.Data.AccessToken)
+		assert.NotEqual(mt, "old-token", body.Data.RefreshToken)
+
+		updates := commands(mt, "update", "sessions")
+		if assert.Len(mt, updates, 1) {
+			update := firstUpdate(updates[0])
+			assert.Equal(mt, false, update.Lookup("q", "revokedAt", "$exists").Boolean(), "only one concurrent refresh wins")
+			assert.Equal(mt, "rotated", update.Lookup("u", "$set", "revokeReason").StringValue())
+
+			inserts := commands(mt, "insert", "sessions")
+			if assert.Len(mt, inserts, 1) {
+				next := inserts[0].Lookup("documents").Array().Index(0).Value().Document()
+				assert.Equal(mt, update.Lookup("u", "$set", "replacedBy").ObjectID(), next.Lookup("_id").ObjectID())
+				assert.Equal(mt, familyID, next.Lookup("familyId").ObjectID(), "the new token stays in the family")
+				assert.Equal(mt, utils.HashToken(body.Data.RefreshToken), next.Lookup("tokenHash").StringValue())
+			}
+		}
+	})
+
+	mt.Run("replaying a rotated token revokes the whole family", func(mt *mtest.T) {
+		userID, familyID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.sessions", mtest.FirstBatch, storedSession(userID, familyID,
+				bson.E{Key: "revokedAt", Value: time.Now().Add(-time.Minute)},
+				bson.E{Key: "revokeReason", Value: "rotated"},
+			)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}),
+		)
+
+		w := refresh(mt, "old-token")
+		assert.Equal(mt, http.StatusUnauthorized, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), "reuse detected")
+
+		updates := commands(mt, "update", "sessions")
+		if assert.Len(mt, updates, 1) {
+			update := firstUpdate(updates[0])
+			assert.Equal(mt, familyID, update.Lookup("q", "familyId").ObjectID())
+			assert.True(mt, update.Lookup("multi").Boolean())
+			assert.Equal(mt, "reuse_detected", update.Lookup("u", "$set", "revokeReason").StringValue())
+		}
+		assert.Empty(mt, commands(mt, "insert", "sessions"))
+	})
+
+	mt.Run("losing a concurrent rotation revokes the whole family", func(mt *mtest.T) {
+		userID, familyID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.sessions", mtest.FirstBatch, storedSession(userID, familyID)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}),
+		)
+
+		w := refresh(mt, "old-token")
+		assert.Equal(mt, http.StatusUnauthorized, w.Code, w.Body.String())
+		updates := commands(mt, "update", "sessions")
+		if assert.Len(mt, updates, 2) {
+			assert.Equal(mt, familyID, firstUpdate(updates[1]).Lookup("q", "familyId").ObjectID())
+		}
+		assert.Empty(mt, commands(mt, "insert", "sessions"))
+	})
+
+	mt.Run("expired token is rejected", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.sessions", mtest.FirstBatch, storedSession(primitive.NewObjectID(), primitive.NewObjectID(),
+			bson.E{Key: "expiresAt", Value: time.Now().Add(-time.Minute)},
+		)))
+
+		w := refresh(mt, "old-token")
+		assert.Equal(mt, http.StatusUnauthorized, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), "expired")
+		assert.Equal(mt, []string{"find"}, commandNames(mt))
+	})
+
+	mt.Run("token of a logged out session is rejected", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.sessions", mtest.FirstBatch, storedSession(primitive.NewObjectID(), primitive.NewObjectID(),
+			bson.E{Key: "revokedAt", Value: time.Now().Add(-time.Minute)},
+			bson.E{Key: "revokeReason", Value: "logout"},
+		)))
+
+		w := refresh(mt, "old-token")
+		assert.Equal(mt, http.StatusUnauthorized, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), "revoked")
+		assert.Equal(mt, []string{"find"}, commandNames(mt), "a revoked session is not rotated")
+	})
+
+	mt.Run("unknown token is rejected", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.sessions", mtest.FirstBatch))
+
+		w := refresh(mt, "made-up")
+		assert.Equal(mt, http.StatusUnauthorized, w.Code, w.Body.String())
+	})
+}
+
+func TestLogoutAll(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("revokes every live session of the user", func(mt *mtest.T) {
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 3}, bson.E{Key: "nModified", Value: 3}))
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/auth/logout-all", middleware.RequireAuth(), handlers.NewAuthHandler(mt.DB).LogoutAll)
+		token, err := utils.GenerateToken(userID.Hex(), "customer", time.Minute)
+		assert.NoError(mt, err)
+		req := httptest.NewRequest(http.MethodPost, "/auth/logout-all", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		updates := commands(mt, "update", "sessions")
+		if assert.Len(mt, updates, 1) {
+			update := firstUpdate(updates[0])
+			assert.Equal(mt, userID, update.Lookup("q", "userId").ObjectID())
+			assert.Equal(mt, false, update.Lookup("q", "revokedAt", "$exists").Boolean())
+			assert.True(mt, update.Lookup("multi").Boolean())
+			assert.Equal(mt, "logout_all", update.Lookup("u", "$set", "revokeReason").StringValue())
+		}
+	})
+}
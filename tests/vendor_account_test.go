@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/handlers"
+	"github.com/developia-II/ecommerce-backend/internal/middleware"
+	"github.com/developia-II/ecommerce-backend/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func vendorAccount(id primitive.ObjectID, tier string, extra ...bson.E) bson.D {
+	return append(bson.D{
+		{Key: "_id", Value: id},
+		{Key: "userID", Value: primitive.NewObjectID()},
+		{Key: "tier", Value: tier},
+		{Key: "status", Value: "active"},
+	}, extra...)
+}
+
+func adminRequest(mt *mtest.T, method, route, target, body string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Handle(method, route, middleware.RequireAuth(), handler)
+
+	token, err := utils.GenerateToken(primitive.NewObjectID().Hex(), "admin", time.Minute)
+	assert.NoError(mt, err)
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestChangeAccountTier(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	changeTier := func(mt *mtest.T, from, to string) bson.Raw {
+		accountID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.vendor_accounts", mtest.FirstBatch, vendorAccount(accountID, from)),
+			mtest.CreateCursorResponse(0, "test.tier_policies", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: vendorAccount(accountID, to)}),
+		)
+
+		w := adminRequest(mt, http.MethodPost, "/admin/vendor-accounts/:id/tier", "/admin/vendor-accounts/"+accountID.Hex()+"/tier",
+			`{"tier":"`+to+`"}`, handlers.NewVendorAccountHandler(mt.DB).ChangeAccountTier)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+
+		updates := commands(mt, "findAndModify", "vendor_accounts")
+		if !assert.Len(mt, updates, 1) {
+			return nil
+		}
+		return updates[0].Lookup("update", "$set").Document()
+	}
+
+	mt.Run("upgrade is stamped", func(mt *mtest.T) {
+		set := changeTier(mt, "individual", "verified")
+		if set != nil {
+			assert.Equal(mt, "verified", set.Lookup("tier").StringValue())
+			_, err := set.LookupErr("tierUpgradedAt")
+			assert.NoError(mt, err)
+		}
+	})
+
+	mt.Run("downgrade keeps the last upgrade date", func(mt *mtest.T) {
+		set := changeTier(mt, "business", "verified")
+		if set != nil {
+			assert.Equal(mt, "verified", set.Lookup("tier").StringValue())
+			_, err := set.LookupErr("tierUpgradedAt")
+			assert.Error(mt, err)
+		}
+	})
+
+	mt.Run("unknown account", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.vendor_accounts", mtest.FirstBatch))
+
+		id := primitive.NewObjectID().Hex()
+		w := adminRequest(mt, http.MethodPost, "/admin/vendor-accounts/:id/tier", "/admin/vendor-accounts/"+id+"/tier",
+			`{"tier":"verified"}`, handlers.NewVendorAccountHandler(mt.DB).ChangeAccountTier)
+		assert.Equal(mt, http.StatusNotFound, w.Code, w.Body.String())
+		assert.Empty(mt, commands(mt, "findAndModify", "vendor_accounts"))
+	})
+}
+
+func TestUpdateTierPolicy(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret-key-12345")
+	defer os.Unsetenv("JWT_SECRET")
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("raising existing limits lowers alerts already sent", func(mt *mtest.T) {
+		accountID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}),
+			// Already warned at 80% of the old 500,000 limit
+			mtest.CreateCursorResponse(0, "test.vendor_accounts", mtest.FirstBatch, vendorAccount(accountID, "individual",
+				bson.E{Key: "maxProducts", Value: 100},
+				bson.E{Key: "productCount", Value: 10},
+				bson.E{Key: "maxMonthlySales", Value: 1000000.0},
+				bson.E{Key: "currentMonthSales", Value: 450000.0},
+				bson.E{Key: "salesLimitAlert", Value: 80},
+			)),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		w := adminRequest(mt, http.MethodPut, "/admin/tier-policies/:tier", "/admin/tier-policies/individual",
+			`{"maxProducts":100,"maxMonthlySales":1000000,"transactionFee":5,"payoutHoldDays":14,"applyToExisting":true}`,
+			handlers.NewVendorAccountHandler(mt.DB).UpdateTierPolicy)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(mt, w.Body.String(), `"accountsUpdated":2`)
+
+		updates := commands(mt, "update", "vendor_accounts")
+		if assert.Len(mt, updates, 2) {
+			assert.Equal(mt, "individual", firstUpdate(updates[0]).Lookup("q", "tier").StringValue())
+			lowered := firstUpdate(updates[1])
+			assert.Equal(mt, accountID, lowered.Lookup("q", "_id").ObjectID())
+			assert.Equal(mt, int32(0), lowered.Lookup("u", "$set", "salesLimitAlert").Int32())
+		}
+	})
+
+	mt.Run("policy alone leaves accounts untouched", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		w := adminRequest(mt, http.MethodPut, "/admin/tier-policies/:tier", "/admin/tier-policies/individual",
+			`{"maxProducts":100,"maxMonthlySales":1000000,"transactionFee":5,"payoutHoldDays":14}`,
+			handlers.NewVendorAccountHandler(mt.DB).UpdateTierPolicy)
+		assert.Equal(mt, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(mt, []string{"update"}, commandNames(mt))
+	})
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/developia-II/ecommerce-backend/internal/handlers"
+	"github.com/developia-II/ecommerce-backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestProductWatchAlerts(t *testing.T) {
+	watch := models.ProductWatch{Price: 100, Stock: 0}
+
+	assert.Empty(t, watch.Alerts(&models.Product{Price: 100, Stock: 0}))
+	assert.Empty(t, watch.Alerts(&models.Product{Price: 120, Stock: 0}))
+	assert.Equal(t, []string{models.WishlistAlertPriceDrop}, watch.Alerts(&models.Product{Price: 90, Stock: 0}))
+	assert.Equal(t, []string{models.WishlistAlertBackInStock}, watch.Alerts(&models.Product{Price: 100, Stock: 3}))
+	assert.Equal(t, []string{models.WishlistAlertPriceDrop, models.WishlistAlertBackInStock}, watch.Alerts(&models.Product{Price: 80, Stock: 1}))
+
+	// Stock going up from some to more is not a restock
+	watch.Stock = 2
+	assert.Empty(t, watch.Alerts(&models.Product{Price: 100, Stock: 10}))
+}
+
+func TestProductWatchVariantAlerts(t *testing.T) {
+	small, large := primitive.NewObjectID(), primitive.NewObjectID()
+	product := func(smallStock, largeStock int) *models.Product {
+		p := &models.Product{Variants: []models.ProductVariant{
+			{ID: small, Price: 100, Stock: smallStock},
+			{ID: large, Price: 100, Stock: largeStock},
+		}}
+		p.SyncVariantTotals()
+		return p
+	}
+	watch := models.NewProductWatch(product(0, 4), time.Now())
+
+	// The product as a whole never ran out, but one of its variants did
+	assert.True(t, watch.Unchanged(product(0, 4)))
+	assert.False(t, watch.Unchanged(product(2, 2)))
+	assert.Equal(t, []string{models.WishlistAlertBackInStock}, watch.Alerts(product(2, 2)))
+	assert.Empty(t, watch.Alerts(product(0, 9)))
+
+	// A variant added since the watch is new, not restocked
+	added := product(0, 4)
+	added.Variants = append(added.Variants, models.ProductVariant{ID: primitive.NewObjectID(), Price: 100, Stock: 3})
+	assert.False(t, watch.Unchanged(added))
+	assert.Empty(t, watch.Alerts(added))
+}
+
+func TestWishlistAlertKey(t *testing.T) {
+	from, product := &models.ProductWatch{Price: 99.5}, &models.Product{Price: 89.5}
+	morning := time.Date(2026, 5, 4, 8, 0, 0, 0, time.UTC)
+	evening := time.Date(2026, 5, 4, 20, 0, 0, 0, time.UTC)
+
+	// The same drop is the same event for the rest of the day
+	assert.Equal(t, models.WishlistAlertKey(models.WishlistAlertPriceDrop, from, product, morning),
+		models.WishlistAlertKey(models.WishlistAlertPriceDrop, from, product, evening))
+	assert.NotEqual(t, models.WishlistAlertKey(models.WishlistAlertPriceDrop, from, product, morning),
+		models.WishlistAlertKey(models.WishlistAlertPriceDrop, from, product, morning.AddDate(0, 0, 1)))
+	// Dropping to the same price from somewhere else is a new drop
+	assert.NotEqual(t, models.WishlistAlertKey(models.WishlistAlertPriceDrop, from, product, morning),
+		models.WishlistAlertKey(models.WishlistAlertPriceDrop, &models.ProductWatch{Price: 120}, product, morning))
+	assert.NotEqual(t, models.WishlistAlertKey(models.WishlistAlertPriceDrop, from, product, morning),
+		models.WishlistAlertKey(models.WishlistAlertPriceDrop, from, &models.Product{Price: 79.5}, morning))
+
+	// Restocks are deduplicated per day
+	assert.Equal(t, models.WishlistAlertKey(models.WishlistAlertBackInStock, from, product, morning),
+		models.WishlistAlertKey(models.WishlistAlertBackInStock, from, product, evening))
+	assert.NotEqual(t, models.WishlistAlertKey(models.WishlistAlertBackInStock, from, product, morning),
+		models.WishlistAlertKey(models.WishlistAlertBackInStock, from, product, morning.AddDate(0, 0, 1)))
+}
+
+func TestWishlistHas(t *testing.T) {
+	id := primitive.NewObjectID()
+	wishlist := models.Wishlist{ProductIDs: []primitive.ObjectID{primitive.NewObjectID(), id}}
+	assert.True(t, wishlist.Has(id))
+	assert.False(t, wishlist.Has(primitive.NewObjectID()))
+}
+
+func TestSendWishlistAlerts(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	// priceDrop queues a product that got cheaper since it was last seen,
+	// and one opted-in user with it on their wishlist
+	priceDrop := func(mt *mtest.T) {
+		productID, userID := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{productID}}),
+			mtest.CreateCursorResponse(0, "test.products", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: productID},
+				{Key: "name", Value: "Sneakers"},
+				{Key: "price", Value: 90.0},
+				{Key: "stock", Value: 3},
+			}),
+			mtest.CreateCursorResponse(0, "test.product_watches", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: productID},
+				{Key: "price", Value: 100.0},
+				{Key: "stock", Value: 3},
+				{Key: "checkedAt", Value: time.Now().Add(-time.Hour)},
+			}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "test.wishlists", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "userId", Value: userID},
+			}),
+			mtest.CreateCursorResponse(0, "test.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "email", Value: "ada@example.com"},
+				{Key: "name", Value: "Ada"},
+			}),
+		)
+	}
+
+	mt.Run("user at the daily limit gets nothing", func(mt *mtest.T) {
+		priceDrop(mt)
+		// The counter is full, so the conditional upsert collides with it
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}))
+
+		assert.NoError(mt, handlers.SendWishlistAlerts(mt.DB)(context.Background()))
+		replaces := commands(mt, "update", "product_watches")
+		if assert.Len(mt, replaces, 1) {
+			update := firstUpdate(replaces[0])
+			_, err := update.LookupErr("q", "checkedAt")
+			assert.NoError(mt, err, "the snapshot only moves on from the one read")
+			assert.Equal(mt, 90.0, update.Lookup("u", "price").Double())
+		}
+		counts := commands(mt, "update", "wishlist_alert_counts")
+		if assert.Len(mt, counts, 1) {
+			update := firstUpdate(counts[0])
+			assert.Equal(mt, int32(5), update.Lookup("q", "count", "$lt").Int32())
+			assert.Equal(mt, int32(1), update.Lookup("u", "$inc", "count").Int32())
+			assert.True(mt, update.Lookup("upsert").Boolean())
+		}
+		assert.Empty(mt, commands(mt, "insert", "wishlist_notifications"))
+	})
+
+	mt.Run("alert already sent gives its slot back", func(mt *mtest.T) {
+		priceDrop(mt)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		assert.NoError(mt, handlers.SendWishlistAlerts(mt.DB)(context.Background()))
+		inserts := commands(mt, "insert", "wishlist_notifications")
+		if assert.Len(mt, inserts, 1) {
+			notification := inserts[0].Lookup("documents").Array().Index(0).Value().Document()
+			assert.Contains(mt, notification.Lookup("key").StringValue(), "price_drop:100.00:90.00:")
+		}
+		counts := commands(mt, "update", "wishlist_alert_counts")
+		if assert.Len(mt, counts, 2) {
+			assert.Equal(mt, int32(-1), firstUpdate(counts[1]).Lookup("u", "$inc", "count").Int32())
+		}
+	})
+}
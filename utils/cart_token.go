@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidCartToken = errors.New("invalid cart token")
+	ErrCartTokenExpired = errors.New("cart token expired")
+)
+
+// SignCartToken returns a token for a guest cart: the cart ID and the Unix
+// time it expires at, followed by an HMAC of both, so clients cannot guess or
+// forge other visitors' carts or extend a token's lifetime.
+func SignCartToken(cartID string, expiresAt time.Time) (string, error) {
+	payload := cartID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	mac, err := cartTokenMAC(payload)
+	if err != nil {
+		return "", err
+	}
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// ParseCartToken verifies a token from SignCartToken and returns the cart ID.
+// A correctly signed token past its expiry fails with ErrCartTokenExpired.
+func ParseCartToken(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", ErrInvalidCartToken
+	}
+	cartID, exp, sig := parts[0], parts[1], parts[2]
+	expiresAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return "", ErrInvalidCartToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", ErrInvalidCartToken
+	}
+	want, err := cartTokenMAC(cartID + "." + exp)
+	if err != nil {
+		return "", err
+	}
+	if !hmac.Equal(got, want) {
+		return "", ErrInvalidCartToken
+	}
+	if time.Now().Unix() >= expiresAt {
+		return "", ErrCartTokenExpired
+	}
+	return cartID, nil
+}
+
+func cartTokenMAC(payload string) ([]byte, error) {
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" {
+		return nil, errors.New("JWT_SECRET not set in environment")
+	}
+	mac := hmac.New(sha256.New, []byte("cart:"+secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil), nil
+}
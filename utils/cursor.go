@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the position of the last item of a page for keyset
+// pagination: the value of the sort field and the document ID breaking ties.
+// Sort records the ordering it was issued for so it cannot be replayed
+// against a different one.
+type Cursor struct {
+	Sort  string  `json:"s"`
+	Value float64 `json:"v,omitempty"`
+	ID    string  `json:"id"`
+}
+
+// EncodeCursor turns a cursor into an opaque URL-safe string.
+func EncodeCursor(c Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor.
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -33,13 +33,15 @@ func SendEmail(to, subject, body string) error {
 	senderEmail := os.Getenv("SENDER_EMAIL")
 	senderName := os.Getenv("SENDER_NAME")
 
-	logrus.WithField("apiKey", apiKey[:10]+"...").Info("Using API key")
-	logrus.WithField("senderEmail", senderEmail).Info("Using sender email")
-
 	if apiKey == "" || senderEmail == "" {
 		return fmt.Errorf("BREVO_API_KEY or SENDER_EMAIL not set")
 	}
 
+	if len(apiKey) > 10 {
+		logrus.WithField("apiKey", apiKey[:10]+"...").Info("Using API key")
+	}
+	logrus.WithField("senderEmail", senderEmail).Info("Using sender email")
+
 	// Prepare request payload
 	payload := BrevoEmailRequest{
 		Sender: BrevoSender{
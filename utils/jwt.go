@@ -2,6 +2,7 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"os"
@@ -52,9 +53,18 @@ func VerifyToken(tokenString string) (*JWTClaims, error) {
 	return token.Claims.(*JWTClaims), nil
 }
 
-// func RefreshToken(tokenString string) (string, error) {
+// Access tokens are short-lived; clients use a refresh token (see the
+// sessions collection) to obtain a new one.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
 
-// }
+// GenerateRefreshToken returns an opaque random refresh token. Only its
+// hash is persisted server-side.
+func GenerateRefreshToken() (string, error) {
+	return GenerateSecureToken(32)
+}
 
 func GenerateSecureToken(length int) (string, error) {
 	bytes := make([]byte, length)
@@ -63,3 +73,10 @@ func GenerateSecureToken(length int) (string, error) {
 	}
 	return hex.EncodeToString(bytes), nil
 }
+
+// HashToken returns the hex encoded SHA-256 digest of a token so that
+// tokens can be stored and looked up without keeping the raw value.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}